@@ -0,0 +1,117 @@
+package rotatefile
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// BackupInfo 历史文件信息
+type BackupInfo struct {
+	// Name 相对于日志目录的路径
+	Name string `json:"name"`
+	// Size 文件大小
+	Size int64 `json:"size"`
+	// Time 滚动时间
+	Time time.Time `json:"time"`
+	// Compressed 是否已经压缩
+	Compressed bool `json:"compressed"`
+}
+
+// Backups 返回历史文件列表，按滚动时间从新到旧排序
+func (l *file) Backups() ([]BackupInfo, error) {
+	l.mu.Lock()
+	err := l.resolveFileName()
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := l.oldLogFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]BackupInfo, 0, len(files))
+	for _, f := range files {
+		backups = append(backups, BackupInfo{
+			Name:       f.Name,
+			Size:       f.Size,
+			Time:       f.timestamp,
+			Compressed: l.compressorOf(f.Name) != nil,
+		})
+	}
+	return backups, nil
+}
+
+// AdminHandler 返回管理接口，可以挂载到应用已有的调试路由上，例如
+// mux.Handle("/debug/log/", http.StripPrefix("/debug/log", rotatefile.AdminHandler(rf)))
+//
+//	POST /rotate  强制滚动
+//	POST /flush   刷新缓冲
+//	GET  /stats   运行状态
+//	GET  /backups 历史文件列表
+//	GET  /plan    预演清理，返回将要执行的操作
+func AdminHandler(rf RotateFile) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rotate", adminAction(rf.Rotate))
+	mux.HandleFunc("/flush", adminAction(rf.Flush))
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		if !adminMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, rf.Stats())
+	})
+	mux.HandleFunc("/backups", func(w http.ResponseWriter, r *http.Request) {
+		if !adminMethod(w, r, http.MethodGet) {
+			return
+		}
+		backups, err := rf.Backups()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, backups)
+	})
+	mux.HandleFunc("/plan", func(w http.ResponseWriter, r *http.Request) {
+		if !adminMethod(w, r, http.MethodGet) {
+			return
+		}
+		actions, err := rf.PlanCleanup()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, actions)
+	})
+	return mux
+}
+
+// adminAction 包装无参数的操作为 POST 接口
+func adminAction(action func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !adminMethod(w, r, http.MethodPost) {
+			return
+		}
+		if err := action(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// adminMethod 检查请求方法，不匹配时应答 405
+func adminMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
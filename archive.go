@@ -0,0 +1,99 @@
+package rotatefile
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// Archiver 归档（上传）历史文件，例如上传到对象存储，path 为历史文件完整路径，返回 nil 表示归档成功
+type Archiver interface {
+	Archive(path string) error
+}
+
+// ArchiverFunc 将函数适配为 Archiver
+type ArchiverFunc func(path string) error
+
+// Archive 返回 f(path)
+func (f ArchiverFunc) Archive(path string) error { return f(path) }
+
+// shippedSet 已归档的历史文件名，持久化在清单文件中
+type shippedSet struct {
+	once sync.Once
+	mu   sync.Mutex
+	m    map[string]bool
+}
+
+// loadShipped 首次使用时，从清单文件中恢复归档状态
+func (l *file) loadShipped() {
+	l.shipped.once.Do(func() {
+		l.shipped.m = map[string]bool{}
+		if m, err := LoadManifest(l.manifestPath()); err == nil {
+			for _, e := range m.Backups {
+				if e.Shipped {
+					l.shipped.m[e.Name] = true
+				}
+			}
+		}
+	})
+}
+
+// isShipped 历史文件 name 是否已经归档
+func (l *file) isShipped(name string) bool {
+	l.loadShipped()
+	l.shipped.mu.Lock()
+	defer l.shipped.mu.Unlock()
+	return l.shipped.m[name]
+}
+
+// setShipped 记录历史文件 name 的归档状态
+func (l *file) setShipped(name string, shipped bool) {
+	l.loadShipped()
+	l.shipped.mu.Lock()
+	defer l.shipped.mu.Unlock()
+	if shipped {
+		l.shipped.m[name] = true
+	} else {
+		delete(l.shipped.m, name)
+	}
+}
+
+// keepUnshipped 配置了 Archiver 时，尚未归档的历史文件不删除（过期、个数、总大小等），
+// 磁盘空余低于 ArchiveEmergencyFree 时除外
+func (l *file) keepUnshipped(name string) bool {
+	if l.Archiver == nil || l.isShipped(name) {
+		return false
+	}
+	if l.ArchiveEmergencyFree > 0 {
+		if info, err := getDiskInfo(l.dir()); err == nil && info.Free < l.ArchiveEmergencyFree {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveBackups 归档尚未归档的历史文件，需要压缩的，压缩后再归档，失败的下次清理时重试
+func (l *file) archiveBackups() error {
+	if l.Archiver == nil {
+		return nil
+	}
+	files, err := l.oldLogFiles()
+	if err != nil {
+		return err
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		name := files[i].Name
+		if l.isShipped(name) || l.isCompressing(name) || l.Compress && l.compressorOf(name) == nil {
+			continue
+		}
+		if errArchive := l.Archiver.Archive(filepath.Join(l.dir(), name)); errArchive != nil {
+			if err == nil {
+				err = errArchive
+			}
+			continue
+		}
+		l.setShipped(name, true)
+		l.emit(Event{Type: Archived, Path: filepath.Join(l.dir(), name)})
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package rotatefile
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+const (
+	// defaultAsyncQueueSize 异步写入队列的默认长度
+	defaultAsyncQueueSize = 4096
+	// asyncBatchSize 写入协程合并队列中的多条消息，一次写入文件的最大字节数
+	asyncBatchSize = 64 * 1024
+)
+
+// writeMsg 异步写入队列中的消息，done 非空时表示刷新请求，stop 时写入协程随后退出
+type writeMsg struct {
+	p    []byte
+	done chan struct{}
+	stop bool
+}
+
+// writeAsync 将 p 的副本放入异步写入队列，由唯一的写入协程写入文件，
+// 队列满时阻塞等待，非阻塞模式下丢弃并返回 ErrWouldBlock
+func (l *file) writeAsync(p []byte) (int, error) {
+	m := writeMsg{p: append([]byte(nil), p...)}
+	for {
+		q := l.asyncQueue()
+		if !q.enter() {
+			// 队列正在停止（并发 Close），使用重新启动的队列
+			continue
+		}
+		sent := true
+		if !l.NonBlocking {
+			q.ch <- m
+		} else {
+			select {
+			case q.ch <- m:
+			default:
+				sent = false
+			}
+		}
+		q.leave()
+
+		if !sent {
+			return l.wouldBlock()
+		}
+		return len(p), nil
+	}
+}
+
+// queueGate 异步队列的发送方计数，停止队列时先等待正在进行的发送完成，此后的发送失败，
+// 以免消息留在写入协程已经退出的队列中而丢失，或者等待已经退出的协程而永久阻塞
+type queueGate struct {
+	senders atomic.Int64
+	stopped atomic.Bool
+	// exited 协程退出时关闭
+	exited chan struct{}
+}
+
+// enter 开始发送，队列已经停止时返回 false，否则发送完成后需调用 leave
+func (g *queueGate) enter() bool {
+	g.senders.Add(1)
+	if g.stopped.Load() {
+		g.senders.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (g *queueGate) leave() {
+	g.senders.Add(-1)
+}
+
+// stop 标记队列停止，并等待正在进行的发送完成，此后队列只由停止方发送
+func (g *queueGate) stop() {
+	g.stopped.Store(true)
+	for g.senders.Load() > 0 {
+		runtime.Gosched()
+	}
+}
+
+// asyncQueue 异步写入队列
+type asyncQueue struct {
+	ch chan writeMsg
+	queueGate
+}
+
+// asyncQueue 返回异步写入队列，写入协程未运行（首次写入或者关闭后）时启动，该协程独占当前日志文件的写入，
+// 队列运行时无需加锁
+func (l *file) asyncQueue() *asyncQueue {
+	if q := l.asyncQ.Load(); q != nil {
+		return q
+	}
+
+	l.asyncMu.Lock()
+	defer l.asyncMu.Unlock()
+
+	if q := l.asyncQ.Load(); q != nil {
+		return q
+	}
+	size := l.AsyncQueueSize
+	if size <= 0 {
+		size = defaultAsyncQueueSize
+	}
+	q := &asyncQueue{ch: make(chan writeMsg, size), queueGate: queueGate{exited: make(chan struct{})}}
+	register(l)
+	go l.asyncWriter(q)
+	l.asyncQ.Store(q)
+	return q
+}
+
+// asyncWriter 依次处理异步写入队列中的消息，并将队列中已有的消息合并后一次写入文件，以减少系统调用，
+// 收到 stop 消息后退出
+func (l *file) asyncWriter(q *asyncQueue) {
+	defer close(q.exited)
+
+	ch := q.ch
+	batchSize := int64(asyncBatchSize)
+	if max := l.max(); max < batchSize {
+		batchSize = max
+	}
+
+	var batch []byte
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if _, err := l.failoverWrite(batch, l.writeFile); err != nil {
+			debugf("async write %s: %v", l.filename(), err)
+		}
+		batch = batch[:0]
+	}
+
+	for m := range ch {
+		for {
+			if m.done != nil {
+				flush()
+				close(m.done)
+				if m.stop {
+					return
+				}
+			} else if p, ok := l.beforeWrite(m.p); ok {
+				if len(batch) > 0 && int64(len(batch)+len(p)) > batchSize {
+					flush()
+				}
+				batch = append(batch, p...)
+			}
+
+			var more bool
+			select {
+			case m, more = <-ch:
+			default:
+			}
+			if !more {
+				break
+			}
+		}
+		flush()
+	}
+}
+
+// flushAsync 等待异步写入队列中已有的内容写入完毕，写入协程未运行时没有需要写入的内容，不启动写入协程
+func (l *file) flushAsync() {
+	q := l.asyncQ.Load()
+	if q == nil {
+		return
+	}
+	if !q.enter() {
+		// 正在停止，停止前写入队列中已有的内容
+		<-q.exited
+		return
+	}
+	done := make(chan struct{})
+	q.ch <- writeMsg{done: done}
+	q.leave()
+	<-done
+}
+
+// stopAsync 写入队列中已有的内容后，停止异步写入协程
+func (l *file) stopAsync() {
+	l.asyncMu.Lock()
+	q := l.asyncQ.Swap(nil)
+	l.asyncMu.Unlock()
+
+	if q != nil {
+		q.stop()
+		q.ch <- writeMsg{done: make(chan struct{}), stop: true}
+		<-q.exited
+	}
+}
@@ -0,0 +1,47 @@
+package rotatefile
+
+import "sync"
+
+// background 后台协程，done 关闭时退出
+type background struct {
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Go 启动后台协程 fn，done 关闭时 fn 应当返回
+func (b *background) Go(fn func(done <-chan struct{})) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn(b.done)
+	}()
+}
+
+// startBackground 打开日志文件时启动后台协程（滚动触发文件检查、定时刷盘、磁盘使用率检查等），已经运行时不重复启动，
+// Close 时通过 stopBackground 停止，关闭后再次写入时重新启动
+func (l *file) startBackground() {
+	l.bgMu.Lock()
+	defer l.bgMu.Unlock()
+
+	if l.bg != nil {
+		return
+	}
+	l.bg = &background{done: make(chan struct{})}
+	l.watchRotateTrigger(l.bg)
+	l.startSyncInterval(l.bg)
+	l.watchDiskUsage(l.bg)
+	l.startDropSummary(l.bg)
+}
+
+// stopBackground 通知后台协程退出，并等待其退出，以免关闭后仍然滚动、刷盘而重新打开日志文件
+func (l *file) stopBackground() {
+	l.bgMu.Lock()
+	bg := l.bg
+	l.bg = nil
+	l.bgMu.Unlock()
+
+	if bg != nil {
+		close(bg.done)
+		bg.wg.Wait()
+	}
+}
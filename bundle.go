@@ -0,0 +1,187 @@
+package rotatefile
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// bundleDayFormat 每日打包文件名中的日期格式
+	bundleDayFormat = "20060102"
+	// bundleSuffix 每日打包文件的扩展名
+	bundleSuffix = ".tar" + compressSuffix
+)
+
+// bundleName 返回指定日期的打包文件名，例如 app-20240102.tar.gz
+func (l *file) bundleName(day string) string {
+	prefix, _ := l.prefixAndExt()
+	return strings.TrimSuffix(prefix, ".") + "-" + day + bundleSuffix
+}
+
+// timeFromBundleName 解析打包文件名中的日期，返回该日结束时间作为其滚动时间
+func (l *file) timeFromBundleName(name string) (time.Time, bool) {
+	prefix, _ := l.prefixAndExt()
+	prefix = strings.TrimSuffix(prefix, ".") + "-"
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, bundleSuffix) {
+		return time.Time{}, false
+	}
+
+	day := name[len(prefix) : len(name)-len(bundleSuffix)]
+	if len(day) != len(bundleDayFormat) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(bundleDayFormat, day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.Add(DAY - time.Millisecond), true
+}
+
+// bundleDays 将已经结束的日期的历史文件，按天打包压缩为一个 tar.gz 文件，返回剩余未打包的历史文件
+func (l *file) bundleDays(files []logInfo) ([]logInfo, error) {
+	now := l.now()
+	if l.UtcTime {
+		now = now.UTC()
+	}
+	today := now.Format(bundleDayFormat)
+
+	days := map[string][]logInfo{}
+	var remaining []logInfo
+	for _, f := range files {
+		day := f.timestamp.Format(bundleDayFormat)
+		if strings.HasSuffix(f.Name, bundleSuffix) || day >= today {
+			remaining = append(remaining, f)
+			continue
+		}
+		days[day] = append(days[day], f)
+	}
+
+	var err error
+	for day, dayFiles := range days {
+		if errBundle := l.bundleDay(day, dayFiles); errBundle != nil {
+			remaining = append(remaining, dayFiles...)
+			if err == nil {
+				err = errBundle
+			}
+		}
+	}
+
+	return remaining, err
+}
+
+// bundleDay 将一天的历史文件打包压缩，已有当天的打包文件时，合并其中内容，成功后删除原历史文件
+func (l *file) bundleDay(day string, files []logInfo) (err error) {
+	bundle := filepath.Join(l.dir(), l.bundleName(day))
+	tmp := bundle + ".tmp"
+
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open bundle file: %v", err)
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(tmp)
+			err = fmt.Errorf("failed to bundle log files: %v", err)
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	if err := copyBundle(tw, bundle); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// files 从最新到最老排列，按时间顺序打包
+	for i := len(files) - 1; i >= 0; i-- {
+		if err := addToBundle(tw, filepath.Join(l.dir(), files[i].Name)); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := l.unprotectBackup(bundle); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, bundle); err != nil {
+		return err
+	}
+	l.protectBackup(bundle)
+
+	for _, f := range files {
+		if errRemove := l.removeBackup(f.Name, ReasonDailyBundle); errRemove != nil && err == nil {
+			err = errRemove
+		}
+	}
+	return err
+}
+
+// copyBundle 将已有打包文件中的内容复制到 tw 中
+func copyBundle(tw *tar.Writer, bundle string) error {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// addToBundle 将文件 name 添加到 tw 中
+func addToBundle(tw *tar.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -50,6 +50,23 @@ func ParseBytes(s string) (uint64, error) {
 	return 0, fmt.Errorf("unhandled size name: %v", extra)
 }
 
+// Bytes produces a human readable representation of an SI size.
+//
+// Bytes(82854982) -> 83 MB
+func Bytes(s uint64) string {
+	sizes := []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	if s < 10 {
+		return fmt.Sprintf("%d B", s)
+	}
+	e := math.Floor(math.Log(float64(s)) / math.Log(1000))
+	val := math.Floor(float64(s)/math.Pow(1000, e)*10+0.5) / 10
+	f := "%.0f %s"
+	if val < 10 {
+		f = "%.1f %s"
+	}
+	return fmt.Sprintf(f, val, sizes[int(e)])
+}
+
 var bytesSizeTable = map[string]uint64{
 	"b":   Byte,
 	"kib": KiByte,
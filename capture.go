@@ -0,0 +1,64 @@
+package rotatefile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+)
+
+// CaptureCommand 在 cmd 启动前调用，将子进程的标准输出、标准错误分别写入滚动日志文件 out、errFile，
+// 每行加上前缀 "{程序名}: "，errFile 为 nil 或者与 out 相同时，标准错误也写入 out，前缀为 "{程序名} stderr: "，
+// 适合用 Go 编写的进程管理程序为子进程输出提供滚动，子进程输出不完整的行时，该行可能与其它输出交错
+func CaptureCommand(cmd *exec.Cmd, out, errFile RotateFile) error {
+	if cmd.Process != nil {
+		return errors.New("rotatefile: CaptureCommand after process started")
+	}
+	if out == nil {
+		return errors.New("rotatefile: CaptureCommand with nil out")
+	}
+
+	name := cmd.Path
+	if len(cmd.Args) > 0 {
+		name = cmd.Args[0]
+	}
+	name = filepath.Base(name)
+
+	cmd.Stdout = &prefixWriter{w: out, prefix: []byte(name + ": "), atLineStart: true}
+	if errFile == nil || errFile == out {
+		cmd.Stderr = &prefixWriter{w: out, prefix: []byte(name + " stderr: "), atLineStart: true}
+	} else {
+		cmd.Stderr = &prefixWriter{w: errFile, prefix: []byte(name + ": "), atLineStart: true}
+	}
+	return nil
+}
+
+// prefixWriter 在每行开头加上前缀 prefix 后写入 w，一次写入中的多行合并为一次写入
+type prefixWriter struct {
+	w           io.Writer
+	prefix      []byte
+	atLineStart bool
+	buf         []byte
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = p.buf[:0]
+	for rest := b; len(rest) > 0; {
+		if p.atLineStart {
+			p.buf = append(p.buf, p.prefix...)
+		}
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		p.buf = append(p.buf, line...)
+		rest = rest[len(line):]
+		p.atLineStart = line[len(line)-1] == '\n'
+	}
+
+	if _, err := p.w.Write(p.buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
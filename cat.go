@@ -0,0 +1,17 @@
+package rotatefile
+
+import (
+	"io"
+	"time"
+)
+
+// Cat 不写入日志，按时间顺序流式返回日志文件 filename 及其历史文件（包括压缩文件，解压后返回）的内容，
+// since/until 用于根据历史文件名中的滚动时间跳过无关文件，零值表示不限制，
+// 与 Grep 一样只按文件粒度筛选
+func Cat(filename string, since, until time.Time, fns ...ConfigFn) (io.ReadCloser, error) {
+	c := createConfig(fns...)
+	c.Filename = filename
+	l := &file{Config: c}
+	l.setPaths(filename, nil)
+	return l.grepBetween(nil, l.dir(), filename, since, until)
+}
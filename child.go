@@ -0,0 +1,86 @@
+package rotatefile
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// children 子日志文件，与父日志文件位于同一目录，共享父日志文件的配置及 TotalSizeCap 额度
+type children struct {
+	mu    sync.Mutex
+	files map[string]*file
+}
+
+// Child 返回分类为 suffix 的子日志文件，例如 app.log 的 Child("slow") 为 app_slow.log，
+// 子日志文件使用父日志文件的配置滚动，其历史文件计入父日志文件的 TotalSizeCap
+func (l *file) Child(suffix string) RotateFile {
+	l.mill() // 确定父日志文件的路径
+
+	l.children.mu.Lock()
+	defer l.children.mu.Unlock()
+
+	if c, ok := l.children.files[suffix]; ok {
+		return c
+	}
+
+	prefix, ext := l.prefixAndExt()
+	c := l.Config
+	c.Filename = filepath.Join(l.dir(), strings.TrimSuffix(prefix, ".")+"_"+suffix+ext)
+	// 总大小由父日志文件统一控制，控制通道及滚动触发文件只由父日志文件使用
+	c.TotalSizeCap = 0
+	c.MinDiskFree = 0
+	c.MinFreeInodes = 0
+	c.MaxDiskUsage = 0
+	c.CtlSocket = ""
+	c.RotateTrigger = ""
+	c.Failover = nil
+
+	child := &file{Config: c, parent: l}
+	if l.children.files == nil {
+		l.children.files = map[string]*file{}
+	}
+	l.children.files[suffix] = child
+	return child
+}
+
+// childFiles 返回所有子日志文件
+func (l *file) childFiles() []*file {
+	l.children.mu.Lock()
+	defer l.children.mu.Unlock()
+
+	files := make([]*file, 0, len(l.children.files))
+	for _, c := range l.children.files {
+		files = append(files, c)
+	}
+	return files
+}
+
+// childLogFiles 返回所有子日志文件的历史文件，以及子日志文件当前的大小之和，
+// 持有子日志文件的锁读取，以免滚动过程中（已改名、尚未重置大小）重复统计，
+// 子日志文件同步清理时（SyncMill）已持有自身的锁，且已完成滚动，无需再加锁
+func (l *file) childLogFiles() (files []logInfo, activeSize int64, err error) {
+	for _, c := range l.childFiles() {
+		inline := c.inlineMill.Load()
+		if !inline {
+			c.mu.Lock()
+		}
+		activeSize += c.size.Load()
+		prefix, ext := c.childPrefixAndExt()
+		errScan := l.scanBackups("", l.subdirDepth(), prefix, ext, &files)
+		if !inline {
+			c.mu.Unlock()
+		}
+		if errScan != nil && err == nil {
+			err = errScan
+		}
+	}
+	return files, activeSize, err
+}
+
+// childPrefixAndExt 根据配置的文件名返回子日志文件的前缀和扩展名，不依赖子日志文件是否已经写入
+func (l *file) childPrefixAndExt() (prefix, ext string) {
+	filename := filepath.Base(l.Filename)
+	ext = filepath.Ext(filename)
+	return filename[:len(filename)-len(ext)] + ".", ext
+}
@@ -5,8 +5,25 @@ package rotatefile
 
 import (
 	"os"
+	"runtime"
 )
 
+// osChown is a var so we can mock it out during tests.
+var osChown = os.Chown
+
 func chown(_ string, _ os.FileInfo) error {
 	return nil
 }
+
+// chownTo 创建文件 name（如果不存在），并设置属主为 uid/gid，-1 表示不修改，Windows 上不支持
+func chownTo(name string, mode os.FileMode, uid, gid int) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return osChown(name, uid, gid)
+}
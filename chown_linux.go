@@ -17,3 +17,13 @@ func chown(name string, info os.FileInfo) error {
 	stat := info.Sys().(*syscall.Stat_t)
 	return osChown(name, int(stat.Uid), int(stat.Gid))
 }
+
+// chownTo 创建文件 name（如果不存在），并设置属主为 uid/gid，-1 表示不修改
+func chownTo(name string, mode os.FileMode, uid, gid int) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return osChown(name, uid, gid)
+}
@@ -0,0 +1,97 @@
+package rotatefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// cleanState 独立清理或者预演清理时，记录删除（或者 dryRun 时将要删除）的历史文件
+type cleanState struct {
+	dryRun  bool
+	removed map[string]bool
+	paths   []string
+	actions []Action
+}
+
+// record 记录删除（或者移入隔离目录）的历史文件，name 为相对于日志目录的路径，op、reason 为操作及原因
+func (c *cleanState) record(dir, name, op, reason string) {
+	if c.removed[name] {
+		return
+	}
+	c.removed[name] = true
+	c.paths = append(c.paths, filepath.Join(dir, name))
+	c.plan(op, filepath.Join(dir, name), reason)
+}
+
+// plan 记录清理操作
+func (c *cleanState) plan(op, path, reason string) {
+	c.actions = append(c.actions, Action{Op: op, Path: path, Reason: reason})
+}
+
+// isDryRun 是否只预演，不做任何修改
+func (c *cleanState) isDryRun() bool {
+	return c != nil && c.dryRun
+}
+
+// isRemoved 判断相对于日志目录的历史文件 name 是否已经（dryRun 时假定）删除
+func (c *cleanState) isRemoved(name string) bool {
+	return c != nil && c.removed[name]
+}
+
+// Clean 不写入日志，按配置独立清理目录 dir 中 rotatefile 格式的历史文件（包括其它程序写入的），
+// 依次对每个日志文件执行过期、个数、压缩等处理，最后按 TotalSizeCap/MinDiskFree/MinFreeInodes/MaxDiskUsage 控制整个目录的总大小，
+// 返回删除的文件路径，dryRun 时不做任何修改，只返回将要删除的文件，适合由 cron 定期执行
+func Clean(dir string, dryRun bool, fns ...ConfigFn) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := createConfig(fns...)
+	state := &cleanState{dryRun: dryRun, removed: map[string]bool{}}
+	if dryRun {
+		// 不压缩、归档、打包，也不更新清单
+		c.Compress, c.DailyBundle, c.BackupSubdirLayout, c.Manifest = false, false, "", false
+	}
+
+	probe := &file{Config: c}
+	actives := map[string]bool{}
+	for _, e := range entries {
+		if active, _, ok := probe.splitAnyBackupName(e.Name()); ok && !e.IsDir() {
+			actives[active] = true
+		}
+	}
+	names := make([]string, 0, len(actives))
+	for name := range actives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	newFile := func(name string, cfg Config) *file {
+		cfg.Filename = filepath.Join(dir, name)
+		l := &file{Config: cfg, clean: state}
+		l.setPaths(cfg.Filename, nil)
+		return l
+	}
+
+	var errs []error
+	perFile := c
+	perFile.TotalSizeCap, perFile.MinDiskFree, perFile.MinFreeInodes, perFile.MaxDiskUsage = 0, 0, 0, 0
+	for _, name := range names {
+		if err := newFile(name, perFile).millRunOnce(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	capped := c
+	capped.TotalSizeCapDir = true
+	if len(names) > 0 {
+		if err := newFile(names[0], capped).keepTotalSizeCap(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return state.paths, errors.Join(errs...)
+}
@@ -0,0 +1,35 @@
+package rotatefile
+
+import (
+	"strings"
+	"time"
+)
+
+// 超过 TotalSizeCap、MinDiskFree 等额度时，历史文件的删除顺序
+const (
+	// CleanupOldest 从最早的历史文件开始删除（默认）
+	CleanupOldest = "oldest"
+	// CleanupLargest 从最大的历史文件开始删除，大小相同时先删除较早的
+	CleanupLargest = "largest"
+	// CleanupUncompressed 先删除未压缩的历史文件，再删除压缩的，各自从最早的开始
+	CleanupUncompressed = "uncompressed"
+)
+
+// cleanupBefore 按删除顺序策略 strategy，判断历史文件 a 是否应先于 b 删除，compressed 为是否已压缩，
+// 调用方需先按时间从早到晚排序，再按此稳定排序，策略相同的文件保持从早到晚
+func cleanupBefore(strategy string, a, b logInfo, aCompressed, bCompressed bool) bool {
+	switch strings.ToLower(strategy) {
+	case CleanupLargest:
+		return a.Size > b.Size
+	case CleanupUncompressed:
+		return !aCompressed && bCompressed
+	default:
+		return false
+	}
+}
+
+// tooYoung 历史文件 f 的时间距 now 不足 MinBackupAge 时返回 true，超过额度时也不删除，
+// 以免删除外部日志采集程序尚未上传的文件
+func (c *Config) tooYoung(f logInfo, now time.Time) bool {
+	return c.MinBackupAge > 0 && now.Sub(f.timestamp) < c.MinBackupAge
+}
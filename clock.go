@@ -0,0 +1,22 @@
+package rotatefile
+
+import "time"
+
+// Clock 时钟，用于历史文件名中的时间戳、按天滚动以及按时间清理，测试中可以替换为可控的时钟
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc 将函数适配为 Clock，例如 ClockFunc(time.Now)
+type ClockFunc func() time.Time
+
+// Now 返回 f()
+func (f ClockFunc) Now() time.Time { return f() }
+
+// now 返回当前时间，优先使用配置的 Clock
+func (c *Config) now() time.Time {
+	if c.Clock != nil {
+		return c.Clock.Now()
+	}
+	return currentTime()
+}
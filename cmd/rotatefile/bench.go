@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// rateValue 每秒写入行数参数，例如 50k/s、1000、2m/s，0 表示不限速
+type rateValue float64
+
+func (r *rateValue) String() string { return strconv.FormatFloat(float64(*r), 'f', -1, 64) + "/s" }
+
+func (r *rateValue) Set(v string) error {
+	s := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(v)), "/s")
+	unit := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		s, unit = s[:len(s)-1], 1e3
+	case strings.HasSuffix(s, "m"):
+		s, unit = s[:len(s)-1], 1e6
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid rate %q, expected format like 50k/s", v)
+	}
+	*r = rateValue(f * unit)
+	return nil
+}
+
+// bench 以指定的行大小和速率写入滚动日志文件，报告吞吐量、写入延迟及滚动次数，例如：
+// rotatefile bench -line-size 1KB -rate 50k/s -duration 60s
+func bench(args []string) error {
+	fs := flag.NewFlagSet("rotatefile bench", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: rotatefile bench [-line-size 1KB] [-rate 50k/s] [-duration 60s] [flags]\n")
+		fs.PrintDefaults()
+		fmt.Fprint(fs.Output(), envUsage)
+	}
+
+	c := rotatefile.NewConfig(rotatefile.WithPrintTerm(false))
+	configFlags(fs, &c)
+	fs.StringVar(&c.Filename, "f", c.Filename, "-filename 的简写，例如 /var/log/app/app.log")
+	lineSize := sizeValue(rotatefile.KByte)
+	fs.Var(&lineSize, "line-size", "每行日志的大小（包括换行符），例如 1KB")
+	var rate rateValue
+	fs.Var(&rate, "rate", "每秒写入行数，例如 50k/s，0 表示不限速")
+	duration := fs.Duration("duration", 60*time.Second, "压测时长")
+	fs.Parse(args)
+
+	if lineSize == 0 {
+		return fmt.Errorf("invalid line size 0")
+	}
+
+	rf := rotatefile.New(rotatefile.WithConfig(c))
+	defer rf.Close()
+
+	r, err := runBench(rf, int(lineSize), float64(rate), *duration)
+	if err != nil {
+		return err
+	}
+	r.Rotations = rf.Stats().Rotations
+	r.print(os.Stdout)
+	return nil
+}
+
+// benchResult 压测结果
+type benchResult struct {
+	Lines     int64
+	Bytes     int64
+	Elapsed   time.Duration
+	Rotations int64
+	// Latencies 每次写入的耗时
+	Latencies []time.Duration
+}
+
+// runBench 在 duration 内以每秒 rate 行（0 表示不限速）向 w 写入大小为 lineSize 的行，记录每次写入的耗时
+func runBench(w io.Writer, lineSize int, rate float64, duration time.Duration) (benchResult, error) {
+	line := []byte(RandStringBytesMaskImprSrc(lineSize-1) + "\n")
+
+	var r benchResult
+	start := time.Now()
+	for {
+		now := time.Now()
+		elapsed := now.Sub(start)
+		if elapsed >= duration {
+			break
+		}
+		if rate > 0 && float64(r.Lines) >= rate*elapsed.Seconds() {
+			// 已达到速率，等待下一批
+			time.Sleep(time.Millisecond)
+			continue
+		}
+
+		if _, err := w.Write(line); err != nil {
+			return r, err
+		}
+		r.Latencies = append(r.Latencies, time.Since(now))
+		r.Lines++
+		r.Bytes += int64(len(line))
+	}
+	r.Elapsed = time.Since(start)
+	return r, nil
+}
+
+// percentile 返回写入耗时的 p 分位值，p 取值 0~1
+func (r *benchResult) percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	if !sort.SliceIsSorted(r.Latencies, func(i, j int) bool { return r.Latencies[i] < r.Latencies[j] }) {
+		sort.Slice(r.Latencies, func(i, j int) bool { return r.Latencies[i] < r.Latencies[j] })
+	}
+	i := int(float64(len(r.Latencies)-1) * p)
+	return r.Latencies[i]
+}
+
+// print 输出压测报告
+func (r *benchResult) print(w io.Writer) {
+	seconds := r.Elapsed.Seconds()
+	if seconds == 0 {
+		seconds = 1
+	}
+	fmt.Fprintf(w, "lines:      %d (%.0f lines/s)\n", r.Lines, float64(r.Lines)/seconds)
+	fmt.Fprintf(w, "bytes:      %s (%s/s)\n", rotatefile.Bytes(uint64(r.Bytes)), rotatefile.Bytes(uint64(float64(r.Bytes)/seconds)))
+	fmt.Fprintf(w, "latency:    p50 %s, p99 %s, max %s\n", r.percentile(0.5), r.percentile(0.99), r.percentile(1))
+	fmt.Fprintf(w, "rotations:  %d\n", r.Rotations)
+	fmt.Fprintf(w, "elapsed:    %s\n", r.Elapsed.Round(time.Millisecond))
+}
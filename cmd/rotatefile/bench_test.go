@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateValue(t *testing.T) {
+	for in, want := range map[string]float64{"50k/s": 50000, "1000": 1000, "2m/s": 2e6, "0": 0} {
+		var r rateValue
+		if err := r.Set(in); err != nil || float64(r) != want {
+			t.Fatalf("Set(%q) = %v, %v", in, float64(r), err)
+		}
+	}
+	var r rateValue
+	if err := r.Set("fast"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	r, err := runBench(io.Discard, 100, 1000, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 限速 1000 行每秒，100ms 内约 100 行
+	if r.Lines < 50 || r.Lines > 120 || r.Bytes != r.Lines*100 || len(r.Latencies) != int(r.Lines) {
+		t.Fatalf("unexpected result lines %d bytes %d", r.Lines, r.Bytes)
+	}
+
+	var out strings.Builder
+	r.print(&out)
+	for _, want := range []string{"lines/s", "p99", "rotations"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// timeLayouts cat 子命令 -since/-until 支持的时间格式
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// parseTime 按 timeLayouts 解析时间 s，不带时区的格式按 loc 解析，s 为空时返回零值
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected format like 2006-01-02 or 2006-01-02 15:04:05", s)
+}
+
+// parseInterspersed 解析 args，允许位置参数与命令行参数混合出现，例如 app.log -since 2024-01-01
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if args = fs.Args(); len(args) == 0 {
+			return positional, nil
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+}
+
+// cat 按时间顺序输出日志文件及其历史文件（压缩文件解压后输出），例如：
+// rotatefile cat /var/log/app/app.log -since 2024-01-01 -until 2024-01-03
+func cat(args []string) error {
+	fs := flag.NewFlagSet("rotatefile cat", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rotatefile cat app.log [-since 2024-01-01] [-until 2024-01-03] [flags]")
+		fs.PrintDefaults()
+	}
+
+	c := rotatefile.NewConfig()
+	configFlags(fs, &c)
+	sinceArg := fs.String("since", "", "只输出该时间之后滚动的历史文件及当前日志文件")
+	untilArg := fs.String("until", "", "只输出内容开始于该时间之前的文件")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	loc := time.Local
+	if c.UtcTime {
+		loc = time.UTC
+	}
+	since, err := parseTime(*sinceArg, loc)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(*untilArg, loc)
+	if err != nil {
+		return err
+	}
+	if len(*untilArg) == len("2006-01-02") { // 只有日期时包含当天
+		until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	r, err := rotatefile.Cat(files[0], since, until, rotatefile.WithConfig(c))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(os.Stdout, r)
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	since := fs.String("since", "", "")
+	files, err := parseInterspersed(fs, []string{"app.log", "-since", "2024-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "app.log" || *since != "2024-01-01" {
+		t.Fatalf("got files %q since %q", files, *since)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, s := range []string{"2024-01-02T03:04:05Z", "2024-01-02 03:04:05", "2024-01-02T03:04:05"} {
+		if got, err := parseTime(s, time.UTC); err != nil || !got.Equal(want) {
+			t.Fatalf("parseTime(%q) = %v, %v", s, got, err)
+		}
+	}
+	if got, err := parseTime("", time.UTC); err != nil || !got.IsZero() {
+		t.Fatalf("parseTime empty = %v, %v", got, err)
+	}
+	if _, err := parseTime("yesterday", time.UTC); err == nil {
+		t.Fatal("expected error")
+	}
+}
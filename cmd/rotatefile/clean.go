@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// clean 独立清理日志目录，例如：
+// rotatefile clean -dir /var/log/app -max-days 7 -total-size-cap 2G -dry-run
+func clean(args []string) error {
+	fs := flag.NewFlagSet("rotatefile clean", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rotatefile clean -dir /var/log/app [flags]")
+		fs.PrintDefaults()
+	}
+
+	c := rotatefile.NewConfig()
+	configFlags(fs, &c)
+	dir := fs.String("dir", "", "要清理的日志目录")
+	dryRun := fs.Bool("dry-run", false, "只打印将要删除的文件，不做任何修改")
+	fs.Parse(args)
+
+	if *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	removed, err := rotatefile.Clean(*dir, *dryRun, rotatefile.WithConfig(c))
+	for _, path := range removed {
+		if *dryRun {
+			fmt.Println("would remove", path)
+		} else {
+			fmt.Println("removed", path)
+		}
+	}
+	return err
+}
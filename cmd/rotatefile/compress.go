@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bingoohuang/rotatefile"
+	// 注册 zstd 压缩格式，各个子命令都能识别 .zst 历史文件
+	_ "github.com/bingoohuang/rotatefile/zstd"
+)
+
+// compress 手工压缩历史文件，或者将历史文件迁移为其它压缩格式，例如：
+// rotatefile compress /var/log/app -format zstd -level 6
+func compress(args []string) error {
+	fs := flag.NewFlagSet("rotatefile compress", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rotatefile compress <dir|file> [-format gzip|zip|zstd] [-level N] [-force] [flags]")
+		fs.PrintDefaults()
+	}
+
+	c := rotatefile.NewConfig()
+	configFlags(fs, &c)
+	fs.StringVar(&c.CompressFormat, "format", c.CompressFormat, "-compress-format 的简写，gzip、zip 或者 zstd")
+	fs.IntVar(&c.CompressLevel, "level", c.CompressLevel, "-compress-level 的简写，gzip、zip 为 1（最快）~ 9（最小），zstd 为 1 ~ 22")
+	force := fs.Bool("force", false, "已是目标格式的文件同样重新压缩，例如调整压缩级别")
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	maxLevel := 9
+	switch format := strings.ToLower(c.CompressFormat); format {
+	case "", "gzip", "zip":
+	case "zstd":
+		maxLevel = 22
+	default:
+		return fmt.Errorf("unsupported compress format %q, supported: gzip, zip, zstd", format)
+	}
+	if c.CompressLevel < 0 || c.CompressLevel > maxLevel {
+		return fmt.Errorf("invalid compress level %d, expected 1-%d", c.CompressLevel, maxLevel)
+	}
+
+	recompress := rotatefile.Recompress
+	if *force {
+		recompress = rotatefile.RecompressForce
+	}
+	var errs []error
+	for _, path := range paths {
+		compressed, err := recompress(path, rotatefile.WithConfig(c))
+		for _, name := range compressed {
+			fmt.Println("compressed", name)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// sizeValue 支持 100M、1G 等格式的字节大小参数
+type sizeValue uint64
+
+func (s *sizeValue) String() string { return fmt.Sprint(uint64(*s)) }
+
+func (s *sizeValue) Set(v string) error {
+	n, err := rotatefile.ParseBytes(v)
+	if err != nil {
+		return err
+	}
+	*s = sizeValue(n)
+	return nil
+}
+
+// signalsValue 以英文逗号分隔的信号名参数，例如 SIGHUP,SIGUSR1
+type signalsValue []os.Signal
+
+func (s *signalsValue) String() string {
+	var names []string
+	for _, sig := range *s {
+		names = append(names, fmt.Sprint(sig))
+	}
+	return strings.Join(names, ",")
+}
+
+func (s *signalsValue) Set(v string) error {
+	*s = rotatefile.ParseSignals(v)
+	return nil
+}
+
+// configFlags 为 Config 中每个可以序列化的字段注册命令行参数，参数名为 json 标签的短横线形式，
+// 例如 maxSize 为 -max-size，默认值为环境变量或者内置默认值，命令行参数优先
+func configFlags(fs *flag.FlagSet, c *rotatefile.Config) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name, usage := kebab(tag), "Config."+f.Name
+		switch p := v.Field(i).Addr().Interface().(type) {
+		case *string:
+			fs.StringVar(p, name, *p, usage)
+		case *bool:
+			fs.BoolVar(p, name, *p, usage)
+		case *int:
+			fs.IntVar(p, name, *p, usage)
+		case *time.Duration:
+			fs.DurationVar(p, name, *p, usage+"，例如 30s、24h")
+		case *uint64:
+			fs.Var((*sizeValue)(p), name, usage+"，例如 100M、1G")
+		}
+	}
+	fs.Var((*signalsValue)(&c.RotateSignals), "rotate-signals", "Config.RotateSignals，例如 SIGHUP,SIGUSR1")
+}
+
+// kebab 将驼峰形式转换为短横线形式，例如 maxSize 转换为 max-size
+func kebab(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
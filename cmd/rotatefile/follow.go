@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// follow 持续输出日志文件新写入的内容，类似 tail -F，文件被滚动（改名）或者重新创建后自动切换到新文件，例如：
+// rotatefile follow /var/log/app/app.log
+func follow(args []string) error {
+	fs := flag.NewFlagSet("rotatefile follow", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rotatefile follow app.log [flags]")
+		fs.PrintDefaults()
+	}
+	fromStart := fs.Bool("from-start", false, "从文件开头输出，默认只输出新写入的内容")
+	interval := fs.Duration("interval", 250*time.Millisecond, "检查新内容及滚动的间隔")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := followFile(ctx, os.Stdout, files[0], *fromStart, *interval); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// followFile 每隔 interval 将文件 name 新写入的内容写入 w，直到 ctx 结束，
+// 通过比较路径与已打开文件是否为同一文件（inode）发现滚动，此时先读完旧文件剩余内容，再从头读取新文件，
+// 文件被截断时从头读取
+func followFile(ctx context.Context, w io.Writer, name string, fromStart bool, interval time.Duration) error {
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	open := func(whence int) error {
+		nf, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		if _, err := nf.Seek(0, whence); err != nil {
+			nf.Close()
+			return err
+		}
+		if f != nil {
+			f.Close()
+		}
+		f = nf
+		return nil
+	}
+
+	whence := io.SeekEnd
+	if fromStart {
+		whence = io.SeekStart
+	}
+	if err := open(whence); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if f != nil {
+			if _, err := io.Copy(w, f); err != nil {
+				return err
+			}
+
+			cur, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			if info, err := os.Stat(name); err == nil && !os.SameFile(cur, info) {
+				// 已滚动，读完旧文件中滚动前写入的内容后切换
+				if _, err := io.Copy(w, f); err != nil {
+					return err
+				}
+				if err := open(io.SeekStart); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			} else if err == nil {
+				if offset, _ := f.Seek(0, io.SeekCurrent); info.Size() < offset {
+					// 被截断
+					if _, err := f.Seek(0, io.SeekStart); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+		} else if err := open(io.SeekStart); err != nil && !os.IsNotExist(err) {
+			return err
+		} else if err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
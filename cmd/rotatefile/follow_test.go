@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestFollowFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(name, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error)
+	go func() { done <- followFile(ctx, &out, name, false, 10*time.Millisecond) }()
+	time.Sleep(50 * time.Millisecond)
+
+	appendFile := func(name, s string) {
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	appendFile(name, "one\n")
+	time.Sleep(50 * time.Millisecond)
+	// 滚动：改名后旧文件仍有写入，随后创建新文件
+	if err := os.Rename(name, filepath.Join(dir, "app.20240101T000000.000.log")); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(filepath.Join(dir, "app.20240101T000000.000.log"), "two\n")
+	appendFile(name, "three\n")
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "one\ntwo\nthree\n" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixPrefix unix socket 输入源的前缀，例如 unix:/run/app/log.sock
+const unixPrefix = "unix:"
+
+// splitInput 解析输入源参数 {输入源}[={输出文件名}]，未指定输出文件名时返回空
+func splitInput(spec string) (src, name string) {
+	if i := strings.LastIndex(spec, "="); i > 0 {
+		return spec[:i], spec[i+1:]
+	}
+	return spec, ""
+}
+
+// inputName 根据输入源 src 生成输出文件名，例如 /run/app.fifo 为 app.log
+func inputName(src string) string {
+	if src == "-" {
+		return "stdin.log"
+	}
+	base := filepath.Base(strings.TrimPrefix(src, unixPrefix))
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ".log"
+}
+
+// runInputs 并发读取多个输入源，按行写入 open 按输出文件名返回的目标，直到所有输入源结束或者 ctx 结束，输入源可以是：
+//
+//	"-"                  标准输入
+//	unix:/path/to.sock   监听 unix socket，每个连接的内容按行写入
+//	/path/to.fifo        命名管道，写入方关闭后继续等待新的写入方
+//	/var/log/*.log       普通文件或者通配符，跟随文件新写入的内容（包括滚动及符号链接指向的变化），新出现的文件从开头读取
+//
+// 输入源后可以加上 ={输出文件名} 指定输出文件，否则根据输入源生成
+func runInputs(ctx context.Context, specs []string, open func(name string) io.Writer, fromStart bool, interval time.Duration) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(specs))
+	for i, spec := range specs {
+		wg.Add(1)
+		go func(i int, spec string) {
+			defer wg.Done()
+			if err := runInput(ctx, spec, open, fromStart, interval); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", spec, err)
+			}
+		}(i, spec)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// runInput 读取单个输入源 spec，见 runInputs
+func runInput(ctx context.Context, spec string, open func(name string) io.Writer, fromStart bool, interval time.Duration) error {
+	src, name := splitInput(spec)
+	output := func(src string) io.Writer {
+		if name != "" {
+			return open(name)
+		}
+		return open(inputName(src))
+	}
+
+	if src == "-" {
+		return copyLines(output(src), os.Stdin)
+	}
+	if strings.HasPrefix(src, unixPrefix) {
+		return serveUnix(ctx, strings.TrimPrefix(src, unixPrefix), output(src))
+	}
+	if info, err := os.Stat(src); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		return readFIFO(ctx, src, output(src))
+	}
+
+	return runSidecar(ctx, []string{src}, func(file string) io.Writer {
+		if name != "" {
+			return open(name)
+		}
+		return open(outputName([]string{src}, file))
+	}, fromStart, interval)
+}
+
+// serveUnix 监听 unix socket path，将每个连接的内容按行写入 w，直到 ctx 结束
+func serveUnix(ctx context.Context, path string, w io.Writer) error {
+	_ = os.Remove(path) // 上次退出时残留的 socket 文件
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stop()
+			defer conn.Close()
+			_ = copyLines(w, conn)
+		}()
+	}
+}
+
+// readFIFO 读取命名管道 path，按行写入 w，直到 ctx 结束，
+// 以读写方式打开，打开时无需等待写入方，写入方全部关闭后也不会读到 EOF
+func readFIFO(ctx context.Context, path string, w io.Writer) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { f.Close() })
+	defer stop()
+	defer f.Close()
+
+	if err := copyLines(w, f); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunInputs(t *testing.T) {
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "app.fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "log.sock")
+
+	var mu sync.Mutex
+	outs := map[string]*syncBuffer{}
+	open := func(name string) io.Writer {
+		mu.Lock()
+		defer mu.Unlock()
+		if outs[name] == nil {
+			outs[name] = &syncBuffer{}
+		}
+		return outs[name]
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runInputs(ctx, []string{fifo, "unix:" + sock + "=sock.log"}, open, false, 10*time.Millisecond)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 写入方关闭后，命名管道继续等待新的写入方
+	for _, s := range []string{"f1\n", "f2\n"} {
+		f, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+
+	for _, s := range []string{"s1\n", "s2\n"} {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte(s))
+		conn.Close()
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := outs["app.log"].String(); got != "f1\nf2\n" {
+		t.Fatalf("app.log got %q", got)
+	}
+	if got := outs["sock.log"].String(); got != "s1\ns2\n" {
+		t.Fatalf("sock.log got %q", got)
+	}
+}
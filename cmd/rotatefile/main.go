@@ -1,17 +1,14 @@
 package main
 
 import (
-	"flag"
-	"log"
+	"fmt"
 	"math/rand"
+	"os"
 	"time"
-
-	_ "github.com/bingoohuang/rotatefile/stdlog/autoload"
 )
 
-func main() {
-	flag.Bool("v", false, `
-通过环境变量设置：
+const envUsage = `
+通过环境变量设置（命令行参数优先）：
 
 | 序号 | 变量名                | 默认值                       | 含义              |
 |----|--------------------|---------------------------|-----------------|
@@ -25,14 +22,34 @@ func main() {
 | 8  | LOG_MIN_DISK_FREE  | 100M                      | 最少磁盘空余          |
 | 9  | LOG_UTCTIME        | 0                         | 是否使用 UTC 时间     |
 | 10 | LOG_COMPRESS       | 1                         | 是否启用gzip 压缩历史文件 |
-| 11 | LOG_PRINT_TERM     | 根据进程是否有终端                 | 同时在终端打印         |
 
-`)
-	flag.Parse()
+完整列表见 https://github.com/bingoohuang/rotatefile#环境变量
+`
+
+// commands 子命令，不指定子命令时为管道模式
+var commands = map[string]func(args []string) error{
+	"pipe":     pipe,
+	"clean":    clean,
+	"cat":      cat,
+	"follow":   follow,
+	"compress": compress,
+	"stats":    stats,
+	"verify":   verify,
+	"sidecar":  sidecar,
+	"bench":    bench,
+}
+
+func main() {
+	cmd, args := pipe, os.Args[1:]
+	if len(args) > 0 {
+		if c, ok := commands[args[0]]; ok {
+			cmd, args = c, args[1:]
+		}
+	}
 
-	for {
-		log.Printf("I! %s", RandStringBytesMaskImprSrc(1024))
-		time.Sleep(time.Second)
+	if err := cmd(args); err != nil {
+		fmt.Fprintln(os.Stderr, "rotatefile:", err)
+		os.Exit(1)
 	}
 }
 
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// pipe 从标准输入读取日志，按行写入滚动日志文件，类似 Apache rotatelogs，例如：
+// someapp | rotatefile -f /var/log/app/app.log -max-size 100M -compress
+func pipe(args []string) error {
+	fs := flag.NewFlagSet("rotatefile", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: someapp | rotatefile [flags]\n       rotatefile <%s> [flags]\n\n", "pipe|clean|cat|follow|compress|stats|verify|sidecar|bench")
+		fs.PrintDefaults()
+		fmt.Fprint(fs.Output(), envUsage)
+	}
+
+	c := rotatefile.NewConfig(rotatefile.WithPrintTerm(false), rotatefile.WithCloseOnExit(true),
+		rotatefile.WithStderrFallback(false))
+	configFlags(fs, &c)
+	fs.StringVar(&c.Filename, "f", c.Filename, "-filename 的简写，例如 /var/log/app/app.log")
+	tee := fs.Bool("tee", false, "同时输出到标准输出")
+	var inputs stringsValue
+	fs.Var(&inputs, "input", "输入源，默认为标准输入，可以是命名管道、unix:/path.sock、文件或者通配符，"+
+		"可以加上 ={输出文件名}，可以指定多次")
+	dir := fs.String("dir", "", "指定时每个输入源写入该目录下各自的滚动日志文件，共享 TotalSizeCap 额度，否则都写入 -filename")
+	interval := fs.Duration("interval", time.Second, "跟随文件时，检查新内容及新文件的间隔")
+	fs.Parse(args)
+
+	if len(inputs) == 0 && *dir == "" {
+		rf := rotatefile.New(rotatefile.WithConfig(c))
+		defer rf.Close()
+		return copyLines(teeWriter(rf, *tee), os.Stdin)
+	}
+
+	// 由 ctx 结束各个输入源后关闭日志文件
+	c.CloseOnExit = false
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(inputs) == 0 {
+		inputs = append(inputs, "-")
+	}
+	var open func(name string) io.Writer
+	if *dir != "" {
+		m := rotatefile.NewManager(*dir, rotatefile.WithConfig(c))
+		defer m.Close()
+		open = func(name string) io.Writer { return teeWriter(m.Open(name), *tee) }
+	} else {
+		rf := rotatefile.New(rotatefile.WithConfig(c))
+		defer rf.Close()
+		w := teeWriter(rf, *tee)
+		open = func(string) io.Writer { return w }
+	}
+	return runInputs(ctx, inputs, open, false, *interval)
+}
+
+// teeWriter tee 为 true 时同时输出到标准输出
+func teeWriter(w io.Writer, tee bool) io.Writer {
+	if tee {
+		return io.MultiWriter(w, os.Stdout)
+	}
+	return w
+}
+
+// copyLines 按行复制，每行一次写入，避免一行日志被滚动拆分到两个文件中
+func copyLines(w io.Writer, r io.Reader) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if _, werr := w.Write(line); werr != nil {
+				fmt.Fprintln(os.Stderr, "rotatefile:", werr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
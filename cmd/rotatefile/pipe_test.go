@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+type recorder struct{ writes []string }
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, string(p))
+	return len(p), nil
+}
+
+func TestCopyLines(t *testing.T) {
+	var r recorder
+	if err := copyLines(&r, strings.NewReader("one\ntwo\nthree")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one\n", "two\n", "three"}; strings.Join(r.writes, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", r.writes, want)
+	}
+}
+
+func TestConfigFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c := rotatefile.NewConfig()
+	configFlags(fs, &c)
+	err := fs.Parse([]string{"-max-size", "10M", "-utc-time", "-max-days", "7", "-sync-interval", "3s", "-compress-format", "zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxSize != 10000000 || !c.UtcTime || c.MaxDays != 7 || c.SyncInterval != 3*time.Second || c.CompressFormat != "zip" {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
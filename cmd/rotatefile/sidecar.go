@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// stringsValue 可重复指定的字符串参数
+type stringsValue []string
+
+func (s *stringsValue) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsValue) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// sidecar 监视一个或多个日志文件（支持通配符，例如容器的标准输出日志，以及命名管道、unix socket，见 runInputs），
+// 将新写入的内容复制到滚动日志文件中，
+// 所有日志文件共享 TotalSizeCap/MinDiskFree 额度，适合作为没有日志采集组件的 Pod 的滚动边车，例如：
+// rotatefile sidecar -watch '/var/log/containers/*.log' -dir /logs -max-size 50M -max-days 3 -total-size-cap 1G
+func sidecar(args []string) error {
+	fs := flag.NewFlagSet("rotatefile sidecar", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rotatefile sidecar -watch '/var/log/containers/*.log' -dir /logs [flags]")
+		fs.PrintDefaults()
+	}
+
+	c := rotatefile.NewConfig(rotatefile.WithPrintTerm(false))
+	configFlags(fs, &c)
+	var patterns stringsValue
+	fs.Var(&patterns, "watch", "要监视的日志文件，支持通配符、命名管道及 unix:/path.sock，可以指定多次")
+	dir := fs.String("dir", "", "滚动日志文件的输出目录")
+	fromStart := fs.Bool("from-start", false, "启动时已存在的文件从开头复制，默认只复制新写入的内容")
+	interval := fs.Duration("interval", time.Second, "检查新内容及新文件的间隔")
+	fs.Parse(args)
+
+	if len(patterns) == 0 || *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	m := rotatefile.NewManager(*dir, rotatefile.WithConfig(c))
+	defer m.Close()
+
+	open := func(name string) io.Writer { return m.Open(name) }
+	return runInputs(ctx, patterns, open, *fromStart, *interval)
+}
+
+// runSidecar 每隔 interval 按通配符 patterns 查找日志文件，跟随每个文件，将新写入的内容按行写入 open 返回的目标，
+// 启动后新出现的文件从开头复制，不再匹配的文件（例如已删除的容器日志）停止跟随，直到 ctx 结束
+func runSidecar(ctx context.Context, patterns []string, open func(src string) io.Writer, fromStart bool, interval time.Duration) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	tracked := map[string]context.CancelFunc{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for first := true; ; first = false {
+		matched := map[string]bool{}
+		for _, pattern := range patterns {
+			srcs, err := filepath.Glob(pattern)
+			if err != nil {
+				return err
+			}
+			for _, src := range srcs {
+				matched[src] = true
+			}
+		}
+
+		for src, cancel := range tracked {
+			if !matched[src] {
+				cancel()
+				delete(tracked, src)
+			}
+		}
+		for src := range matched {
+			if _, ok := tracked[src]; ok {
+				continue
+			}
+			fileCtx, cancel := context.WithCancel(ctx)
+			tracked[src] = cancel
+			wg.Add(1)
+			go func(src string, fromStart bool) {
+				defer wg.Done()
+				defer cancel()
+				if err := copyFollow(fileCtx, open(src), src, fromStart, interval); err != nil {
+					fmt.Fprintln(os.Stderr, "rotatefile:", src, err)
+				}
+			}(src, fromStart || !first)
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, cancel := range tracked {
+				cancel()
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// copyFollow 跟随文件 src，将新写入的内容按行写入 w，以免一行日志被滚动拆分到两个文件中
+func copyFollow(ctx context.Context, w io.Writer, src string, fromStart bool, interval time.Duration) error {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := copyLines(w, pr)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	err := followFile(ctx, pw, src, fromStart, interval)
+	pw.Close()
+	if copyErr := <-done; copyErr != nil && err == nil {
+		err = copyErr
+	}
+	if err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// outputName 根据源文件 src 相对于匹配的通配符中不含通配符部分的路径生成输出文件名，路径分隔符替换为 _，
+// 例如 /var/log/pods/*/*/*.log 匹配的 /var/log/pods/ns_pod_uid/app/0.log 输出为 ns_pod_uid_app_0.log
+func outputName(patterns []string, src string) string {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, src); !ok {
+			continue
+		}
+		base := pattern
+		for strings.ContainsAny(base, "*?[") {
+			base = filepath.Dir(base)
+		}
+		if rel, err := filepath.Rel(base, src); err == nil {
+			return strings.ReplaceAll(filepath.ToSlash(rel), "/", "_")
+		}
+	}
+	return filepath.Base(src)
+}
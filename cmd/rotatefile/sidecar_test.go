@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutputName(t *testing.T) {
+	patterns := []string{"/var/log/containers/*.log", "/var/log/pods/*/*/*.log"}
+	for src, want := range map[string]string{
+		"/var/log/containers/app.log":        "app.log",
+		"/var/log/pods/ns_pod_uid/app/0.log": "ns_pod_uid_app_0.log",
+		"/other/x.log":                       "x.log",
+	} {
+		if got := outputName(patterns, filepath.FromSlash(src)); got != want {
+			t.Fatalf("outputName(%q) = %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestRunSidecar(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.log")
+	if err := os.WriteFile(a, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	outs := map[string]*syncBuffer{}
+	open := func(src string) io.Writer {
+		mu.Lock()
+		defer mu.Unlock()
+		outs[filepath.Base(src)] = &syncBuffer{}
+		return outs[filepath.Base(src)]
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runSidecar(ctx, []string{filepath.Join(dir, "*.log")}, open, false, 10*time.Millisecond)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	appendFile := func(name, s string) {
+		f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+	appendFile(a, "a1\n")
+	// 启动后新出现的文件从开头复制
+	appendFile(filepath.Join(dir, "b.log"), "b1\nb2")
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := outs["a.log"].String(); got != "a1\n" {
+		t.Fatalf("a.log got %q", got)
+	}
+	if got := outs["b.log"].String(); got != "b1\nb2" {
+		t.Fatalf("b.log got %q", got)
+	}
+}
+
+func TestSplitInput(t *testing.T) {
+	for spec, want := range map[string][2]string{
+		"-":                      {"-", ""},
+		"/run/app.fifo=app.log":  {"/run/app.fifo", "app.log"},
+		"unix:/run/log.sock":     {"unix:/run/log.sock", ""},
+		"/var/log/*.log=all.log": {"/var/log/*.log", "all.log"},
+	} {
+		if src, name := splitInput(spec); src != want[0] || name != want[1] {
+			t.Fatalf("splitInput(%q) = %q, %q", spec, src, name)
+		}
+	}
+	if got := inputName("unix:/run/app.sock"); got != "app.log" {
+		t.Fatalf("inputName got %q", got)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// stats 统计日志目录中历史文件的个数、大小、时间范围、压缩率以及磁盘空余，例如：
+// rotatefile stats -dir /var/log/app -json
+func stats(args []string) error {
+	fs := flag.NewFlagSet("rotatefile stats", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rotatefile stats -dir /var/log/app [flags]")
+		fs.PrintDefaults()
+	}
+
+	c := rotatefile.NewConfig()
+	configFlags(fs, &c)
+	dir := fs.String("dir", "", "要统计的日志目录")
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出")
+	ratio := fs.Bool("ratio", true, "解压已压缩的历史文件以统计压缩率，目录较大时耗时较长")
+	fs.Parse(args)
+
+	if *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	s, err := rotatefile.InspectDir(*dir, *ratio, rotatefile.WithConfig(c))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	return printStats(os.Stdout, s)
+}
+
+// printStats 以表格形式输出统计结果
+func printStats(w io.Writer, s *rotatefile.DirStats) error {
+	fmt.Fprintf(w, "dir: %s, disk free: %s / %s", s.Dir, rotatefile.Bytes(s.DiskFree), rotatefile.Bytes(s.DiskTotal))
+	if s.DiskInodes > 0 {
+		fmt.Fprintf(w, ", inodes free: %d / %d", s.DiskFreeInodes, s.DiskInodes)
+	}
+	fmt.Fprint(w, "\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tBACKUPS\tBACKUPS SIZE\tCOMPRESSED\tRATIO\tOLDEST\tNEWEST")
+	for _, l := range s.Logs {
+		ratio := "-"
+		if r := l.Ratio(); r > 0 {
+			ratio = fmt.Sprintf("%.1f%%", r*100)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%s\t%s\t%s\n", l.Name, rotatefile.Bytes(uint64(l.Size)),
+			l.Backups, rotatefile.Bytes(uint64(l.BackupsSize)), l.Compressed, ratio,
+			l.Oldest.Format(time.DateTime), l.Newest.Format(time.DateTime))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+func TestPrintStats(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	var out strings.Builder
+	err := printStats(&out, &rotatefile.DirStats{Dir: "/var/log/app", Logs: []rotatefile.LogStats{{
+		Name: "app.log", Size: 100, Backups: 2, BackupsSize: 300, Compressed: 1,
+		CompressedSize: 100, OriginalSize: 400, Oldest: day, Newest: day,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"app.log", "100 B", "300 B", "25.0%", "2024-01-02 00:00:00"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in\n%s", want, out.String())
+		}
+	}
+}
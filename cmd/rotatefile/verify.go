@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// verify 校验日志目录中的历史文件，发现问题时以非零状态退出，适合在备份流程中使用，例如：
+// rotatefile verify -dir /var/log/app
+func verify(args []string) error {
+	fs := flag.NewFlagSet("rotatefile verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: rotatefile verify -dir /var/log/app [flags]")
+		fs.PrintDefaults()
+	}
+
+	c := rotatefile.NewConfig()
+	configFlags(fs, &c)
+	dir := fs.String("dir", "", "要校验的日志目录")
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出发现的问题")
+	fs.Parse(args)
+
+	if *dir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	problems, err := rotatefile.Verify(*dir, rotatefile.WithConfig(c))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if problems == nil {
+			problems = []rotatefile.Problem{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(problems); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s) found in %s", len(problems), *dir)
+	}
+	return nil
+}
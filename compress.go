@@ -0,0 +1,355 @@
+package rotatefile
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Compressor 历史文件压缩格式
+type Compressor interface {
+	// Suffix 压缩文件扩展名，例如 .gz
+	Suffix() string
+	// Compress 压缩 src 文件内容，写入 dst
+	Compress(dst io.Writer, src *os.File) error
+	// Decompress 打开压缩文件 src，返回解压后的内容
+	Decompress(src *os.File) (io.ReadCloser, error)
+}
+
+var (
+	// GzipCompressor gzip 压缩格式，扩展名 .gz
+	GzipCompressor Compressor = gzipCompressor{}
+	// ZipCompressor zip 压缩格式，扩展名 .zip，在 Windows 上无需额外工具即可打开
+	ZipCompressor Compressor = zipCompressor{}
+)
+
+// NewGzipCompressor 创建指定压缩级别的 gzip 压缩格式，level 为 1（最快）~ 9（最小），0 表示默认级别
+func NewGzipCompressor(level int) Compressor { return gzipCompressor{level: level} }
+
+// NewZipCompressor 创建指定压缩级别的 zip 压缩格式，level 含义同 NewGzipCompressor
+func NewZipCompressor(level int) Compressor { return zipCompressor{level: level} }
+
+// flateLevel 将压缩级别 level 转换为 flate 压缩级别，0 表示默认级别
+func flateLevel(level int) int {
+	if level == 0 {
+		return flate.DefaultCompression
+	}
+	return level
+}
+
+type gzipCompressor struct{ level int }
+
+func (gzipCompressor) Suffix() string { return compressSuffix }
+
+func (c gzipCompressor) Compress(dst io.Writer, src *os.File) error {
+	gz, err := gzip.NewWriterLevel(dst, flateLevel(c.level))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(gz, src); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (gzipCompressor) Decompress(src *os.File) (io.ReadCloser, error) {
+	return gzip.NewReader(src)
+}
+
+type zipCompressor struct{ level int }
+
+func (zipCompressor) Suffix() string { return ".zip" }
+
+func (c zipCompressor) Compress(dst io.Writer, src *os.File) error {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Method = zip.Deflate
+
+	zw := zip.NewWriter(dst)
+	if c.level != 0 {
+		zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, c.level)
+		})
+	}
+	w, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (zipCompressor) Decompress(src *os.File) (io.ReadCloser, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(src, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	if len(zr.File) == 0 {
+		return nil, errors.New("empty zip file")
+	}
+	return zr.File[0].Open()
+}
+
+var (
+	formatsMu sync.RWMutex
+	// formats 通过 RegisterCompressFormat 注册的压缩格式，键为小写的格式名称
+	formats = map[string]func(level int) Compressor{}
+	// formatCompressors 注册的压缩格式（默认压缩级别），用于识别其历史文件
+	formatCompressors []Compressor
+)
+
+// RegisterCompressFormat 注册名为 name 的压缩格式，newCompressor 按压缩级别（CompressLevel）创建压缩格式，
+// 注册后可以通过 CompressFormat（LOG_COMPRESS_FORMAT）选用，并且所有日志文件都能识别该格式的历史文件，
+// 一起按保留策略清理、读取，例如导入 zstd 包时注册 zstd 格式
+func RegisterCompressFormat(name string, newCompressor func(level int) Compressor) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	name = strings.ToLower(name)
+	if _, ok := formats[name]; !ok {
+		formatCompressors = append(formatCompressors, newCompressor(0))
+	}
+	formats[name] = newCompressor
+}
+
+// registeredFormat 返回注册的名为 name 的压缩格式
+func registeredFormat(name string) (func(level int) Compressor, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	f, ok := formats[strings.ToLower(name)]
+	return f, ok
+}
+
+// checkCompressFormat 检查配置的压缩格式，不支持的格式返回错误，而不是改用 gzip 压缩
+func (c *Config) checkCompressFormat() error {
+	if c.Compressor != nil {
+		return nil
+	}
+	switch strings.ToLower(c.CompressFormat) {
+	case "", "gzip", "zip":
+		return nil
+	}
+	if _, ok := registeredFormat(c.CompressFormat); ok {
+		return nil
+	}
+	return fmt.Errorf("rotatefile: unknown compress format %q", c.CompressFormat)
+}
+
+// compressor 返回配置的压缩格式，默认 gzip，CompressFormat 已由 checkCompressFormat 检查
+func (l *file) compressor() Compressor {
+	if l.Compressor != nil {
+		return l.Compressor
+	}
+	switch strings.ToLower(l.CompressFormat) {
+	case "", "gzip":
+	case "zip":
+		return zipCompressor{level: l.CompressLevel}
+	default:
+		if f, ok := registeredFormat(l.CompressFormat); ok {
+			return f(l.CompressLevel)
+		}
+	}
+	if l.CompressConcurrency > 1 {
+		c := NewParallelGzipCompressor(l.CompressConcurrency, 0).(parallelGzipCompressor)
+		c.level = l.CompressLevel
+		return c
+	}
+	return gzipCompressor{level: l.CompressLevel}
+}
+
+// compressors 返回可识别的压缩格式，包括配置的压缩格式、内置的 gzip、zip 格式，以及注册的压缩格式
+func (l *file) compressors() []Compressor {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	cs := make([]Compressor, 0, 3+len(formatCompressors))
+	if l.Compressor != nil {
+		cs = append(cs, l.Compressor)
+	}
+	cs = append(cs, GzipCompressor, ZipCompressor)
+	return append(cs, formatCompressors...)
+}
+
+// compressorOf 根据扩展名返回文件 name 的压缩格式，未压缩时返回 nil
+func (l *file) compressorOf(name string) Compressor {
+	for _, c := range l.compressors() {
+		if strings.HasSuffix(name, c.Suffix()) {
+			return c
+		}
+	}
+	return nil
+}
+
+// trimCompressSuffix 去掉文件名 name 中的压缩扩展名
+func (l *file) trimCompressSuffix(name string) string {
+	if c := l.compressorOf(name); c != nil {
+		return name[:len(name)-len(c.Suffix())]
+	}
+	return name
+}
+
+// uniqueBackups 同一个历史文件的压缩文件与源文件同时存在时（保留源文件，或者正在压缩），只保留源文件
+func (l *file) uniqueBackups(files []logInfo) []logInfo {
+	names := make(map[string]bool, len(files))
+	for _, f := range files {
+		names[f.Name] = true
+	}
+
+	var unique []logInfo
+	for _, f := range files {
+		if l.compressorOf(f.Name) != nil && names[l.trimCompressSuffix(f.Name)] {
+			continue
+		}
+		unique = append(unique, f)
+	}
+	return unique
+}
+
+// hasCompressed 判断历史文件 name 是否已有压缩文件
+func (l *file) hasCompressed(name string, names map[string]bool) bool {
+	for _, c := range l.compressors() {
+		if names[name+c.Suffix()] {
+			return true
+		}
+	}
+	return false
+}
+
+// openBackup 打开日志文件，压缩文件返回解压后的内容
+func (l *file) openBackup(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := l.compressorOf(name)
+	if c == nil {
+		return f, nil
+	}
+
+	r, err := c.Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &backupReader{ReadCloser: r, f: f}, nil
+}
+
+// backupReader 关闭解压内容的同时，关闭历史文件
+type backupReader struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r *backupReader) Close() error {
+	err := r.ReadCloser.Close()
+	if errClose := r.f.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+// defaultCompressBlockSize 并行 gzip 压缩时，每个分块的大小
+const defaultCompressBlockSize = 1 * MB
+
+// NewParallelGzipCompressor 创建并行 gzip 压缩格式，文件按 blockSize 分块，由 concurrency 个 goroutine 并行压缩，
+// 各分块压缩为独立的 gzip member 后按顺序拼接，输出依然是标准的 .gz 文件，
+// 以免大文件滚动后压缩长时间占满单个 CPU 核，阻塞后续的清理工作
+func NewParallelGzipCompressor(concurrency, blockSize int) Compressor {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	if blockSize <= 0 {
+		blockSize = defaultCompressBlockSize
+	}
+	return parallelGzipCompressor{concurrency: concurrency, blockSize: blockSize}
+}
+
+type parallelGzipCompressor struct {
+	gzipCompressor
+	concurrency int
+	blockSize   int
+}
+
+// gzipBlock 一个分块的压缩结果
+type gzipBlock struct {
+	buf  bytes.Buffer
+	err  error
+	done chan struct{}
+}
+
+func (c parallelGzipCompressor) Compress(dst io.Writer, src *os.File) error {
+	// 队列容量限制了同时在内存中的分块数
+	queue := make(chan *gzipBlock, c.concurrency)
+	writeErr := make(chan error, 1)
+	go func() {
+		var err error
+		for b := range queue {
+			<-b.done
+			if err == nil {
+				err = b.err
+			}
+			if err == nil {
+				_, err = dst.Write(b.buf.Bytes())
+			}
+		}
+		writeErr <- err
+	}()
+
+	var readErr error
+	for blocks := 0; ; blocks++ {
+		data := make([]byte, c.blockSize)
+		n, err := io.ReadFull(src, data)
+		if err == io.EOF && blocks > 0 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			readErr = err
+			break
+		}
+
+		b := &gzipBlock{done: make(chan struct{})}
+		queue <- b
+		go func(data []byte) {
+			defer close(b.done)
+			gz, err := gzip.NewWriterLevel(&b.buf, flateLevel(c.level))
+			if b.err = err; err != nil {
+				return
+			}
+			if _, b.err = gz.Write(data); b.err == nil {
+				b.err = gz.Close()
+			}
+		}(data[:n])
+
+		if err != nil { // 最后一个分块，或者空文件
+			break
+		}
+	}
+	close(queue)
+
+	if err := <-writeErr; err != nil {
+		return err
+	}
+	return readErr
+}
@@ -0,0 +1,159 @@
+package rotatefile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// 压缩队列满时的积压处理策略
+const (
+	// CompressBacklogSkip 暂不压缩，留待下次清理时重试
+	CompressBacklogSkip = "skip"
+	// CompressBacklogDelay 等待队列空闲
+	CompressBacklogDelay = "delay"
+	// CompressBacklogDeleteOldest 删除来不及压缩的最旧的历史文件
+	CompressBacklogDeleteOldest = "delete-oldest"
+)
+
+// defaultCompressQueueSize 压缩队列的默认容量
+const defaultCompressQueueSize = 16
+
+// compressFiles 压缩历史文件 files（按时间从新到旧排序），
+// 未开启压缩工作池时，在当前 goroutine 中依次压缩，否则分派到工作池中异步压缩
+func (l *file) compressFiles(files []logInfo) error {
+	if l.clean.isDryRun() {
+		for _, f := range files {
+			l.clean.plan(ActionCompress, filepath.Join(l.dir(), f.Name), ReasonCompress)
+		}
+		return nil
+	}
+	if l.CompressWorkers <= 0 || l.SyncMill {
+		var err error
+		for _, f := range files {
+			if l.isCompressing(f.Name) {
+				continue
+			}
+			if errCompress := l.compressFile(f.Name); err == nil && errCompress != nil {
+				err = errCompress
+			}
+		}
+		return err
+	}
+
+	l.startCompressWorkers()
+	for i, f := range files {
+		if !l.markCompressing(f.Name) { // 已经在队列中
+			continue
+		}
+
+		if l.CompressBacklog == CompressBacklogDelay {
+			l.compressCh <- f.Name
+			continue
+		}
+
+		select {
+		case l.compressCh <- f.Name:
+			continue
+		default:
+		}
+
+		l.unmarkCompressing(f.Name)
+		if l.CompressBacklog != CompressBacklogDeleteOldest {
+			return nil
+		}
+
+		var err error
+		for _, g := range files[i:] {
+			if l.isCompressing(g.Name) {
+				continue
+			}
+			if errRemove := l.removeBackup(g.Name, ReasonCompressBacklog); err == nil && errRemove != nil {
+				err = errRemove
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// compressFile 压缩历史文件 name（相对于日志目录的路径）
+func (l *file) compressFile(name string) error {
+	fn := filepath.Join(l.dir(), name)
+	if err := l.unprotectBackup(fn); err != nil {
+		return err
+	}
+
+	c := l.compressor()
+	if err := l.compressLogFile(fn, fn+c.Suffix(), c); err != nil {
+		return err
+	}
+	if l.CompressKeepSource {
+		l.protectBackup(fn)
+	}
+	l.protectBackup(fn + c.Suffix())
+	l.emit(Event{Type: Compressed, Path: fn + c.Suffix()})
+	l.backupDone(fn + c.Suffix())
+	return nil
+}
+
+// startCompressWorkers 启动压缩工作池
+func (l *file) startCompressWorkers() {
+	l.compressOnce.Do(func() {
+		size := l.CompressQueueSize
+		if size <= 0 {
+			size = defaultCompressQueueSize
+		}
+		l.compressCh = make(chan string, size)
+		for i := 0; i < l.CompressWorkers; i++ {
+			go l.compressWorker()
+		}
+	})
+}
+
+func (l *file) compressWorker() {
+	for name := range l.compressCh {
+		// 可能已被清理，此时忽略错误
+		if err := l.compressFile(name); err != nil {
+			if _, errStat := os.Stat(filepath.Join(l.dir(), name)); errStat == nil {
+				l.millError(err)
+			}
+		}
+		l.unmarkCompressing(name)
+		l.notifyBackups()
+
+		// 队列清空后，重新清理一次，以便更新清单及总大小限制
+		if len(l.compressCh) == 0 {
+			l.mill()
+		}
+	}
+}
+
+// markCompressing 标记历史文件 name 正在压缩，已经标记时返回 false
+func (l *file) markCompressing(name string) bool {
+	l.compressMu.Lock()
+	defer l.compressMu.Unlock()
+
+	if l.compressing[name] {
+		return false
+	}
+	if l.compressing == nil {
+		l.compressing = make(map[string]bool)
+	}
+	l.compressing[name] = true
+	return true
+}
+
+func (l *file) unmarkCompressing(name string) {
+	l.compressMu.Lock()
+	defer l.compressMu.Unlock()
+
+	delete(l.compressing, name)
+}
+
+func (l *file) isCompressing(name string) bool {
+	l.compressMu.Lock()
+	defer l.compressMu.Unlock()
+
+	return l.compressing[name]
+}
@@ -1,9 +1,12 @@
 package rotatefile
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/bingoohuang/rotatefile/homedir"
 	"golang.org/x/term"
@@ -20,19 +23,85 @@ const (
 	defaultMaxSize   = 100 * 1024 * 1024
 )
 
+// NewConfig 创建配置，未通过 fns 指定的字段取环境变量或者默认值
+func NewConfig(fns ...ConfigFn) Config {
+	return createConfig(fns...)
+}
+
 func createConfig(fns ...ConfigFn) Config {
 	c := Config{
-		AppName:       Env("LOG_APPNAME", filepath.Base(os.Args[0])),
-		Filename:      Env("LOG_FILENAME", ""),
-		RotateSignals: EnvSignals("LOG_ROTATE_SIGNALS", []os.Signal{syscall.SIGHUP}),
-		MaxSize:       EnvSize("LOG_MAX_SIZE", 100*MB),
-		MaxDays:       EnvInt("LOG_MAX_DAYS", 30),
-		MaxBackups:    EnvInt("LOG_MAX_BACKUPS", 0),
-		TotalSizeCap:  EnvSize("LOG_TOTAL_SIZE_CAP", GB),
-		MinDiskFree:   EnvSize("LOG_MIN_DISK_FREE", 100*MB),
-		UtcTime:       EnvBool("LOG_UTCTIME", false),
-		Compress:      EnvBool("LOG_COMPRESS", true),
-		PrintTerm:     EnvBool("LOG_PRINT_TERM", IsTerminal),
+		AppName:              Env("LOG_APPNAME", filepath.Base(os.Args[0])),
+		Filename:             Env("LOG_FILENAME", ""),
+		LogDir:               Env("LOG_DIR", ""),
+		DirCandidates:        EnvList("LOG_DIR_CANDIDATES", nil),
+		StderrFallback:       EnvBool("LOG_STDERR_FALLBACK", true),
+		RotateSignals:        EnvSignals("LOG_ROTATE_SIGNALS", []os.Signal{syscall.SIGHUP}),
+		RotateTrigger:        Env("LOG_ROTATE_TRIGGER", ""),
+		CtlSocket:            Env("LOG_CTL_SOCKET", ""),
+		AsyncWrite:           EnvBool("LOG_ASYNC_WRITE", false),
+		NonBlocking:          EnvBool("LOG_NON_BLOCKING", false),
+		CloseOnExit:          EnvBool("LOG_CLOSE_ON_EXIT", false),
+		FailoverAfter:        EnvInt("LOG_FAILOVER_AFTER", 0),
+		FailoverProbe:        EnvDuration("LOG_FAILOVER_PROBE", 0),
+		WriteRetries:         EnvInt("LOG_WRITE_RETRIES", 0),
+		WriteRetryBackoff:    EnvDuration("LOG_WRITE_RETRY_BACKOFF", 0),
+		Preallocate:          EnvBool("LOG_PREALLOCATE", false),
+		DropPageCache:        EnvBool("LOG_DROP_PAGE_CACHE", false),
+		SyncPolicy:           Env("LOG_SYNC_POLICY", SyncNone),
+		SyncInterval:         EnvDuration("LOG_SYNC_INTERVAL", 0),
+		WriteTimeout:         EnvDuration("LOG_WRITE_TIMEOUT", 0),
+		FallbackDir:          Env("LOG_FALLBACK_DIR", ""),
+		AsyncQueueSize:       EnvInt("LOG_ASYNC_QUEUE_SIZE", 0),
+		MaxSize:              EnvSize("LOG_MAX_SIZE", 100*MB),
+		MaxDays:              EnvInt("LOG_MAX_DAYS", 30),
+		MaxAge:               EnvDuration("LOG_MAX_AGE", 0),
+		CalendarDays:         EnvBool("LOG_CALENDAR_DAYS", false),
+		RetentionZone:        Env("LOG_RETENTION_ZONE", ""),
+		MaxBackups:           EnvInt("LOG_MAX_BACKUPS", 0),
+		MaxCompressedBackups: EnvInt("LOG_MAX_COMPRESSED_BACKUPS", 0),
+		MaxUncompressedSize:  EnvSize("LOG_MAX_UNCOMPRESSED_SIZE", 0),
+		TotalSizeCap:         EnvSize("LOG_TOTAL_SIZE_CAP", GB),
+		TotalSizeCapDir:      EnvBool("LOG_TOTAL_SIZE_CAP_DIR", false),
+		AccurateSizeCap:      EnvBool("LOG_ACCURATE_SIZE_CAP", false),
+		MinDiskFree:          EnvSize("LOG_MIN_DISK_FREE", 100*MB),
+		MinFreeInodes:        uint64(max(EnvInt("LOG_MIN_FREE_INODES", 0), 0)),
+		MaxDiskUsage:         EnvInt("LOG_MAX_DISK_USAGE", 0),
+		CleanupStrategy:      Env("LOG_CLEANUP_STRATEGY", CleanupOldest),
+		MinBackupAge:         EnvDuration("LOG_MIN_BACKUP_AGE", 0),
+		ArchiveEmergencyFree: EnvSize("LOG_ARCHIVE_EMERGENCY_FREE", 0),
+		MillDryRun:           EnvBool("LOG_MILL_DRY_RUN", false),
+		PruneEmptyBackups:    EnvBool("LOG_PRUNE_EMPTY_BACKUPS", false),
+		VerifyCompressed:     EnvBool("LOG_VERIFY_COMPRESSED", false),
+		TrashDir:             Env("LOG_TRASH_DIR", ""),
+		TrashMaxAge:          EnvDuration("LOG_TRASH_MAX_AGE", 24*time.Hour),
+		UtcTime:              EnvBool("LOG_UTCTIME", false),
+		Compress:             EnvBool("LOG_COMPRESS", true),
+		CompressFormat:       Env("LOG_COMPRESS_FORMAT", "gzip"),
+		CompressLevel:        EnvInt("LOG_COMPRESS_LEVEL", 0),
+		SyncMill:             EnvBool("LOG_SYNC_MILL", false),
+		CompressKeepSource:   EnvBool("LOG_COMPRESS_KEEP_SOURCE", false),
+		CompressOnClose:      EnvBool("LOG_COMPRESS_ON_CLOSE", false),
+		ReadOnlyBackups:      EnvBool("LOG_READONLY_BACKUPS", false),
+		Owner:                Env("LOG_OWNER", ""),
+		Group:                Env("LOG_GROUP", ""),
+		DisableChown:         EnvBool("LOG_DISABLE_CHOWN", false),
+		DisableLock:          EnvBool("LOG_DISABLE_LOCK", false),
+		LockDir:              Env("LOG_LOCK_DIR", ""),
+		ImmutableBackups:     EnvBool("LOG_IMMUTABLE_BACKUPS", false),
+		CompressConcurrency:  EnvInt("LOG_COMPRESS_CONCURRENCY", 0),
+		CompressWorkers:      EnvInt("LOG_COMPRESS_WORKERS", 0),
+		CompressQueueSize:    EnvInt("LOG_COMPRESS_QUEUE_SIZE", 0),
+		CompressBacklog:      Env("LOG_COMPRESS_BACKLOG", CompressBacklogSkip),
+		PrintTerm:            EnvBool("LOG_PRINT_TERM", IsTerminal),
+		TermWriter:           envTermWriter("LOG_TERM_WRITER"),
+		RateLimit:            EnvInt("LOG_RATE_LIMIT", 0),
+		RateBurst:            EnvInt("LOG_RATE_BURST", 0),
+		Sanitize:             EnvBool("LOG_SANITIZE", false),
+		TailBufferSize:       EnvSize("LOG_TAIL_BUFFER_SIZE", 0),
+		Manifest:             EnvBool("LOG_MANIFEST", false),
+		LumberjackCompat:     EnvBool("LOG_LUMBERJACK_COMPAT", false),
+		BackupSubdirLayout:   Env("LOG_BACKUP_SUBDIR_LAYOUT", ""),
+		DailyBundle:          EnvBool("LOG_DAILY_BUNDLE", false),
 	}
 
 	for _, f := range fns {
@@ -42,6 +111,17 @@ func createConfig(fns ...ConfigFn) Config {
 	return c
 }
 
+// envTermWriter 解析环境变量设置的终端输出目标，支持 stdout/stderr
+func envTermWriter(envName string) io.Writer {
+	switch strings.ToLower(os.Getenv(envName)) {
+	case "stderr":
+		return os.Stderr
+	case "stdout":
+		return os.Stdout
+	}
+	return nil
+}
+
 // IsTerminal tell is if it is on a terminal.
 var IsTerminal = term.IsTerminal(1)
 
@@ -51,13 +131,99 @@ type Config struct {
 	AppName string `json:"appName" yaml:"appName"`
 	// Filename is the file to write logs to.  Backup log files will be retained
 	// in the same directory.  It uses <processname>.log in os.TempDir() if empty.
+	// 可以使用占位符 {hostname}、{pod}、{pid}，例如 /nfs/logs/app-{pod}.log
+	// 文件名中可以带日期模式 %Y、%m、%d、%H，例如 app-%Y%m%d.log，日期变化后切换到新文件，之前的文件作为历史文件
 	Filename string `json:"filename" yaml:"filename"`
-	// Prefix 是日志基本文件名前缀，在 Filename 不指定的情况下，可以使用本字段给自动生成的日志文件名添加此前缀
+	// Prefix 是日志基本文件名前缀，在 Filename 不指定的情况下，可以使用本字段给自动生成的日志文件名添加此前缀，同样支持占位符
 	Prefix string `json:"prefix" yaml:"prefix"`
+	// LogDir 日志目录，Filename 未指定目录时优先使用，不可写时再依次尝试 DirCandidates
+	LogDir string `json:"logDir" yaml:"logDir"`
+	// DirCandidates 替换默认依次尝试的日志目录（见 FindLogDir），都不可写时写入返回 ErrNoLogDir
+	DirCandidates []string `json:"dirCandidates" yaml:"dirCandidates"`
+	// StderrFallback 没有可写的日志目录时，是否改为写入标准错误，而不是写入返回 ErrNoLogDir，默认开启
+	StderrFallback bool `json:"stderrFallback" yaml:"stderrFallback"`
 
 	// RotateSignals 设置滚动日志的信号
 	RotateSignals []os.Signal `json:"-" yaml:"-"`
 
+	// RotateTrigger 滚动触发文件，该文件出现时强制滚动并删除该文件，相对路径相对于日志目录，
+	// 用于不支持信号的平台，Windows 上默认为 {日志文件名}.rotate
+	RotateTrigger string `json:"rotateTrigger" yaml:"rotateTrigger"`
+
+	// CtlSocket 控制通道的 unix socket 路径，相对路径相对于日志目录，为空时不开启，
+	// 接受 rotate、flush、stats、level（stdlog 注册）等命令
+	CtlSocket string `json:"ctlSocket" yaml:"ctlSocket"`
+
+	// AsyncWrite 是否异步写入，写入内容放入队列，由唯一的写入协程写入文件，
+	// 以免高并发时所有日志协程争用同一把锁，写入错误不再返回给调用方，Flush/Close 时等待队列写完
+	AsyncWrite bool `json:"asyncWrite" yaml:"asyncWrite"`
+
+	// AsyncQueueSize 异步写入队列长度，默认 4096，队列满时写入阻塞等待
+	AsyncQueueSize int `json:"asyncQueueSize" yaml:"asyncQueueSize"`
+
+	// NonBlocking 是否非阻塞写入，写入需要等待（异步写入队列已满，或者磁盘繁忙，写入耗时超过 50ms）时，
+	// 立即返回 ErrWouldBlock 并计入 Stats 的 Dropped，定期在日志中记录丢弃的次数
+	NonBlocking bool `json:"nonBlocking" yaml:"nonBlocking"`
+
+	// CloseOnExit 收到 SIGTERM/SIGINT 时，关闭（刷盘）所有已打开的日志文件后再退出，避免丢失最后的日志，
+	// 应用自己 signal.Notify 了该信号时，不退出，由应用处理（会再收到一次该信号）
+	CloseOnExit bool `json:"closeOnExit" yaml:"closeOnExit"`
+
+	// Failover 备用写入目标（例如另一块磁盘或者 tmpfs 上的日志文件），连续 FailoverAfter 次写入失败后切换，
+	// 切换期间每隔 FailoverProbe 尝试写入主目标，成功后切换回来
+	Failover RotateFile `json:"-" yaml:"-"`
+
+	// FailoverAfter 连续写入失败多少次后切换到备用目标，默认 3
+	FailoverAfter int `json:"failoverAfter" yaml:"failoverAfter"`
+
+	// FailoverProbe 切换到备用目标后，探测主目标是否恢复的间隔，默认 30s
+	FailoverProbe time.Duration `json:"failoverProbe" yaml:"failoverProbe"`
+
+	// WriteRetries 遇到 EINTR/EAGAIN/ENOSPC 等临时错误时的重试次数，默认 0 不重试
+	// ENOSPC 时会先清理历史文件再重试
+	WriteRetries int `json:"writeRetries" yaml:"writeRetries"`
+
+	// WriteRetryBackoff 首次重试前的等待时间，之后每次重试翻倍，默认 10ms
+	WriteRetryBackoff time.Duration `json:"writeRetryBackoff" yaml:"writeRetryBackoff"`
+
+	// OnWriteError 写入最终失败（重试之后）时的回调
+	OnWriteError func(err error) `json:"-" yaml:"-"`
+
+	// OnError 后台清理（压缩、删除、总大小控制等）失败时的回调，例如历史文件没有删除权限
+	OnError func(err error) `json:"-" yaml:"-"`
+
+	// OnEvent 内部事件（打开、滚动、压缩、删除、磁盘不足、写入失败、清理失败）的回调，
+	// 在产生事件的协程中同步回调，不应长时间阻塞
+	OnEvent func(e Event) `json:"-" yaml:"-"`
+
+	// OnBackup 历史文件完成时的回调，参数为历史文件路径，启用压缩时在压缩完成后回调，
+	// 在清理协程中按完成顺序回调，不阻塞写入（SyncMill 时在写入中同步回调），
+	// 可用于通知下游处理已经完成的历史文件，例如发送到 Kafka
+	OnBackup func(path string) `json:"-" yaml:"-"`
+
+	// Preallocate 是否在创建日志文件时预分配 MaxSize 的磁盘空间（仅 Linux，不改变文件大小），
+	// 以减少碎片以及写到一半时磁盘空间不足，关闭时释放未使用的空间
+	Preallocate bool `json:"preallocate" yaml:"preallocate"`
+
+	// DropPageCache 是否在滚动、压缩完成后，释放已完成文件的页缓存（仅 Linux，posix_fadvise DONTNEED）
+	DropPageCache bool `json:"dropPageCache" yaml:"dropPageCache"`
+
+	// SyncPolicy 刷盘策略，none（默认）、interval、write、dsync 或者 level
+	SyncPolicy string `json:"syncPolicy" yaml:"syncPolicy"`
+
+	// SyncInterval interval 策略的刷盘间隔，默认 1s
+	SyncInterval time.Duration `json:"syncInterval" yaml:"syncInterval"`
+
+	// SyncFilter level 策略下，返回 true 的写入后刷盘，例如 stdlog.WithSyncLevel(stdlog.ErrorLevel)
+	SyncFilter func(p []byte) bool `json:"-" yaml:"-"`
+
+	// WriteTimeout 单次写入文件的超时时间，超时后返回 ErrWriteTimeout 并切换到 FallbackDir，
+	// 以免挂起的 NFS/fuse 等文件系统永久阻塞所有日志协程，0 表示不限制
+	WriteTimeout time.Duration `json:"writeTimeout" yaml:"writeTimeout"`
+
+	// FallbackDir 写入超时后切换的备用目录，默认为系统临时目录，挂起的写入返回后切换回原来的日志目录
+	FallbackDir string `json:"fallbackDir" yaml:"fallbackDir"`
+
 	// MaxSize is the maximum size of the log file before it gets
 	// rotated. It defaults to 100 megabytes.
 	MaxSize uint64 `json:"maxSize" yaml:"maxSize"`
@@ -69,20 +235,65 @@ type Config struct {
 	// based on age.
 	MaxDays int `json:"maxDays" yaml:"maxDays"`
 
+	// MaxAge is the maximum duration to retain old log files based on the
+	// timestamp encoded in their filename, e.g. 36h or 90m, for short retention
+	// use cases.  If both MaxDays and MaxAge are set, the stricter one wins.
+	MaxAge time.Duration `json:"maxAge" yaml:"maxAge"`
+
+	// CalendarDays 是否按日历天计算 MaxDays，例如 7 表示保留 RetentionZone 时区中
+	// 今天及之前 6 个自然日的历史文件，而不是 7×24 小时之内的历史文件
+	CalendarDays bool `json:"calendarDays" yaml:"calendarDays"`
+
+	// RetentionZone 按日历天计算 MaxDays 时使用的时区，例如 Asia/Shanghai，默认本地时区
+	RetentionZone string `json:"retentionZone" yaml:"retentionZone"`
+
 	// MaxBackups is the maximum number of old log files to retain.  The default
 	// is to retain all old log files (though MaxDays may still cause them to get
 	// deleted.)
 	MaxBackups int `json:"maxBackups" yaml:"maxBackups"`
 
+	// MaxCompressedBackups 最多保留的压缩历史文件个数，0 不限制
+	MaxCompressedBackups int `json:"maxCompressedBackups" yaml:"maxCompressedBackups"`
+
+	// MaxUncompressedSize 开启压缩时，最近的历史文件累计大小在此范围内的，保持不压缩，
+	// 以便其它程序直接读取，更早的历史文件才压缩，0 表示全部压缩
+	MaxUncompressedSize uint64 `json:"maxUncompressedSize" yaml:"maxUncompressedSize"`
+
 	// TotalSizeCap 控制所有文件累积总大小
 	// 如果超过该大小，则从最早的文件开始删除，直到删除到当前文件为止
 	// 当前日志文件大小可以超过 TotalSizeCap
 	// 0 不控制
 	TotalSizeCap uint64 `json:"totalSizeCap" yaml:"totalSizeCap"`
 
+	// AccurateSizeCap TotalSizeCap 以当前日志文件在磁盘上的实际大小统计（而不是本实例写入的大小），
+	// 并包括同一日志文件其它实例（锁冲突时文件名带进程号，例如 app.1234.log）的日志文件及历史文件，
+	// 超过时其它实例的历史文件也按顺序删除，适用于多个进程写入同一日志目录
+	AccurateSizeCap bool `json:"accurateSizeCap" yaml:"accurateSizeCap"`
+
+	// TotalSizeCapDir TotalSizeCap 统计日志目录下所有 rotatefile 管理的文件（通过清单文件或历史文件名识别），
+	// 适用于多个应用共享的日志分区（例如 /var/log/apps），注意：其它应用的历史文件也可能被删除
+	TotalSizeCapDir bool `json:"totalSizeCapDir" yaml:"totalSizeCapDir"`
+
 	// MinDiskFree 日志文件所在磁盘分区最少空余
 	MinDiskFree uint64 `json:"minDiskFree" yaml:"minDiskFree"`
 
+	// MinFreeInodes 日志文件所在磁盘分区最少空余 inode 数，低于时从最早的历史文件开始删除，0 不控制，
+	// 小分区上按小时滚动并压缩时，inode 可能先于空间耗尽；没有固定 inode 数的文件系统（例如 NTFS、btrfs）不控制
+	MinFreeInodes uint64 `json:"minFreeInodes" yaml:"minFreeInodes"`
+
+	// MaxDiskUsage 日志文件所在磁盘分区使用率上限（百分比，例如 90），0 不控制，
+	// 后台定期检查使用率，超过时立即触发清理，从最早的历史文件开始删除，直到使用率低于该值，
+	// 以免两次滚动之间，其它程序写满磁盘
+	MaxDiskUsage int `json:"maxDiskUsage" yaml:"maxDiskUsage"`
+
+	// CleanupStrategy 超过 TotalSizeCap、MinDiskFree 等额度时，历史文件的删除顺序，
+	// oldest（默认，从最早的开始）、largest（从最大的开始）或者 uncompressed（先删除未压缩的）
+	CleanupStrategy string `json:"cleanupStrategy" yaml:"cleanupStrategy"`
+
+	// MinBackupAge 超过 TotalSizeCap、MinDiskFree 等额度时，不删除滚动时间距今不足该时长的历史文件（例如 10m），
+	// 以免删除外部日志采集程序尚未上传的文件，0 不保护
+	MinBackupAge time.Duration `json:"minBackupAge" yaml:"minBackupAge"`
+
 	// UtcTime determines if the time used for formatting the timestamps in
 	// backup files is the computer's local time.
 	// The default is not to use UTC time.
@@ -93,8 +304,135 @@ type Config struct {
 	// The default is to perform compression.
 	Compress bool `json:"compress" yaml:"compress"`
 
+	// CompressFormat 压缩格式，gzip（默认）、zip，或者通过 RegisterCompressFormat 注册的格式
+	// （例如导入 zstd 包后的 zstd），不支持的格式在首次写入（或者 Open）时返回错误
+	CompressFormat string `json:"compressFormat" yaml:"compressFormat"`
+
+	// CompressLevel 压缩级别，gzip/zip 为 1（最快）~ 9（最小），zstd 为 1 ~ 22，0 表示默认级别
+	CompressLevel int `json:"compressLevel" yaml:"compressLevel"`
+
+	// CompressKeepSource 压缩后是否保留未压缩的源文件，以便其它程序读取，
+	// 源文件与其压缩文件视为同一个历史文件，一起按 MaxDays/MaxAge/MaxBackups 清理
+	CompressKeepSource bool `json:"compressKeepSource" yaml:"compressKeepSource"`
+
+	// CompressOnClose 关闭时，是否将当前日志文件滚动并压缩，适用于短时运行的批处理任务
+	CompressOnClose bool `json:"compressOnClose" yaml:"compressOnClose"`
+
+	// ReadOnlyBackups 是否将已完成的历史文件设置为只读（0440），以防篡改
+	ReadOnlyBackups bool `json:"readOnlyBackups" yaml:"readOnlyBackups"`
+
+	// ImmutableBackups 是否将已完成的历史文件设置为只读，并设置不可修改属性（chattr +i），
+	// 仅 Linux 支持，需要 root（CAP_LINUX_IMMUTABLE）权限，清理时自动清除该属性
+	ImmutableBackups bool `json:"immutableBackups" yaml:"immutableBackups"`
+
+	// Owner 日志文件及历史文件的属主，用户名或者 uid，为空时（仅 Linux）沿用原日志文件的属主
+	Owner string `json:"owner" yaml:"owner"`
+
+	// Group 日志文件及历史文件的属组，组名或者 gid
+	Group string `json:"group" yaml:"group"`
+
+	// DisableChown 是否禁止设置日志文件的属主
+	DisableChown bool `json:"disableChown" yaml:"disableChown"`
+	// DisableLock 是否禁止通过锁文件仲裁日志文件名，禁止后多个进程可能写入同一个日志文件
+	DisableLock bool `json:"disableLock" yaml:"disableLock"`
+	// LockDir 锁文件所在目录（例如 /var/lock），为空时锁文件 {日志文件名}.lock 位于日志目录中，
+	// 以免日志采集、备份脚本误处理锁文件
+	LockDir string `json:"lockDir" yaml:"lockDir"`
+
+	// CompressConcurrency gzip 并行压缩的 goroutine 数，大于 1 时启用分块并行压缩
+	CompressConcurrency int `json:"compressConcurrency" yaml:"compressConcurrency"`
+
+	// CompressWorkers 压缩工作池的 goroutine 数，大于 0 时异步压缩，0 表示在清理 goroutine 中依次压缩
+	CompressWorkers int `json:"compressWorkers" yaml:"compressWorkers"`
+
+	// CompressQueueSize 压缩队列容量，默认 16
+	CompressQueueSize int `json:"compressQueueSize" yaml:"compressQueueSize"`
+
+	// CompressBacklog 压缩队列满时的处理策略，skip（默认，下次重试）、delay（等待）或者 delete-oldest（删除最旧的）
+	CompressBacklog string `json:"compressBacklog" yaml:"compressBacklog"`
+
+	// Compressor 自定义压缩格式，优先于 CompressFormat
+	Compressor Compressor `json:"-" yaml:"-"`
+
+	// Archiver 历史文件的归档（上传）程序，压缩后（不压缩时滚动后）调用，归档状态记录在清单文件中，
+	// 尚未归档成功的历史文件，即使过期、超过个数或者总大小，也不删除
+	Archiver Archiver `json:"-" yaml:"-"`
+
+	// ArchiveEmergencyFree 磁盘空余低于该值时，允许删除尚未归档的历史文件，以免写满磁盘，0 表示始终不删除
+	ArchiveEmergencyFree uint64 `json:"archiveEmergencyFree" yaml:"archiveEmergencyFree"`
+
+	// MillDryRun 清理只预演，不压缩、删除任何文件，将要执行的操作及原因以 Planned 事件报告，
+	// 用于在生产环境中验证保留策略，也可以调用 PlanCleanup 直接取得
+	MillDryRun bool `json:"millDryRun" yaml:"millDryRun"`
+
+	// PruneEmptyBackups 清理时删除大小为 0 的历史文件
+	PruneEmptyBackups bool `json:"pruneEmptyBackups" yaml:"pruneEmptyBackups"`
+
+	// VerifyCompressed 清理时校验压缩的历史文件能否完整解压（本进程内每个文件校验一次），
+	// 损坏的移入 TrashDir，未配置时移入日志目录下的 corrupt 子目录
+	VerifyCompressed bool `json:"verifyCompressed" yaml:"verifyCompressed"`
+
+	// TrashDir 隔离目录，过期或者超过个数的历史文件移入该目录，而不是直接删除，以便保留策略配置错误时恢复，
+	// 相对路径相对于日志目录，保留历史文件所在的归档子目录，与日志目录不在同一文件系统时复制后删除，
+	// 为空时直接删除；超过 TotalSizeCap、MinDiskFree 等额度时仍直接删除
+	TrashDir string `json:"trashDir" yaml:"trashDir"`
+
+	// TrashMaxAge 隔离目录中的文件移入超过该时长后删除，默认 24h，0 不删除
+	TrashMaxAge time.Duration `json:"trashMaxAge" yaml:"trashMaxAge"`
+
+	// Clock 时钟，默认为系统时钟，用于在测试中控制历史文件名中的时间戳及按天滚动、按时间清理
+	Clock Clock `json:"-" yaml:"-"`
+
+	// SyncMill 是否在 Rotate/Write 中同步执行压缩、清理，而不是在清理协程中异步执行，
+	// 便于测试中无需等待即可检查历史文件，同时忽略 CompressWorkers
+	SyncMill bool `json:"syncMill" yaml:"syncMill"`
+
 	// PrintTerm 是否同时在终端上输出，只有在终端可用时输出
 	PrintTerm bool `json:"printTerm" yaml:"printTerm"`
+
+	// TermWriter 终端输出目标，默认 os.Stdout
+	TermWriter io.Writer `json:"-" yaml:"-"`
+
+	// TermFilter 终端输出过滤函数，返回 false 的内容不在终端输出，默认全部输出
+	TermFilter func(p []byte) bool `json:"-" yaml:"-"`
+
+	// Tee 同时复制写入内容的其它输出目标，例如网络连接、内存缓冲、测试用 buffer 等
+	Tee []io.Writer `json:"-" yaml:"-"`
+
+	// RateLimit 每秒最多写入行数（一次 Write 计为一行），超出的写入被丢弃并计数
+	// 0 不限速
+	RateLimit int `json:"rateLimit" yaml:"rateLimit"`
+
+	// RateBurst 限速允许的突发行数，默认与 RateLimit 相同
+	RateBurst int `json:"rateBurst" yaml:"rateBurst"`
+
+	// Sanitize 写入文件前，是否去除 ANSI 转义序列（颜色等）及不可打印控制字符
+	// 终端输出（PrintTerm）保持原样
+	Sanitize bool `json:"sanitize" yaml:"sanitize"`
+
+	// TailBufferSize 内存中保留最近写入内容的字节数，可通过 TailBuffer 获取
+	// 0 不保留
+	TailBufferSize uint64 `json:"tailBufferSize" yaml:"tailBufferSize"`
+
+	// Manifest 是否在日志目录中维护历史文件清单 {日志文件名去掉扩展名}.manifest.json
+	// 记录每个历史文件的名称、起止时间、大小、校验和及压缩状态，每次清理后更新，
+	// 清理及搜索时，清单中记录的历史文件即使文件名未被识别也参与清理，搜索按记录的起止时间跳过无关文件
+	Manifest bool `json:"manifest" yaml:"manifest"`
+
+	// BackupMatchers 识别其它命名规则的历史文件，使其参与清理及总大小控制
+	BackupMatchers []BackupMatcher `json:"-" yaml:"-"`
+
+	// LumberjackCompat 是否同时识别 lumberjack 风格的历史文件 name-2006-01-02T15-04-05.000.ext，
+	// 以便从 lumberjack 迁移后，遗留的历史文件也能被正常清理
+	LumberjackCompat bool `json:"lumberjackCompat" yaml:"lumberjackCompat"`
+
+	// BackupSubdirLayout 历史文件按滚动时间归档到子目录的时间格式，例如 2006/01/02 表示按天归档，
+	// 以便滚动频繁时，日志目录保持简洁，空表示不归档
+	BackupSubdirLayout string `json:"backupSubdirLayout" yaml:"backupSubdirLayout"`
+
+	// DailyBundle 是否将已经结束的日期的历史文件，按天打包压缩为一个 {name}-20060102.tar.gz 文件，
+	// 以减少滚动频繁时的文件（inode）数量
+	DailyBundle bool `json:"dailyBundle" yaml:"dailyBundle"`
 }
 
 // ConfigFn 选项模式函数
@@ -106,24 +444,151 @@ func WithConfig(v Config) ConfigFn { return func(c *Config) { *c = v } }
 // WithPrintTerm 指定是否同时打印到控制台
 func WithPrintTerm(v bool) ConfigFn { return func(c *Config) { c.PrintTerm = v } }
 
+// WithTermWriter 指定终端输出目标，例如 os.Stderr
+func WithTermWriter(v io.Writer) ConfigFn { return func(c *Config) { c.TermWriter = v } }
+
+// WithTermFilter 指定终端输出过滤函数
+func WithTermFilter(v func(p []byte) bool) ConfigFn { return func(c *Config) { c.TermFilter = v } }
+
+// WithTee 指定同时复制写入内容的其它输出目标
+func WithTee(w ...io.Writer) ConfigFn { return func(c *Config) { c.Tee = append(c.Tee, w...) } }
+
 // WithCompress 指定是否开启压缩
 func WithCompress(v bool) ConfigFn { return func(c *Config) { c.Compress = v } }
 
+// WithCompressFormat 指定压缩格式，gzip、zip，或者通过 RegisterCompressFormat 注册的格式
+func WithCompressFormat(v string) ConfigFn { return func(c *Config) { c.CompressFormat = v } }
+
+// WithCompressLevel 指定压缩级别
+func WithCompressLevel(v int) ConfigFn { return func(c *Config) { c.CompressLevel = v } }
+
+// WithArchiver 指定历史文件的归档程序
+func WithArchiver(v Archiver) ConfigFn { return func(c *Config) { c.Archiver = v } }
+
+// WithArchiveEmergencyFree 指定允许删除尚未归档的历史文件的磁盘空余下限
+func WithArchiveEmergencyFree(v uint64) ConfigFn {
+	return func(c *Config) { c.ArchiveEmergencyFree = v }
+}
+
+// WithMillDryRun 指定清理只预演，不做任何修改
+func WithMillDryRun(v bool) ConfigFn { return func(c *Config) { c.MillDryRun = v } }
+
+// WithPruneEmptyBackups 指定清理时删除空的历史文件
+func WithPruneEmptyBackups(v bool) ConfigFn { return func(c *Config) { c.PruneEmptyBackups = v } }
+
+// WithVerifyCompressed 指定清理时校验压缩的历史文件，隔离损坏的文件
+func WithVerifyCompressed(v bool) ConfigFn { return func(c *Config) { c.VerifyCompressed = v } }
+
+// WithTrashDir 指定隔离目录，maxAge 为隔离目录中文件的保留时长
+func WithTrashDir(dir string, maxAge time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.TrashDir = dir
+		c.TrashMaxAge = maxAge
+	}
+}
+
+// WithClock 指定时钟
+func WithClock(v Clock) ConfigFn { return func(c *Config) { c.Clock = v } }
+
+// WithSyncMill 指定是否同步执行压缩、清理
+func WithSyncMill(v bool) ConfigFn { return func(c *Config) { c.SyncMill = v } }
+
+// WithCompressKeepSource 指定压缩后是否保留未压缩的源文件
+func WithCompressKeepSource(v bool) ConfigFn { return func(c *Config) { c.CompressKeepSource = v } }
+
+// WithCompressOnClose 指定关闭时是否将当前日志文件滚动并压缩
+func WithCompressOnClose(v bool) ConfigFn { return func(c *Config) { c.CompressOnClose = v } }
+
+// WithReadOnlyBackups 指定是否将已完成的历史文件设置为只读
+func WithReadOnlyBackups(v bool) ConfigFn { return func(c *Config) { c.ReadOnlyBackups = v } }
+
+// WithImmutableBackups 指定是否将已完成的历史文件设置为不可修改
+func WithImmutableBackups(v bool) ConfigFn { return func(c *Config) { c.ImmutableBackups = v } }
+
+// WithOwner 指定日志文件的属主及属组，用户名/组名或者数字 id
+func WithOwner(owner, group string) ConfigFn {
+	return func(c *Config) {
+		c.Owner = owner
+		c.Group = group
+	}
+}
+
+// WithDisableChown 指定是否禁止设置日志文件的属主
+func WithDisableChown(v bool) ConfigFn { return func(c *Config) { c.DisableChown = v } }
+
+// WithDisableLock 指定是否禁止通过锁文件仲裁日志文件名
+func WithDisableLock(v bool) ConfigFn { return func(c *Config) { c.DisableLock = v } }
+
+// WithLockDir 指定锁文件所在目录
+func WithLockDir(v string) ConfigFn { return func(c *Config) { c.LockDir = v } }
+
+// WithCompressConcurrency 指定 gzip 并行压缩的 goroutine 数
+func WithCompressConcurrency(v int) ConfigFn { return func(c *Config) { c.CompressConcurrency = v } }
+
+// WithCompressWorkers 指定压缩工作池的 goroutine 数及队列容量
+func WithCompressWorkers(workers, queueSize int) ConfigFn {
+	return func(c *Config) {
+		c.CompressWorkers = workers
+		c.CompressQueueSize = queueSize
+	}
+}
+
+// WithCompressBacklog 指定压缩队列满时的处理策略
+func WithCompressBacklog(v string) ConfigFn { return func(c *Config) { c.CompressBacklog = v } }
+
+// WithCompressor 指定自定义压缩格式
+func WithCompressor(v Compressor) ConfigFn { return func(c *Config) { c.Compressor = v } }
+
 // WithUtcTime 指定是否使用 UTC 时间
 func WithUtcTime(v bool) ConfigFn { return func(c *Config) { c.UtcTime = v } }
 
 // WithMinDiskFree 指定最小磁盘可用大小
 func WithMinDiskFree(v uint64) ConfigFn { return func(c *Config) { c.MinDiskFree = v } }
 
+// WithMinFreeInodes 指定最少磁盘空余 inode 数
+func WithMinFreeInodes(v uint64) ConfigFn { return func(c *Config) { c.MinFreeInodes = v } }
+
+// WithMaxDiskUsage 指定磁盘使用率上限（百分比）
+func WithMaxDiskUsage(v int) ConfigFn { return func(c *Config) { c.MaxDiskUsage = v } }
+
+// WithCleanupStrategy 指定超过额度时历史文件的删除顺序
+func WithCleanupStrategy(v string) ConfigFn { return func(c *Config) { c.CleanupStrategy = v } }
+
+// WithMinBackupAge 指定超过额度时不删除的历史文件的最短时长
+func WithMinBackupAge(v time.Duration) ConfigFn { return func(c *Config) { c.MinBackupAge = v } }
+
+// WithAccurateSizeCap 指定 TotalSizeCap 按磁盘实际大小统计，并包括同一日志文件其它实例的文件
+func WithAccurateSizeCap(v bool) ConfigFn { return func(c *Config) { c.AccurateSizeCap = v } }
+
 // WithTotalSizeCap 指定日志总和大小上限
 func WithTotalSizeCap(v uint64) ConfigFn { return func(c *Config) { c.TotalSizeCap = v } }
 
+// WithTotalSizeCapDir 指定 TotalSizeCap 统计日志目录下所有 rotatefile 管理的文件
+func WithTotalSizeCapDir(v bool) ConfigFn { return func(c *Config) { c.TotalSizeCapDir = v } }
+
 // WithMaxBackups 指定最大备份文件数量
 func WithMaxBackups(v int) ConfigFn { return func(c *Config) { c.MaxBackups = v } }
 
+// WithMaxCompressedBackups 指定最多保留的压缩历史文件个数
+func WithMaxCompressedBackups(v int) ConfigFn { return func(c *Config) { c.MaxCompressedBackups = v } }
+
+// WithMaxUncompressedSize 指定最近保持不压缩的历史文件累计大小
+func WithMaxUncompressedSize(v uint64) ConfigFn { return func(c *Config) { c.MaxUncompressedSize = v } }
+
 // WithMaxDays 指定最大保存天数
 func WithMaxDays(v int) ConfigFn { return func(c *Config) { c.MaxDays = v } }
 
+// WithMaxAge 指定历史文件最长保存时长
+func WithMaxAge(v time.Duration) ConfigFn { return func(c *Config) { c.MaxAge = v } }
+
+// WithCalendarDays 指定按日历天计算 MaxDays，zone 为使用的时区，空表示本地时区
+func WithCalendarDays(zone string) ConfigFn {
+	return func(c *Config) {
+		c.CalendarDays = true
+		c.RetentionZone = zone
+	}
+}
+
 // WithMaxSize 指定日志文件最大大小
 func WithMaxSize(v uint64) ConfigFn { return func(c *Config) { c.MaxSize = v } }
 
@@ -145,6 +610,27 @@ func WithFilename(v string) ConfigFn {
 	}
 }
 
+// WithLogDir 指定日志目录
+func WithLogDir(v string) ConfigFn {
+	return func(c *Config) {
+		c.LogDir = v
+	}
+}
+
+// WithDirCandidates 指定依次尝试的日志目录，替换默认的查找顺序
+func WithDirCandidates(v ...string) ConfigFn {
+	return func(c *Config) {
+		c.DirCandidates = v
+	}
+}
+
+// WithStderrFallback 指定没有可写的日志目录时，是否改为写入标准错误
+func WithStderrFallback(v bool) ConfigFn {
+	return func(c *Config) {
+		c.StderrFallback = v
+	}
+}
+
 // WithPrefix 指定日志基本文件名前缀，在 Filename 不指定的情况下，可以使用本字段给自动生成的日志文件名添加此前缀
 func WithPrefix(v string) ConfigFn {
 	return func(c *Config) {
@@ -154,3 +640,109 @@ func WithPrefix(v string) ConfigFn {
 
 // WithRotateSignals 指定强制滚动信号
 func WithRotateSignals(v ...os.Signal) ConfigFn { return func(c *Config) { c.RotateSignals = v } }
+
+// WithRotateTrigger 指定滚动触发文件
+func WithRotateTrigger(v string) ConfigFn { return func(c *Config) { c.RotateTrigger = v } }
+
+// WithCtlSocket 指定控制通道的 unix socket 路径
+func WithCtlSocket(v string) ConfigFn { return func(c *Config) { c.CtlSocket = v } }
+
+// WithPreallocate 指定是否在创建日志文件时预分配磁盘空间
+func WithPreallocate(v bool) ConfigFn { return func(c *Config) { c.Preallocate = v } }
+
+// WithDropPageCache 指定是否释放已完成文件的页缓存
+func WithDropPageCache(v bool) ConfigFn { return func(c *Config) { c.DropPageCache = v } }
+
+// WithSyncPolicy 指定刷盘策略，interval 策略时 interval 为刷盘间隔
+func WithSyncPolicy(policy string, interval time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.SyncPolicy = policy
+		c.SyncInterval = interval
+	}
+}
+
+// WithSyncFilter 指定 level 刷盘策略，f 返回 true 的写入后刷盘
+func WithSyncFilter(f func(p []byte) bool) ConfigFn {
+	return func(c *Config) {
+		c.SyncPolicy = SyncLevel
+		c.SyncFilter = f
+	}
+}
+
+// WithWriteTimeout 指定写入超时时间，以及超时后切换的备用目录
+func WithWriteTimeout(timeout time.Duration, fallbackDir string) ConfigFn {
+	return func(c *Config) {
+		c.WriteTimeout = timeout
+		c.FallbackDir = fallbackDir
+	}
+}
+
+// WithNonBlocking 指定是否非阻塞写入
+func WithNonBlocking(v bool) ConfigFn { return func(c *Config) { c.NonBlocking = v } }
+
+// WithWriteRetry 指定临时写入错误的重试次数和首次退避时间
+func WithWriteRetry(retries int, backoff time.Duration) ConfigFn {
+	return func(c *Config) {
+		c.WriteRetries = retries
+		c.WriteRetryBackoff = backoff
+	}
+}
+
+// WithOnWriteError 指定写入最终失败时的回调
+func WithOnWriteError(f func(err error)) ConfigFn { return func(c *Config) { c.OnWriteError = f } }
+
+// WithOnError 指定后台清理失败时的回调
+func WithOnError(f func(err error)) ConfigFn { return func(c *Config) { c.OnError = f } }
+
+// WithOnEvent 指定内部事件的回调
+func WithOnEvent(f func(e Event)) ConfigFn { return func(c *Config) { c.OnEvent = f } }
+
+// WithCloseOnExit 指定收到 SIGTERM/SIGINT 时，关闭所有已打开的日志文件后再退出
+func WithCloseOnExit(v bool) ConfigFn { return func(c *Config) { c.CloseOnExit = v } }
+
+// WithOnBackup 指定历史文件完成时的回调
+func WithOnBackup(f func(path string)) ConfigFn { return func(c *Config) { c.OnBackup = f } }
+
+// WithFailover 指定备用写入目标
+func WithFailover(other RotateFile) ConfigFn { return func(c *Config) { c.Failover = other } }
+
+// WithAsyncWrite 指定异步写入及队列长度
+func WithAsyncWrite(queueSize int) ConfigFn {
+	return func(c *Config) {
+		c.AsyncWrite = true
+		c.AsyncQueueSize = queueSize
+	}
+}
+
+// WithRateLimit 指定每秒最多写入行数及允许的突发行数
+func WithRateLimit(linesPerSec, burst int) ConfigFn {
+	return func(c *Config) {
+		c.RateLimit = linesPerSec
+		c.RateBurst = burst
+	}
+}
+
+// WithSanitize 指定写入文件前是否去除 ANSI 转义序列及控制字符
+func WithSanitize(v bool) ConfigFn { return func(c *Config) { c.Sanitize = v } }
+
+// WithTailBufferSize 指定内存中保留最近写入内容的字节数
+func WithTailBufferSize(v uint64) ConfigFn { return func(c *Config) { c.TailBufferSize = v } }
+
+// WithManifest 指定是否维护历史文件清单
+func WithManifest(v bool) ConfigFn { return func(c *Config) { c.Manifest = v } }
+
+// WithBackupMatcher 指定识别其它命名规则历史文件的匹配器
+func WithBackupMatcher(m ...BackupMatcher) ConfigFn {
+	return func(c *Config) {
+		c.BackupMatchers = append(c.BackupMatchers, m...)
+	}
+}
+
+// WithLumberjackCompat 指定是否识别 lumberjack 风格的历史文件
+func WithLumberjackCompat(v bool) ConfigFn { return func(c *Config) { c.LumberjackCompat = v } }
+
+// WithBackupSubdirLayout 指定历史文件按滚动时间归档到子目录的时间格式，例如 2006/01/02
+func WithBackupSubdirLayout(v string) ConfigFn { return func(c *Config) { c.BackupSubdirLayout = v } }
+
+// WithDailyBundle 指定是否将历史文件按天打包压缩
+func WithDailyBundle(v bool) ConfigFn { return func(c *Config) { c.DailyBundle = v } }
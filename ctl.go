@@ -0,0 +1,163 @@
+package rotatefile
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// CtlCommand 控制通道命令，args 为命令参数，返回的内容作为应答
+type CtlCommand func(rf RotateFile, args []string) (string, error)
+
+var (
+	ctlCommandsMu sync.RWMutex
+	ctlCommands   = map[string]CtlCommand{
+		"rotate": func(rf RotateFile, args []string) (string, error) {
+			if len(args) > 0 { // rotate deploy-v1.2.3
+				return "", rf.RotateWithSuffix(args[0])
+			}
+			return "", rf.Rotate()
+		},
+		"flush": func(rf RotateFile, _ []string) (string, error) { return "", rf.Flush() },
+		"stats": func(rf RotateFile, _ []string) (string, error) {
+			s, err := json.Marshal(rf.Stats())
+			return string(s), err
+		},
+		"plan": func(rf RotateFile, _ []string) (string, error) {
+			actions, err := rf.PlanCleanup()
+			if err != nil {
+				return "", err
+			}
+			s, err := json.Marshal(actions)
+			return string(s), err
+		},
+	}
+)
+
+// RegisterCtlCommand 注册控制通道命令，例如 stdlog 注册的 level 命令，同名命令将被覆盖
+func RegisterCtlCommand(name string, cmd CtlCommand) {
+	ctlCommandsMu.Lock()
+	defer ctlCommandsMu.Unlock()
+
+	ctlCommands[strings.ToLower(name)] = cmd
+}
+
+func lookupCtlCommand(name string) CtlCommand {
+	ctlCommandsMu.RLock()
+	defer ctlCommandsMu.RUnlock()
+
+	return ctlCommands[strings.ToLower(name)]
+}
+
+// ctlSocketPath 返回控制通道的 unix socket 路径，相对路径相对于日志目录
+func (l *file) ctlSocketPath() string {
+	if l.CtlSocket == "" || filepath.IsAbs(l.CtlSocket) {
+		return l.CtlSocket
+	}
+	return filepath.Join(l.dir(), l.CtlSocket)
+}
+
+// listenCtl 监听控制通道，每行一个命令，例如 rotate、flush、stats、plan、level debug，
+// 应答一行，成功时以 ok 开头，失败时以 error 开头
+// 用于容器等无法按库投递信号的场景，例如 echo rotate | nc -U app.ctl.sock
+func (l *file) listenCtl() {
+	path := l.ctlSocketPath()
+	if path == "" {
+		return
+	}
+
+	ln, err := listenPrivate(path)
+	if err != nil {
+		debugf("listen %s: %v", path, err)
+		return
+	}
+
+	l.ctlMu.Lock()
+	l.ctlListener = ln
+	l.ctlPath = path
+	l.ctlMu.Unlock()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go l.serveCtl(conn)
+		}
+	}()
+}
+
+// listenPrivate 在 path 上监听 unix socket。控制通道可以轮转日志、修改日志级别等，只允许当前用户访问，
+// 因此先在同目录下权限为 0700 的临时目录中创建 socket 并修改权限为 0600，再移动到 path，
+// 避免 socket 创建之后、修改权限之前被其他用户连接
+func listenPrivate(path string) (net.Listener, error) {
+	tmp, err := os.MkdirTemp(filepath.Dir(path), ".ctl-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	tmpPath := filepath.Join(tmp, "sock")
+	ln, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	// socket 文件移动到 path 后由 closeCtl 删除
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, 0o600); err != nil && runtime.GOOS != "windows" {
+		ln.Close()
+		return nil, err
+	}
+	// 覆盖上次运行残留的 socket 文件
+	if err := os.Rename(tmpPath, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// closeCtl 关闭控制通道，并删除 socket 文件
+func (l *file) closeCtl() {
+	l.ctlMu.Lock()
+	defer l.ctlMu.Unlock()
+
+	if l.ctlListener != nil {
+		l.ctlListener.Close()
+		_ = os.Remove(l.ctlPath)
+		l.ctlListener = nil
+		l.ctlPath = ""
+	}
+}
+
+func (l *file) serveCtl(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var reply string
+		if cmd := lookupCtlCommand(fields[0]); cmd == nil {
+			reply = fmt.Sprintf("error unknown command %s", fields[0])
+		} else if result, err := cmd(l, fields[1:]); err != nil {
+			reply = "error " + err.Error()
+		} else {
+			reply = strings.TrimSpace("ok " + result)
+		}
+
+		if _, err := fmt.Fprintln(conn, reply); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rotatefile
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// datePatternTokens 活动文件名中支持的日期模式，例如 app-%Y%m%d.log
+var datePatternTokens = []struct {
+	token  string
+	layout string
+}{
+	{token: "%Y", layout: "2006"},
+	{token: "%m", layout: "01"},
+	{token: "%d", layout: "02"},
+	{token: "%H", layout: "15"},
+}
+
+// hasDatePattern 判断文件名中是否包含日期模式
+func hasDatePattern(name string) bool {
+	for _, p := range datePatternTokens {
+		if strings.Contains(name, p.token) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDatePattern 将文件名 pattern 中的日期模式替换为时间 t 对应的值
+func formatDatePattern(pattern string, t time.Time) string {
+	oldnew := make([]string, 0, 2*len(datePatternTokens))
+	for _, p := range datePatternTokens {
+		oldnew = append(oldnew, p.token, t.Format(p.layout))
+	}
+	return strings.NewReplacer(oldnew...).Replace(pattern)
+}
+
+// datePatternMatcher 识别活动文件名带日期模式时，之前日期的日志文件（例如 app-20240101.log），
+// 以及这些文件按大小滚动出的历史文件（例如 app-20240101.20240101T101500.000.log），均可以是压缩的
+type datePatternMatcher struct {
+	re      *regexp.Regexp
+	layouts []string
+	loc     *time.Location
+}
+
+// newDatePatternMatcher 根据文件名 pattern（不含目录）创建匹配器，suffixes 为压缩后缀
+func newDatePatternMatcher(pattern string, suffixes []string, loc *time.Location) *datePatternMatcher {
+	ext := filepath.Ext(pattern)
+	stem := regexp.QuoteMeta(pattern[:len(pattern)-len(ext)])
+
+	m := &datePatternMatcher{loc: loc}
+	for i := 0; i < len(stem); i++ {
+		for _, p := range datePatternTokens {
+			if strings.HasPrefix(stem[i:], p.token) {
+				m.layouts = append(m.layouts, p.layout)
+			}
+		}
+	}
+	for _, p := range datePatternTokens {
+		stem = strings.ReplaceAll(stem, p.token, `(\d{`+strconv.Itoa(len(p.layout))+`})`)
+	}
+
+	quoted := make([]string, 0, len(suffixes))
+	for _, s := range suffixes {
+		quoted = append(quoted, regexp.QuoteMeta(s))
+	}
+	m.re = regexp.MustCompile(`^` + stem + `(?:\.(\d{8}T\d{6}\.\d{3})(?:\..+)?)?` +
+		regexp.QuoteMeta(ext) + `(?:` + strings.Join(quoted, "|") + `)?$`)
+	return m
+}
+
+// MatchBackup 实现 BackupMatcher，filename 为当前活动文件名，不作为历史文件
+func (m *datePatternMatcher) MatchBackup(filename, name string) (time.Time, bool) {
+	if name == filename {
+		return time.Time{}, false
+	}
+	sub := m.re.FindStringSubmatch(name)
+	if sub == nil {
+		return time.Time{}, false
+	}
+	if ts := sub[len(sub)-1]; ts != "" {
+		t, err := time.Parse(backupTimeFormat, ts)
+		return t, err == nil
+	}
+	t, err := time.ParseInLocation(strings.Join(m.layouts, " "), strings.Join(sub[1:len(sub)-1], " "), m.loc)
+	return t, err == nil
+}
+
+// switchDatedFile 活动文件名带日期模式时，日期变化后关闭当前文件，下次写入时打开新日期的文件，
+// 新文件名同样加上前缀并锁定（锁定失败时带上进程号），之前的文件作为历史文件参与压缩及清理
+func (l *file) switchDatedFile(writeTime time.Time) error {
+	dated := formatDatePattern(l.datePattern, l.patternTime(writeTime))
+	if dated == l.datedName {
+		return nil
+	}
+	if err := l.close(); err != nil {
+		return err
+	}
+
+	old, oldLock := l.filename(), l.fileLock()
+	if err := l.generateFilename(filepath.Join(l.dir(), dated)); err != nil {
+		return err
+	}
+	l.datedName = dated
+	if oldLock != l.fileLock() {
+		// 之前日期的文件名不再写入，删除其锁文件，以免锁文件逐日累积
+		releaseLock(oldLock)
+	}
+	if _, err := osStat(old); err == nil {
+		l.emit(Event{Type: Rotated, Path: old})
+		if !l.Compress {
+			l.protectBackup(old)
+			l.backupDone(old)
+		}
+	}
+	l.rotations.Add(1)
+	return nil
+}
+
+// patternTime 返回用于展开日期模式的时间，UtcTime 时使用 UTC
+func (l *file) patternTime(t time.Time) time.Time {
+	if l.UtcTime {
+		return t.UTC()
+	}
+	return t
+}
@@ -0,0 +1,137 @@
+package rotatefile
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dirLogFiles 返回日志目录中所有 rotatefile 管理的历史文件（包括其它应用的），从最新到最老排列，
+// 通过清单文件或者历史文件名模式（{前缀}.{时间戳}{扩展名}[压缩后缀]）识别，
+// activeSize 为清单中记录的其它应用当前日志文件的大小
+func (l *file) dirLogFiles() (files []logInfo, activeSize int64, err error) {
+	if files, err = l.oldLogFiles(); err != nil {
+		return nil, 0, err
+	}
+
+	seen := map[string]bool{}
+	for _, f := range files {
+		seen[f.Name] = true
+	}
+	add := func(name string, t time.Time) {
+		if seen[name] || l.clean.isRemoved(name) {
+			return
+		}
+		if info, err := os.Stat(filepath.Join(l.dir(), name)); err == nil && info.Mode().IsRegular() {
+			seen[name] = true
+			files = append(files, logInfo{timestamp: t, Name: name, Size: info.Size()})
+		}
+	}
+
+	manifests, _ := filepath.Glob(filepath.Join(l.dir(), "*"+manifestSuffix))
+	for _, path := range manifests {
+		m, err := LoadManifest(path)
+		if err != nil {
+			continue
+		}
+		for _, e := range m.Backups {
+			add(e.Name, e.Last)
+		}
+		if m.Filename != "" && m.Filename != filepath.Base(l.filename()) {
+			if info, err := os.Stat(filepath.Join(l.dir(), m.Filename)); err == nil {
+				activeSize += info.Size()
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(l.dir())
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, t, ok := l.splitAnyBackupName(e.Name()); ok {
+			add(e.Name(), t)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].timestamp.After(files[j].timestamp)
+	})
+	return files, activeSize, nil
+}
+
+// splitAnyBackupName 按历史文件名模式 {前缀}.{时间戳}[.{标签}]{扩展名}[压缩后缀] 解析任意应用的历史文件，
+// 返回对应的日志文件名 {前缀}{扩展名} 及滚动时间
+func (l *file) splitAnyBackupName(name string) (active string, t time.Time, ok bool) {
+	for _, c := range l.compressors() {
+		if s := strings.TrimSuffix(name, c.Suffix()); s != name {
+			name = s
+			break
+		}
+	}
+	ext := filepath.Ext(name)
+	name = strings.TrimSuffix(name, ext)
+
+	// 时间戳之后可能带有 RotateWithSuffix 的标签：{前缀}.{时间戳}.{标签}{扩展名}
+	for i := strings.IndexByte(name, '.'); i > 0; i = nextDot(name, i) {
+		end := i + 1 + len(backupTimeFormat)
+		if end > len(name) || end < len(name) && (name[end] != '.' || end+1 == len(name)) {
+			continue
+		}
+		if t, err := time.Parse(backupTimeFormat, name[i+1:end]); err == nil {
+			return name[:i] + ext, t, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// nextDot 返回 name 中位置 i 之后的下一个 '.' 的位置，没有时返回 -1
+func nextDot(name string, i int) int {
+	if j := strings.IndexByte(name[i+1:], '.'); j >= 0 {
+		return i + 1 + j
+	}
+	return -1
+}
+
+// siblingLogFiles 返回同一日志文件其它实例（锁冲突时文件名带进程号，例如 app.1234.log，以及不带进程号的 app.log）
+// 的历史文件，从最新到最老排列，activeSize 为这些实例当前日志文件的总大小
+func (l *file) siblingLogFiles() (files []logInfo, activeSize int64, err error) {
+	entries, err := os.ReadDir(l.dir())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stem, ext := l.instanceStem()
+	own := filepath.Base(l.filename())
+	sibling := func(name string) bool {
+		if name == own {
+			return false
+		}
+		_, ok := instancePid(name, stem, ext)
+		return ok || name == stem+ext
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, errInfo := e.Info()
+		if errInfo != nil {
+			continue
+		}
+		if sibling(e.Name()) {
+			activeSize += info.Size()
+		} else if active, t, ok := l.splitAnyBackupName(e.Name()); ok && sibling(active) && !l.clean.isRemoved(e.Name()) {
+			files = append(files, logInfo{timestamp: t, Name: e.Name(), Size: info.Size()})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].timestamp.After(files[j].timestamp)
+	})
+	return files, activeSize, nil
+}
@@ -22,6 +22,7 @@ package disk
 // Free - free size of the volume / disk
 // Files - total inodes available
 // Ffree - free inodes available
+// Files and Ffree are zero when the file system has no fixed number of inodes (e.g. NTFS, btrfs)
 // FSType - file system type
 // Major - major dev id
 // Minor - minor dev id
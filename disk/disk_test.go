@@ -21,6 +21,8 @@
 package disk_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/bingoohuang/rotatefile/disk"
@@ -36,3 +38,22 @@ func TestFree(t *testing.T) {
 		t.Error("Unexpected FSType", di.FSType)
 	}
 }
+
+func TestGetInfoSubdir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	di, err := disk.GetInfo(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if di.Total == 0 || di.Free > di.Total {
+		t.Errorf("Unexpected Total %d Free %d", di.Total, di.Free)
+	}
+
+	if _, err := disk.GetInfo(filepath.Join(dir, "missing"), false); err == nil {
+		t.Error("Expected error for missing path")
+	}
+}
@@ -24,7 +24,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"syscall"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -54,57 +53,42 @@ func GetInfo(path string, _ bool) (info Info, err error) {
 		return Info{}, err
 	}
 
-	lpFreeBytesAvailable := int64(0)
-	lpTotalNumberOfBytes := int64(0)
-	lpTotalNumberOfFreeBytes := int64(0)
+	dirPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return Info{}, err
+	}
+
+	var lpFreeBytesAvailable, lpTotalNumberOfBytes, lpTotalNumberOfFreeBytes uint64
 
-	// Extract values safely
 	// BOOL WINAPI GetDiskFreeSpaceEx(
 	// _In_opt_  LPCTSTR         lpDirectoryName,
 	// _Out_opt_ PULARGE_INTEGER lpFreeBytesAvailable,
 	// _Out_opt_ PULARGE_INTEGER lpTotalNumberOfBytes,
 	// _Out_opt_ PULARGE_INTEGER lpTotalNumberOfFreeBytes
 	// );
-	_, _, _ = GetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(path))),
+	if r1, _, e := GetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(dirPtr)),
 		uintptr(unsafe.Pointer(&lpFreeBytesAvailable)),
 		uintptr(unsafe.Pointer(&lpTotalNumberOfBytes)),
-		uintptr(unsafe.Pointer(&lpTotalNumberOfFreeBytes)))
+		uintptr(unsafe.Pointer(&lpTotalNumberOfFreeBytes))); r1 == 0 {
+		return Info{}, &os.PathError{Op: "GetDiskFreeSpaceEx", Path: path, Err: e}
+	}
 
-	if uint64(lpTotalNumberOfFreeBytes) > uint64(lpTotalNumberOfBytes) {
+	// Like statfs Bavail on unix, Free is the space available to the caller,
+	// which honors per-user disk quotas.
+	if lpFreeBytesAvailable > lpTotalNumberOfBytes {
 		return info, fmt.Errorf("detected free space (%d) > total drive space (%d), fs corruption at (%s). please run 'fsck'",
-			uint64(lpTotalNumberOfFreeBytes), uint64(lpTotalNumberOfBytes), path)
+			lpFreeBytesAvailable, lpTotalNumberOfBytes, path)
 	}
 
 	info = Info{
-		Total:  uint64(lpTotalNumberOfBytes),
-		Free:   uint64(lpTotalNumberOfFreeBytes),
-		Used:   uint64(lpTotalNumberOfBytes) - uint64(lpTotalNumberOfFreeBytes),
+		Total:  lpTotalNumberOfBytes,
+		Free:   lpFreeBytesAvailable,
+		Used:   lpTotalNumberOfBytes - lpTotalNumberOfFreeBytes,
 		FSType: getFSType(path),
 	}
 
-	// Return values of GetDiskFreeSpace()
-	lpSectorsPerCluster := uint32(0)
-	lpBytesPerSector := uint32(0)
-	lpNumberOfFreeClusters := uint32(0)
-	lpTotalNumberOfClusters := uint32(0)
-
-	// Extract values safely
-	// BOOL WINAPI GetDiskFreeSpace(
-	//   _In_  LPCTSTR lpRootPathName,
-	//   _Out_ LPDWORD lpSectorsPerCluster,
-	//   _Out_ LPDWORD lpBytesPerSector,
-	//   _Out_ LPDWORD lpNumberOfFreeClusters,
-	//   _Out_ LPDWORD lpTotalNumberOfClusters
-	// );
-	_, _, _ = GetDiskFreeSpace.Call(uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(path))),
-		uintptr(unsafe.Pointer(&lpSectorsPerCluster)),
-		uintptr(unsafe.Pointer(&lpBytesPerSector)),
-		uintptr(unsafe.Pointer(&lpNumberOfFreeClusters)),
-		uintptr(unsafe.Pointer(&lpTotalNumberOfClusters)))
-
-	info.Files = uint64(lpTotalNumberOfClusters)
-	info.Ffree = uint64(lpNumberOfFreeClusters)
-
+	// NTFS and ReFS allocate file records on demand, there is no fixed
+	// inode table to exhaust, so Files and Ffree are left as zero.
 	return info, nil
 }
 
@@ -0,0 +1,48 @@
+package rotatefile
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bingoohuang/rotatefile/disk"
+)
+
+// diskInfoTTL 磁盘信息的缓存时长，频繁滚动时，避免每次清理都调用 statfs
+var diskInfoTTL = time.Second
+
+// cachedDiskInfo 缓存的磁盘信息，At 为查询时间
+type cachedDiskInfo struct {
+	disk.Info
+	At time.Time
+}
+
+var diskInfoCache = struct {
+	sync.Mutex
+	m map[string]cachedDiskInfo
+}{m: map[string]cachedDiskInfo{}}
+
+// getDiskInfo 返回目录 dir 所在磁盘的信息，diskInfoTTL 内重复查询时返回缓存的结果，同一目录的多个日志文件共享缓存
+func getDiskInfo(dir string) (cachedDiskInfo, error) {
+	diskInfoCache.Lock()
+	defer diskInfoCache.Unlock()
+
+	now := time.Now()
+	if c, ok := diskInfoCache.m[dir]; ok && now.Sub(c.At) < diskInfoTTL {
+		return c, nil
+	}
+	info, err := disk.GetInfo(dir, false)
+	if err != nil {
+		delete(diskInfoCache.m, dir)
+		return cachedDiskInfo{}, err
+	}
+	c := cachedDiskInfo{Info: info, At: now}
+	diskInfoCache.m[dir] = c
+	return c, nil
+}
+
+// invalidateDiskInfo 删除历史文件后，丢弃目录 dir 缓存的磁盘信息，以免下次清理按删除前的空余重复删除
+func invalidateDiskInfo(dir string) {
+	diskInfoCache.Lock()
+	delete(diskInfoCache.m, dir)
+	diskInfoCache.Unlock()
+}
@@ -0,0 +1,81 @@
+package rotatefile
+
+import (
+	"time"
+
+	"github.com/bingoohuang/rotatefile/du"
+)
+
+// diskWatchInterval 检查磁盘使用率的间隔
+var diskWatchInterval = 10 * time.Second
+
+// minDiskFree 返回磁盘总大小为 total 时，同时满足 MinDiskFree 及 MaxDiskUsage 的最少空余
+func (c *Config) minDiskFree(total uint64) uint64 {
+	if c.MaxDiskUsage <= 0 || c.MaxDiskUsage >= 100 {
+		return c.MinDiskFree
+	}
+	if v := total / 100 * uint64(100-c.MaxDiskUsage); v > c.MinDiskFree {
+		return v
+	}
+	return c.MinDiskFree
+}
+
+// watchDiskUsage 定期检查目录 dir 所在磁盘的使用率，超过 maxUsage 时调用 trigger 立即清理，而不是等到下次滚动，
+// 返回停止检查的函数，maxUsage 无效时不检查，返回 nil
+func watchDiskUsage(dir string, maxUsage int, trigger func()) (stop func()) {
+	if maxUsage <= 0 || maxUsage >= 100 {
+		return nil
+	}
+	return du.Watch(dir, diskWatchInterval, []float64{float64(maxUsage)}, func(float64, *du.DiskUsage) { trigger() })
+}
+
+// watchDiskUsage 磁盘使用率超过 MaxDiskUsage 时，立即触发清理，Manager 的日志流由 Manager 统一检查
+func (l *file) watchDiskUsage(bg *background) {
+	if l.manager != nil {
+		l.manager.watchDiskUsage()
+		return
+	}
+
+	stop := watchDiskUsage(l.dir(), l.MaxDiskUsage, func() {
+		select {
+		case <-bg.done:
+			return
+		default:
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.mill()
+	})
+	if stop != nil {
+		bg.Go(func(done <-chan struct{}) {
+			<-done
+			stop()
+		})
+	}
+}
+
+// watchDiskUsage 磁盘使用率超过 MaxDiskUsage 时，立即触发所有日志流的清理，已经在检查时不重复启动
+func (m *Manager) watchDiskUsage() {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+
+	if m.watchStop == nil {
+		m.watchStop = watchDiskUsage(m.dir, m.config.MaxDiskUsage, func() {
+			select {
+			case m.millChan() <- true:
+			default:
+			}
+		})
+	}
+}
+
+// stopWatchDiskUsage 停止检查磁盘使用率，日志流再次打开时重新启动
+func (m *Manager) stopWatchDiskUsage() {
+	m.watchMu.Lock()
+	defer m.watchMu.Unlock()
+
+	if m.watchStop != nil {
+		m.watchStop()
+		m.watchStop = nil
+	}
+}
@@ -0,0 +1,8 @@
+//go:build linux || darwin || netbsd || openbsd || solaris || aix
+
+package rotatefile
+
+import "syscall"
+
+// dsyncFlag 以 O_DSYNC 模式打开文件的标志
+const dsyncFlag = syscall.O_DSYNC
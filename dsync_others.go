@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || netbsd || openbsd || solaris || aix)
+
+package rotatefile
+
+import "os"
+
+// dsyncFlag 不支持 O_DSYNC 的平台，使用 O_SYNC 代替
+const dsyncFlag = os.O_SYNC
@@ -48,3 +48,13 @@ func (du *DiskUsage) Used() uint64 {
 func (du *DiskUsage) Usage() float32 {
 	return float32(du.Used()) / float32(du.Size())
 }
+
+// Inodes returns total inodes on file system
+func (du *DiskUsage) Inodes() uint64 {
+	return uint64(du.stat.Files)
+}
+
+// FreeInodes returns total free inodes on file system
+func (du *DiskUsage) FreeInodes() uint64 {
+	return uint64(du.stat.Ffree)
+}
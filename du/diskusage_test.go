@@ -3,6 +3,7 @@ package du
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 const KB = 1024
@@ -14,4 +15,28 @@ func TestNewDiskUsage(t *testing.T) {
 	fmt.Println("Size:", usage.Size()/(KB*KB))
 	fmt.Println("Used:", usage.Used()/(KB*KB))
 	fmt.Println("Usage:", usage.Usage()*100, "%")
+	fmt.Println("Inodes:", usage.FreeInodes(), "/", usage.Inodes())
+}
+
+func TestWatch(t *testing.T) {
+	fired := make(chan float64, 10)
+	stop := Watch(".", 10*time.Millisecond, []float64{101, 0}, func(threshold float64, usage *DiskUsage) {
+		fired <- threshold
+	})
+	defer stop()
+
+	select {
+	case threshold := <-fired:
+		if threshold != 0 {
+			t.Fatalf("unexpected threshold %v", threshold)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("threshold 0 not fired")
+	}
+
+	// usage has not dropped below 0, so it must not fire again
+	time.Sleep(50 * time.Millisecond)
+	if len(fired) > 0 {
+		t.Fatalf("unexpected threshold %v", <-fired)
+	}
 }
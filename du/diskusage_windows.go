@@ -1,31 +1,26 @@
 package du
 
 import (
-	"unsafe"
-
 	"golang.org/x/sys/windows"
 )
 
+// DiskUsage contains usage data and provides user-friendly access methods
 type DiskUsage struct {
-	freeBytes  int64
-	totalBytes int64
-	availBytes int64
+	freeBytes  uint64
+	totalBytes uint64
+	availBytes uint64
 }
 
-// NewDiskUsages returns an object holding the disk usage of volumePath
+// NewDiskUsage returns an object holding the disk usage of volumePath
 // or nil in case of error (invalid path, etc.)
 func NewDiskUsage(volumePath string) (*DiskUsage, error) {
-	h := windows.MustLoadDLL("kernel32.dll")
-	c := h.MustFindProc("GetDiskFreeSpaceExW")
+	p, err := windows.UTF16PtrFromString(volumePath)
+	if err != nil {
+		return nil, err
+	}
 
 	du := &DiskUsage{}
-
-	r1, _, err := c.Call(
-		uintptr(unsafe.Pointer(windows.StringToUTF16Ptr(volumePath))),
-		uintptr(unsafe.Pointer(&du.freeBytes)),
-		uintptr(unsafe.Pointer(&du.totalBytes)),
-		uintptr(unsafe.Pointer(&du.availBytes)))
-	if r1 == 0 {
+	if err := windows.GetDiskFreeSpaceEx(p, &du.availBytes, &du.totalBytes, &du.freeBytes); err != nil {
 		return nil, err
 	}
 
@@ -34,17 +29,17 @@ func NewDiskUsage(volumePath string) (*DiskUsage, error) {
 
 // Free returns total free bytes on file system
 func (du *DiskUsage) Free() uint64 {
-	return uint64(du.freeBytes)
+	return du.freeBytes
 }
 
 // Available returns total available bytes on file system to an unprivileged user
 func (du *DiskUsage) Available() uint64 {
-	return uint64(du.availBytes)
+	return du.availBytes
 }
 
 // Size returns total size of the file system
 func (du *DiskUsage) Size() uint64 {
-	return uint64(du.totalBytes)
+	return du.totalBytes
 }
 
 // Used returns total bytes used in file system
@@ -56,3 +51,13 @@ func (du *DiskUsage) Used() uint64 {
 func (du *DiskUsage) Usage() float32 {
 	return float32(du.Used()) / float32(du.Size())
 }
+
+// Inodes returns total inodes on file system, always 0 since NTFS has no fixed inode table
+func (du *DiskUsage) Inodes() uint64 {
+	return 0
+}
+
+// FreeInodes returns total free inodes on file system, always 0 since NTFS has no fixed inode table
+func (du *DiskUsage) FreeInodes() uint64 {
+	return 0
+}
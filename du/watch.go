@@ -0,0 +1,43 @@
+package du
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Watch checks the usage of the file system containing path every interval,
+// and calls fn each time the usage (in percent, 0-100) rises to or above one of
+// the thresholds. A threshold fires again only after the usage has dropped
+// below it. The returned function stops the watching.
+func Watch(path string, interval time.Duration, thresholds []float64, fn func(threshold float64, usage *DiskUsage)) (stop func()) {
+	ts := append([]float64(nil), thresholds...)
+	sort.Float64s(ts)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		crossed := 0 // number of thresholds at or below the last usage
+		for {
+			if usage, err := NewDiskUsage(path); err == nil && usage.Size() > 0 {
+				percent := float64(usage.Usage()) * 100
+				n := sort.Search(len(ts), func(i int) bool { return ts[i] > percent })
+				for i := crossed; i < n; i++ {
+					fn(ts[i], usage)
+				}
+				crossed = n
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -3,10 +3,10 @@ package rotatefile
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
-
-	"github.com/bingoohuang/q"
+	"time"
 )
 
 // EnvBool 解析环境变量设置的 bool 类型变量
@@ -40,6 +40,18 @@ func EnvInt(envName string, defaultValue int) int {
 	return defaultValue
 }
 
+// EnvDuration 解析环境变量设置的时长类型的变量，例如 36h、90m
+func EnvDuration(envName string, defaultValue time.Duration) time.Duration {
+	if s := os.Getenv(envName); s != "" {
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return defaultValue
+		}
+		return v
+	}
+	return defaultValue
+}
+
 // EnvSize 解析环境变量设置的字节大小类型的变量
 func EnvSize(envName string, defaultValue uint64) uint64 {
 	if s := os.Getenv(envName); s != "" {
@@ -53,11 +65,17 @@ func EnvSize(envName string, defaultValue uint64) uint64 {
 	return defaultValue
 }
 
-// Debugf print debug info.
+// EnvList 解析环境变量设置的路径列表，以 : 分隔（Windows 为 ;）
+func EnvList(envName string, defaultValue []string) []string {
+	if s := os.Getenv(envName); s != "" {
+		return filepath.SplitList(s)
+	}
+	return defaultValue
+}
+
+// Debugf print debug info to the internal logger, see SetInternalLogger.
 func Debugf(format string, a ...interface{}) {
-	s := fmt.Sprintf(format, a...)
-	if !strings.HasSuffix(s, "\n") {
-		s += "\n"
+	if l := internalLogger.Load(); l != nil {
+		_ = l.Output(2, fmt.Sprintf(format, a...))
 	}
-	q.Q(s)
 }
@@ -14,6 +14,11 @@ func EnvSignals(envName string, defaultValue []os.Signal) []os.Signal {
 	if s == "" {
 		return defaultValue
 	}
+	return ParseSignals(s)
+}
+
+// ParseSignals 解析以英文逗号分隔的信号名，例如 SIGHUP,SIGUSR1，支持 SIGHUP、SIGUSR1、SIGUSR2
+func ParseSignals(s string) []os.Signal {
 	var signals []os.Signal
 	splits := strings.Split(s, ",")
 	for _, item := range splits {
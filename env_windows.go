@@ -5,3 +5,8 @@ import "os"
 func EnvSignals(envName string, defaultValue []os.Signal) []os.Signal {
 	return nil
 }
+
+// ParseSignals Windows 不支持滚动信号
+func ParseSignals(s string) []os.Signal {
+	return nil
+}
@@ -0,0 +1,90 @@
+package rotatefile
+
+import (
+	"strconv"
+	"time"
+)
+
+// EventType 事件类型
+type EventType int
+
+const (
+	// FileOpened 打开或者新建日志文件，Path 为日志文件
+	FileOpened EventType = iota + 1
+	// Rotated 日志文件滚动为历史文件，Path 为历史文件
+	Rotated
+	// Compressed 历史文件压缩完成，Path 为压缩文件
+	Compressed
+	// Deleted 历史文件被清理，Path 为删除的文件
+	Deleted
+	// DiskLow 磁盘剩余空间低于 MinDiskFree（或者使用率超过 MaxDiskUsage）、剩余 inode 低于 MinFreeInodes，Path 为日志目录，Free 为剩余空间，FreeInodes 为剩余 inode
+	DiskLow
+	// WriteError 写入最终失败（重试之后），Path 为日志文件
+	WriteError
+	// MillError 后台清理（压缩、删除等）失败
+	MillError
+	// Archived 历史文件由 Archiver 归档成功，Path 为历史文件
+	Archived
+	// Trashed 过期或超过个数的历史文件移入隔离目录 TrashDir，Path 为隔离目录中的文件
+	Trashed
+	// Planned MillDryRun 时将要执行的清理操作，Path 为文件，Reason 为操作及原因，例如 delete max-backups
+	Planned
+)
+
+var eventTypeNames = map[EventType]string{
+	FileOpened: "FileOpened",
+	Rotated:    "Rotated",
+	Compressed: "Compressed",
+	Deleted:    "Deleted",
+	DiskLow:    "DiskLow",
+	WriteError: "WriteError",
+	MillError:  "MillError",
+	Archived:   "Archived",
+	Trashed:    "Trashed",
+	Planned:    "Planned",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Event 日志文件的内部事件，用于编排程序按事件处理，例如滚动后上传历史文件、磁盘不足时告警
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Path 事件相关的文件或者目录
+	Path string
+	// Err WriteError/MillError 的错误
+	Err error
+	// Free DiskLow 时磁盘剩余空间
+	Free uint64
+	// FreeInodes DiskLow 时磁盘剩余 inode，文件系统没有固定 inode 数时为 0
+	FreeInodes uint64
+	// Reason Planned 的操作及原因
+	Reason string
+}
+
+// emit 回调 OnEvent
+func (c *Config) emit(e Event) {
+	if c.OnEvent == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = c.now()
+	}
+	c.OnEvent(e)
+}
+
+// WithEventChan 指定事件通道，事件不阻塞地发送到 ch，通道满时丢弃，用于以通道方式订阅事件，
+// 与 WithOnEvent 互相覆盖
+func WithEventChan(ch chan<- Event) ConfigFn {
+	return WithOnEvent(func(e Event) {
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+}
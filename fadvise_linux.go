@@ -0,0 +1,12 @@
+package rotatefile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dropPageCache 告知内核不再需要文件 f 的页缓存，以免大量冷日志数据挤占应用的页缓存
+func dropPageCache(f *os.File) error {
+	return unix.Fadvise(int(f.Fd()), 0, 0, unix.FADV_DONTNEED)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package rotatefile
+
+import "os"
+
+// dropPageCache 仅 Linux 支持
+func dropPageCache(*os.File) error { return nil }
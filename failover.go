@@ -0,0 +1,48 @@
+package rotatefile
+
+import (
+	"time"
+)
+
+const (
+	// defaultFailoverAfter 默认连续写入失败多少次后切换到备用目标
+	defaultFailoverAfter = 3
+	// defaultFailoverProbe 切换到备用目标后，默认探测主目标是否恢复的间隔
+	defaultFailoverProbe = 30 * time.Second
+)
+
+// failoverWrite 使用 write 写入 p，设置了 Failover 时，连续 FailoverAfter 次写入失败后切换到备用目标，
+// 切换期间每隔 FailoverProbe 尝试写入主目标一次，成功后切换回主目标
+func (l *file) failoverWrite(p []byte, write func([]byte) (int, error)) (int, error) {
+	if l.Failover == nil {
+		return write(p)
+	}
+
+	now := l.now()
+	if l.failedOver.Load() && now.UnixNano() < l.nextProbe.Load() {
+		return l.Failover.Write(p)
+	}
+
+	n, err := write(p)
+	if err == nil || err == ErrWouldBlock {
+		l.failures.Store(0)
+		l.failedOver.Store(false)
+		return n, err
+	}
+
+	after := l.FailoverAfter
+	if after <= 0 {
+		after = defaultFailoverAfter
+	}
+	if !l.failedOver.Load() && l.failures.Add(1) < int32(after) {
+		return n, err
+	}
+
+	probe := l.FailoverProbe
+	if probe <= 0 {
+		probe = defaultFailoverProbe
+	}
+	l.failedOver.Store(true)
+	l.nextProbe.Store(now.Add(probe).UnixNano())
+	return l.Failover.Write(p)
+}
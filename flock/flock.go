@@ -18,8 +18,13 @@ package flock
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -32,12 +37,40 @@ type Flock struct {
 	m    sync.RWMutex
 	l    bool
 	r    bool
+	ofd  bool
+	// holder records the holder in the lock file, see WithHolderInfo
+	holder bool
 }
 
-// New returns a new instance of *Flock. The only parameter
+// Option configures a *Flock created by New.
+type Option func(*Flock)
+
+// WithOFD makes the *Flock use open file description locks (fcntl
+// F_OFD_SETLK/F_OFD_SETLKW) on Linux instead of flock(2). OFD locks are owned
+// by the open file description like flock(2) locks, so opening the lock file
+// twice in the same process (e.g. a writer plus an admin handler) still
+// conflicts and closing one descriptor doesn't drop the other's lock, as it
+// would with classic POSIX record locks; unlike flock(2) they are also
+// honored over NFS. On other platforms the option is ignored.
+func WithOFD() Option {
+	return func(f *Flock) { f.ofd = true }
+}
+
+// WithHolderInfo makes the *Flock record the pid, hostname and time of the
+// holder in the lock file whenever it takes an exclusive lock, so tooling
+// can report who owns a contested lock with Holder.
+func WithHolderInfo() Option {
+	return func(f *Flock) { f.holder = true }
+}
+
+// New returns a new instance of *Flock. The first parameter
 // it takes is the path to the desired lockfile.
-func New(path string) *Flock {
-	return &Flock{path: path}
+func New(path string, opts ...Option) *Flock {
+	f := &Flock{path: path}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 // NewFlock returns a new instance of *Flock. The only parameter
@@ -114,18 +147,101 @@ func tryCtx(ctx context.Context, fn func() (bool, error), retryDelay time.Durati
 	}
 }
 
+// LockWithTimeout tries to take an exclusive lock for at most d, retrying
+// TryLock in between. It returns false with a nil error if it timed out.
+func (f *Flock) LockWithTimeout(d time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	retryDelay := d / 10
+	if retryDelay < time.Millisecond {
+		retryDelay = time.Millisecond
+	} else if retryDelay > 100*time.Millisecond {
+		retryDelay = 100 * time.Millisecond
+	}
+
+	locked, err := f.TryLockContext(ctx, retryDelay)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false, nil
+	}
+	return locked, err
+}
+
+// HolderInfo describes the last holder of an exclusive lock taken WithHolderInfo.
+type HolderInfo struct {
+	Pid  int
+	Host string
+	Time time.Time
+}
+
+// Holder reads the holder recorded in the lock file by a *Flock created
+// WithHolderInfo, possibly in another process. The information is left in
+// place on Unlock, so it describes the last holder, which may have exited.
+func (f *Flock) Holder() (HolderInfo, error) {
+	fh, err := os.Open(f.path)
+	if err != nil {
+		return HolderInfo{}, err
+	}
+	defer fh.Close()
+
+	// the first byte is locked on Windows, the info starts after it
+	buf := make([]byte, 256)
+	n, err := fh.ReadAt(buf, 1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return HolderInfo{}, err
+	}
+	return parseHolder(strings.TrimSpace(string(buf[:n])))
+}
+
+// parseHolder parses the holder info in the form pid@host time.
+func parseHolder(s string) (HolderInfo, error) {
+	var h HolderInfo
+	id, t, _ := strings.Cut(s, " ")
+	pid, host, ok := strings.Cut(id, "@")
+	if !ok {
+		return h, fmt.Errorf("flock: no holder info in %q", s)
+	}
+	var err error
+	if h.Pid, err = strconv.Atoi(pid); err != nil {
+		return h, fmt.Errorf("flock: bad holder pid in %q", s)
+	}
+	h.Host = host
+	h.Time, _ = time.Parse(time.RFC3339, t)
+	return h, nil
+}
+
+// writeHolder records the current process as the holder in the lock file,
+// after an exclusive lock was taken by a *Flock created WithHolderInfo.
+func (f *Flock) writeHolder() {
+	if !f.holder {
+		return
+	}
+	host, _ := os.Hostname()
+	info := fmt.Sprintf("\n%d@%s %s\n", os.Getpid(), host, time.Now().Format(time.RFC3339))
+	if err := f.fh.Truncate(0); err == nil {
+		_, _ = f.fh.WriteAt([]byte(info), 0)
+	}
+}
+
 func (f *Flock) setFh() error {
 	// open a new os.File instance
 	// create it if it doesn't exist, and open the file read-only.
 	flags := os.O_CREATE
-	if runtime.GOOS == "aix" {
-		// AIX cannot preform write-lock (ie exclusive) on a
-		// read-only file.
+	// AIX and fcntl based OFD locks cannot preform write-lock
+	// (ie exclusive) on a read-only file.
+	needWrite := runtime.GOOS == "aix" || f.ofd && runtime.GOOS == "linux"
+	if needWrite || f.holder {
 		flags |= os.O_RDWR
 	} else {
 		flags |= os.O_RDONLY
 	}
 	fh, err := os.OpenFile(f.path, flags, os.FileMode(0o600))
+	if err != nil && !needWrite && f.holder && errors.Is(err, os.ErrPermission) {
+		// the lock file was created by another user (e.g. 0644) and can't be
+		// written, so lock it read-only as without WithHolderInfo; writeHolder
+		// then fails to truncate the file and records nothing.
+		fh, err = os.OpenFile(f.path, os.O_RDONLY, 0)
+	}
 	if err != nil {
 		return err
 	}
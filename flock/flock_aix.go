@@ -103,6 +103,9 @@ func (f *Flock) lock(locked *bool, flag lockType) error {
 	}
 
 	*locked = true
+	if locked == &f.l {
+		f.writeHolder()
+	}
 	return nil
 }
 
@@ -263,6 +266,9 @@ func (f *Flock) try(locked *bool, flag lockType) (bool, error) {
 	}
 
 	*locked = haslock
+	if haslock && locked == &f.l {
+		f.writeHolder()
+	}
 	return haslock, nil
 }
 
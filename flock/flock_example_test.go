@@ -78,3 +78,26 @@ func ExampleFlock_TryLockContext() {
 
 	fmt.Printf("path: %s; locked: %v\n", fileLock.Path(), fileLock.Locked())
 }
+
+func ExampleFlock_TryRLockContext() {
+	// readers (e.g. log viewers) take shared locks, which coexist with each other
+	// but not with the exclusive lock of a writer
+	fileLock := flock.New(os.TempDir() + "/go-lock.lock")
+
+	lockCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	locked, err := fileLock.TryRLockContext(lockCtx, 678*time.Millisecond)
+	if err != nil {
+		// handle locking error
+	}
+
+	if locked {
+		fmt.Printf("path: %s; rlocked: %v\n", fileLock.Path(), fileLock.RLocked())
+
+		if err := fileLock.Unlock(); err != nil {
+			// handle unlock error
+		}
+	}
+
+	fmt.Printf("path: %s; rlocked: %v\n", fileLock.Path(), fileLock.RLocked())
+}
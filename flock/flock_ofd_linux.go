@@ -0,0 +1,33 @@
+package flock
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ofdLock maps the flock(2) operation how (LOCK_SH, LOCK_EX or LOCK_UN,
+// optionally with LOCK_NB) to an OFD lock on the whole file.
+func ofdLock(fd uintptr, how int) error {
+	var lk unix.Flock_t // Whence/Start/Len 0: the whole file
+	switch how &^ syscall.LOCK_NB {
+	case syscall.LOCK_SH:
+		lk.Type = unix.F_RDLCK
+	case syscall.LOCK_EX:
+		lk.Type = unix.F_WRLCK
+	default:
+		lk.Type = unix.F_UNLCK
+	}
+
+	cmd := unix.F_OFD_SETLKW
+	if how&syscall.LOCK_NB != 0 || lk.Type == unix.F_UNLCK {
+		cmd = unix.F_OFD_SETLK
+	}
+	err := unix.FcntlFlock(fd, cmd, &lk)
+	if errors.Is(err, unix.EACCES) || errors.Is(err, unix.EAGAIN) {
+		// report a conflicting lock the same way as flock(2)
+		return syscall.EWOULDBLOCK
+	}
+	return err
+}
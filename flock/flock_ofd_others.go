@@ -0,0 +1,10 @@
+//go:build !aix && !windows && !linux
+
+package flock
+
+import "syscall"
+
+// ofdLock falls back to flock(2) where OFD locks are not available.
+func ofdLock(fd uintptr, how int) error {
+	return syscall.Flock(int(fd), how)
+}
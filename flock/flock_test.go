@@ -296,3 +296,92 @@ func (t *TestSuite) TestFlock_RLock(c *C) {
 	c.Check(gf.Locked(), Equals, false)
 	c.Check(gf.RLocked(), Equals, true)
 }
+
+func (t *TestSuite) TestFlock_OFD(c *C) {
+	if runtime.GOOS != "linux" {
+		c.Skip("OFD locks are Linux only")
+	}
+
+	f1 := flock.New(t.path, flock.WithOFD())
+	defer f1.Unlock()
+	locked, err := f1.TryLock()
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, true)
+
+	// a second open of the same file in the same process conflicts,
+	// as the lock belongs to the open file description
+	f2 := flock.New(t.path, flock.WithOFD())
+	defer f2.Unlock()
+	locked, err = f2.TryLock()
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, false)
+	locked, err = f2.TryRLock()
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, false)
+
+	c.Assert(f1.Unlock(), IsNil)
+	locked, err = f2.TryRLock()
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, true)
+
+	// shared locks coexist
+	locked, err = f1.TryRLock()
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, true)
+}
+
+func (t *TestSuite) TestFlock_LockWithTimeout(c *C) {
+	locked, err := t.flock.LockWithTimeout(time.Second)
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, true)
+
+	start := time.Now()
+	locked, err = flock.New(t.path).LockWithTimeout(50 * time.Millisecond)
+	c.Assert(err, IsNil)
+	c.Check(locked, Equals, false)
+	c.Check(time.Since(start) >= 50*time.Millisecond, Equals, true)
+}
+
+func (t *TestSuite) TestFlock_Holder(c *C) {
+	f := flock.New(t.path, flock.WithHolderInfo())
+	defer f.Unlock()
+	locked, err := f.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(locked, Equals, true)
+
+	// readable from another *Flock while the lock is held
+	h, err := flock.New(t.path).Holder()
+	c.Assert(err, IsNil)
+	host, _ := os.Hostname()
+	c.Check(h.Pid, Equals, os.Getpid())
+	c.Check(h.Host, Equals, host)
+	c.Check(time.Since(h.Time) < time.Minute, Equals, true)
+
+	// no holder info without WithHolderInfo
+	c.Assert(f.Unlock(), IsNil)
+	c.Assert(os.Truncate(t.path, 0), IsNil)
+	locked, err = t.flock.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(locked, Equals, true)
+	_, err = t.flock.Holder()
+	c.Check(err, NotNil)
+}
+
+func (t *TestSuite) TestFlock_HolderReadOnly(c *C) {
+	if runtime.GOOS != "windows" && os.Geteuid() == 0 {
+		c.Skip("root can write a read-only lock file")
+	}
+
+	// a lock file created by another user can't be opened for writing
+	c.Assert(os.WriteFile(t.path, nil, 0o444), IsNil)
+	defer os.Chmod(t.path, 0o600)
+
+	f := flock.New(t.path, flock.WithHolderInfo())
+	defer f.Unlock()
+	locked, err := f.TryLock()
+	c.Assert(err, IsNil)
+	c.Assert(locked, Equals, true)
+
+	_, err = f.Holder()
+	c.Check(err, NotNil)
+}
@@ -56,7 +56,7 @@ func (f *Flock) lock(locked *bool, flag int) error {
 		defer f.ensureFhState()
 	}
 
-	if err := syscall.Flock(int(f.fh.Fd()), flag); err != nil {
+	if err := f.flock(flag); err != nil {
 		shouldRetry, reopenErr := f.reopenFDOnError(err)
 		if reopenErr != nil {
 			return reopenErr
@@ -66,12 +66,15 @@ func (f *Flock) lock(locked *bool, flag int) error {
 			return err
 		}
 
-		if err = syscall.Flock(int(f.fh.Fd()), flag); err != nil {
+		if err = f.flock(flag); err != nil {
 			return err
 		}
 	}
 
 	*locked = true
+	if locked == &f.l {
+		f.writeHolder()
+	}
 	return nil
 }
 
@@ -96,7 +99,7 @@ func (f *Flock) Unlock() error {
 	}
 
 	// mark the file as unlocked
-	if err := syscall.Flock(int(f.fh.Fd()), syscall.LOCK_UN); err != nil {
+	if err := f.flock(syscall.LOCK_UN); err != nil {
 		return err
 	}
 
@@ -150,9 +153,12 @@ func (f *Flock) try(locked *bool, flag int) (bool, error) {
 
 	var retried bool
 retry:
-	err := syscall.Flock(int(f.fh.Fd()), flag|syscall.LOCK_NB)
+	err := f.flock(flag | syscall.LOCK_NB)
 	if err == nil {
 		*locked = true
+		if locked == &f.l {
+			f.writeHolder()
+		}
 		return true, nil
 	}
 	if errors.Is(err, syscall.EWOULDBLOCK) {
@@ -171,6 +177,15 @@ retry:
 	return false, err
 }
 
+// flock applies or removes the lock with flock(2), or with fcntl(2) OFD
+// locks when the *Flock was created WithOFD on Linux.
+func (f *Flock) flock(how int) error {
+	if f.ofd {
+		return ofdLock(f.fh.Fd(), how)
+	}
+	return syscall.Flock(int(f.fh.Fd()), how)
+}
+
 // reopenFDOnError determines whether we should reopen the file handle
 // in readwrite mode and try again. This comes from util-linux/sys-utils/flock.c:
 //
@@ -54,6 +54,9 @@ func (f *Flock) lock(locked *bool, flag uint32) error {
 	}
 
 	*locked = true
+	if locked == &f.l {
+		f.writeHolder()
+	}
 	return nil
 }
 
@@ -137,6 +140,9 @@ func (f *Flock) try(locked *bool, flag uint32) (bool, error) {
 	}
 
 	*locked = true
+	if locked == &f.l {
+		f.writeHolder()
+	}
 
 	return true, nil
 }
@@ -0,0 +1,229 @@
+// Package gelf 将 stdlog 格式的日志行转换为 GELF 1.1 消息，通过 UDP/TCP 发送到 Graylog，
+// 作为 rotatefile 的 Tee 输出目标使用，发送失败不影响日志文件写入
+//
+//	w, err := gelf.New("udp", "graylog:12201", gelf.WithFacility("myapp"))
+//	stdlog.Init(rotatefile.WithTee(w))
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bingoohuang/rotatefile/internal/logline"
+)
+
+const (
+	// DefaultChunkSize UDP 分块大小，适合大多数网络的 MTU
+	DefaultChunkSize = 1420
+	// maxChunks GELF 规定的最大分块数
+	maxChunks = 128
+	// chunkHeaderSize 分块头：魔数 2 字节，消息 ID 8 字节，序号 1 字节，分块数 1 字节
+	chunkHeaderSize = 12
+	dialTimeout     = 5 * time.Second
+)
+
+// ErrTooLarge 消息分块数超过 GELF 规定的 128 块
+var ErrTooLarge = errors.New("gelf: message too large")
+
+// levels stdlog 日志级别对应的 syslog 级别
+var levels = map[string]int{
+	"PANIC": 0, "FATAL": 2, "ERROR": 3, "WARN": 4, "INFO": 6, "DEBUG": 7, "TRACE": 7,
+}
+
+// Writer 将写入的日志行作为 GELF 消息发送，实现 io.Writer
+type Writer struct {
+	network   string
+	addr      string
+	host      string
+	facility  string
+	chunkSize int
+	compress  bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Option GELF 输出选项
+type Option func(*Writer)
+
+// WithHost 指定消息的 host 字段，默认为主机名
+func WithHost(host string) Option { return func(w *Writer) { w.host = host } }
+
+// WithFacility 指定附加字段 _facility，通常为应用名
+func WithFacility(facility string) Option { return func(w *Writer) { w.facility = facility } }
+
+// WithChunkSize 指定 UDP 分块大小，默认 DefaultChunkSize
+func WithChunkSize(size int) Option { return func(w *Writer) { w.chunkSize = size } }
+
+// WithCompress 指定 UDP 消息是否 gzip 压缩，默认压缩
+func WithCompress(v bool) Option { return func(w *Writer) { w.compress = v } }
+
+// New 创建发送到 addr 的 GELF 输出，network 为 udp 或者 tcp
+func New(network, addr string, opts ...Option) (*Writer, error) {
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("gelf: unsupported network %q", network)
+	}
+
+	host, _ := os.Hostname()
+	w := &Writer{
+		network:   network,
+		addr:      addr,
+		host:      host,
+		chunkSize: DefaultChunkSize,
+		compress:  true,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.chunkSize <= chunkHeaderSize {
+		w.chunkSize = DefaultChunkSize
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write 将每一行日志作为一条 GELF 消息发送
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, line := range logline.Split(p) {
+		msg, err := w.message(line)
+		if err != nil {
+			return 0, err
+		}
+		if err := w.send(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close 关闭网络连接
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func (w *Writer) dial() error {
+	conn, err := net.DialTimeout(w.network, w.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	w.conn = conn
+	return nil
+}
+
+// message 将日志行转换为 GELF 1.1 JSON 消息
+func (w *Writer) message(line []byte) ([]byte, error) {
+	l := logline.Parse(line)
+	level, ok := levels[l.Level]
+	if !ok {
+		level = levels["INFO"]
+	}
+
+	m := map[string]any{
+		"version":       "1.1",
+		"host":          w.host,
+		"short_message": string(l.Msg),
+		"timestamp":     float64(l.Time.UnixMilli()) / 1e3,
+		"level":         level,
+	}
+	if w.facility != "" {
+		m["_facility"] = w.facility
+	}
+	if l.TraceID != "" {
+		m["_trace_id"] = l.TraceID
+	}
+	return json.Marshal(m)
+}
+
+// send 发送一条消息，TCP 以 \0 分隔，UDP 必要时压缩、分块，连接断开时重连一次
+func (w *Writer) send(msg []byte) error {
+	if w.conn == nil {
+		if err := w.dial(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if w.network == "tcp" {
+		_, err = w.conn.Write(append(msg, 0))
+	} else {
+		err = w.sendUDP(msg)
+	}
+	if err != nil && !errors.Is(err, ErrTooLarge) {
+		w.conn.Close()
+		w.conn = nil
+	}
+	return err
+}
+
+func (w *Writer) sendUDP(msg []byte) error {
+	if w.compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(msg)
+		gz.Close()
+		msg = buf.Bytes()
+	}
+
+	if len(msg) <= w.chunkSize {
+		_, err := w.conn.Write(msg)
+		return err
+	}
+
+	parts, err := chunks(msg, w.chunkSize)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range parts {
+		if _, err := w.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunks 将消息拆分为 GELF 分块，每块最多 chunkSize 字节（包括分块头）
+func chunks(msg []byte, chunkSize int) ([][]byte, error) {
+	size := chunkSize - chunkHeaderSize
+	count := (len(msg) + size - 1) / size
+	if count > maxChunks {
+		return nil, ErrTooLarge
+	}
+
+	var id [8]byte
+	rand.Read(id[:])
+
+	result := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		part := msg[i*size : min(len(msg), (i+1)*size)]
+		chunk := make([]byte, 0, chunkHeaderSize+len(part))
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id[:]...)
+		chunk = append(chunk, byte(i), byte(count))
+		result = append(result, append(chunk, part...))
+	}
+	return result, nil
+}
@@ -0,0 +1,126 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUDPChunked(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := New("udp", pc.LocalAddr().String(), WithHost("h1"), WithCompress(false), WithChunkSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	long := strings.Repeat("x", 500)
+	if _, err := w.Write([]byte("2024-01-02 03:04:05.678 [WARN ] 1 --- [1     ] [-] : " + long + "\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[byte][]byte{}
+	var count byte
+	buf := make([]byte, 2048)
+	for pc.SetReadDeadline(time.Now().Add(5 * time.Second)); count == 0 || len(parts) < int(count); {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 100 || buf[0] != 0x1e || buf[1] != 0x0f {
+			t.Fatalf("unexpected chunk of %d bytes", n)
+		}
+		count = buf[11]
+		parts[buf[10]] = append([]byte(nil), buf[12:n]...)
+	}
+
+	var msg []byte
+	for i := byte(0); i < count; i++ {
+		msg = append(msg, parts[i]...)
+	}
+	m := decode(t, msg)
+	if m["short_message"] != long || m["level"] != float64(4) || m["host"] != "h1" || m["version"] != "1.1" {
+		t.Fatalf("unexpected message %v", m)
+	}
+}
+
+func TestUDPCompressed(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	w, err := New("udp", pc.LocalAddr().String(), WithFacility("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte("hello\n"))
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(buf[:n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := io.ReadAll(gz)
+	if m := decode(t, msg); m["short_message"] != "hello" || m["level"] != float64(6) || m["_facility"] != "app" {
+		t.Fatalf("unexpected message %v", m)
+	}
+}
+
+func TestTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w, err := New("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w.Write([]byte("one\ntwo\n"))
+	r := bufio.NewReader(conn)
+	for _, want := range []string{"one", "two"} {
+		msg, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := decode(t, msg[:len(msg)-1]); m["short_message"] != want {
+			t.Fatalf("unexpected message %v", m)
+		}
+	}
+}
+
+func decode(t *testing.T, msg []byte) map[string]any {
+	var m map[string]any
+	if err := json.Unmarshal(msg, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
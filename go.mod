@@ -5,15 +5,23 @@ go 1.21
 toolchain go1.21.5
 
 require (
-	github.com/bingoohuang/q v0.0.0-20240327074618-3ac50e6530c2
+	github.com/klauspost/compress v1.17.9
 	github.com/kortschak/goroutine v1.1.1
-	golang.org/x/sys v0.20.0
-	golang.org/x/term v0.20.0
+	go.opentelemetry.io/proto/otlp v1.3.1
+	golang.org/x/sys v0.21.0
+	golang.org/x/term v0.21.0
+	google.golang.org/grpc v1.64.1
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
 )
 
 require (
+	github.com/grpc-ecosystem/grpc-gateway/v2 v2.20.0 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/rogpeppe/go-internal v1.12.0 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/api v0.0.0-20240513163218-0867130af1f8 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240513163218-0867130af1f8 // indirect
+	google.golang.org/protobuf v1.34.1 // indirect
 )
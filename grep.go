@@ -0,0 +1,118 @@
+package rotatefile
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Grep 在当前日志文件及历史文件（包括压缩文件）中搜索匹配正则 pattern 的行，按时间顺序流式返回
+// since/until 用于根据历史文件名中的滚动时间（启用 Manifest 时结合清单记录的起止时间）跳过无关文件，零值表示不限制
+// 注意：只按文件粒度筛选，返回的行不按行内时间再次过滤
+func (l *file) Grep(pattern string, since, until time.Time) (io.ReadCloser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	err = l.resolveFileName()
+	paths := l.loadPaths()
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return l.grepBetween(re, paths.dir, paths.filename, since, until)
+}
+
+// grepBetween 按时间顺序在目录 dir 下的日志文件 filename 及其历史文件中与 [since, until] 有交集的文件里搜索匹配 re 的行，
+// re 为空时返回全部内容
+func (l *file) grepBetween(re *regexp.Regexp, dir, filename string, since, until time.Time) (io.ReadCloser, error) {
+	files, err := l.oldLogFiles()
+	if err != nil {
+		return nil, err
+	}
+	files = l.uniqueBackups(files)
+
+	// 历史文件的滚动时间是其内容的结束时间，上一个历史文件的滚动时间是其内容的开始时间，
+	// 上一个历史文件已被清理时，使用清单中记录的开始时间
+	entries := l.manifestEntries()
+	var names []string
+	var start time.Time
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		if e, ok := entries[f.Name]; ok && start.IsZero() {
+			start = e.First
+		}
+		if overlaps(start, f.timestamp, since, until) {
+			names = append(names, filepath.Join(dir, f.Name))
+		}
+		start = f.timestamp
+	}
+	if overlaps(start, time.Time{}, since, until) {
+		names = append(names, filename)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(l.grepFiles(pw, re, names))
+	}()
+
+	return pr, nil
+}
+
+// overlaps 判断时间段 [start, end] 与 [since, until] 是否有交集，零值表示不限制
+func overlaps(start, end, since, until time.Time) bool {
+	if !since.IsZero() && !end.IsZero() && end.Before(since) {
+		return false
+	}
+	if !until.IsZero() && !start.IsZero() && start.After(until) {
+		return false
+	}
+	return true
+}
+
+// grepFiles 依次在文件 names 中搜索匹配 re 的行，写入 w
+func (l *file) grepFiles(w io.Writer, re *regexp.Regexp, names []string) error {
+	for _, name := range names {
+		if err := l.grepFile(w, re, name); err != nil {
+			if os.IsNotExist(err) { // 可能已被清理或压缩
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// grepFile 在文件 name 中搜索匹配 re 的行，写入 w，压缩文件解压后搜索
+func (l *file) grepFile(w io.Writer, re *regexp.Regexp, name string) error {
+	r, err := l.openBackup(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && (re == nil || re.Match(line)) {
+			if line[len(line)-1] != '\n' {
+				line = append(line, '\n')
+			}
+			if _, werr := w.Write(line); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
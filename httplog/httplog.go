@@ -0,0 +1,202 @@
+// Package httplog 提供 net/http 访问日志中间件，将 Apache 或 JSON 格式的访问日志写入专用的滚动日志文件
+//
+//	access := rotatefile.New(rotatefile.WithFilename("/var/log/app/access.log"))
+//	http.ListenAndServe(":8080", httplog.New(access, httplog.Combined).Middleware(mux))
+//
+// gin/echo 等框架可以在其中间件中使用 NewEntry 构造记录后调用 Logger.Log，例如 gin：
+//
+//	r.Use(func(c *gin.Context) {
+//		start := time.Now()
+//		c.Next()
+//		logger.Log(httplog.NewEntry(c.Request, start, c.Writer.Status(), int64(c.Writer.Size())))
+//	})
+package httplog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Format 访问日志格式
+type Format int
+
+const (
+	// Combined Apache combined 日志格式
+	Combined Format = iota
+	// Common Apache common 日志格式
+	Common
+	// JSON 每行一个 JSON 对象
+	JSON
+)
+
+// apacheTimeFormat Apache 访问日志中的时间格式
+const apacheTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// Entry 一次请求的访问日志记录
+type Entry struct {
+	Time       time.Time     `json:"time"`
+	RemoteAddr string        `json:"remoteAddr"`
+	User       string        `json:"user,omitempty"`
+	Method     string        `json:"method"`
+	URI        string        `json:"uri"`
+	Proto      string        `json:"proto"`
+	Status     int           `json:"status"`
+	Size       int64         `json:"size"`
+	Duration   time.Duration `json:"duration"`
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"userAgent,omitempty"`
+}
+
+// NewEntry 根据请求 r、开始时间 start、响应状态码 status 以及响应体大小 size 构造访问日志记录
+func NewEntry(r *http.Request, start time.Time, status int, size int64) Entry {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := ""
+	if r.URL != nil && r.URL.User != nil {
+		user = r.URL.User.Username()
+	} else if u, _, ok := r.BasicAuth(); ok {
+		user = u
+	}
+
+	return Entry{
+		Time:       start,
+		RemoteAddr: host,
+		User:       user,
+		Method:     r.Method,
+		URI:        r.RequestURI,
+		Proto:      r.Proto,
+		Status:     status,
+		Size:       size,
+		Duration:   time.Since(start),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+	}
+}
+
+// Logger 访问日志记录器
+type Logger struct {
+	w      io.Writer
+	format Format
+}
+
+// New 创建访问日志记录器，日志写入 w，通常为专用的 rotatefile.RotateFile
+func New(w io.Writer, format Format) *Logger {
+	return &Logger{w: w, format: format}
+}
+
+// Log 按格式写入一条访问日志，每条日志一次写入，避免与其它写入穿插
+func (l *Logger) Log(e Entry) error {
+	_, err := l.w.Write(l.AppendEntry(nil, e))
+	return err
+}
+
+// AppendEntry 将格式化后的访问日志（包括换行符）追加到 b 中
+func (l *Logger) AppendEntry(b []byte, e Entry) []byte {
+	if l.format == JSON {
+		data, _ := json.Marshal(e)
+		return append(append(b, data...), '\n')
+	}
+
+	b = append(b, dash(e.RemoteAddr)...)
+	b = append(b, " - "...)
+	b = append(b, dash(e.User)...)
+	b = append(b, " ["...)
+	b = e.Time.AppendFormat(b, apacheTimeFormat)
+	b = append(b, "] \""...)
+	b = append(b, e.Method...)
+	b = append(b, ' ')
+	b = append(b, e.URI...)
+	b = append(b, ' ')
+	b = append(b, e.Proto...)
+	b = append(b, "\" "...)
+	b = strconv.AppendInt(b, int64(e.Status), 10)
+	b = append(b, ' ')
+	if e.Size > 0 {
+		b = strconv.AppendInt(b, e.Size, 10)
+	} else {
+		b = append(b, '-')
+	}
+	if l.format == Combined {
+		b = append(b, " "...)
+		b = strconv.AppendQuote(b, e.Referer)
+		b = append(b, " "...)
+		b = strconv.AppendQuote(b, e.UserAgent)
+	}
+	return append(b, '\n')
+}
+
+// dash 空值在 Apache 日志格式中记为 -
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// Middleware 返回记录访问日志的 net/http 中间件
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			l.Log(NewEntry(r, start, rw.status, rw.size))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// Middleware 返回将访问日志写入 w 的 net/http 中间件
+func Middleware(w io.Writer, format Format) func(http.Handler) http.Handler {
+	return New(w, format).Middleware
+}
+
+// responseWriter 记录响应状态码及响应体大小
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush 实现 http.Flusher，以支持流式响应
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 实现 http.Hijacker，以支持 WebSocket 等协议升级
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("httplog: ResponseWriter does not implement http.Hijacker")
+}
+
+// Unwrap 返回原始的 http.ResponseWriter，供 http.ResponseController 使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
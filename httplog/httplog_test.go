@@ -0,0 +1,60 @@
+package httplog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCombined(t *testing.T) {
+	var buf bytes.Buffer
+	h := Middleware(&buf, Combined)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest("POST", "/foo?bar=1", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", "curl/8.0")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	line := buf.String()
+	if !strings.HasPrefix(line, "10.0.0.1 - - [") {
+		t.Fatalf("unexpected line %q", line)
+	}
+	if want := `] "POST /foo?bar=1 HTTP/1.1" 201 5 "http://example.com/" "curl/8.0"` + "\n"; !strings.HasSuffix(line, want) {
+		t.Fatalf("line %q does not end with %q", line, want)
+	}
+}
+
+func TestCommon(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Common)
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.Log(Entry{Time: start, RemoteAddr: "::1", User: "bob", Method: "GET", URI: "/", Proto: "HTTP/1.0", Status: 304})
+
+	if want := "::1 - bob [02/Jan/2024:03:04:05 +0000] \"GET / HTTP/1.0\" 304 -\n"; buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	h := Middleware(&buf, JSON)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/bar", nil))
+
+	var e Entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Method != "GET" || e.URI != "/bar" || e.Status != http.StatusOK || e.Size != 2 {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+}
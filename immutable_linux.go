@@ -0,0 +1,31 @@
+package rotatefile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsImmutableFl 文件不可修改、删除、重命名的属性，即 chattr +i
+const fsImmutableFl = 0x00000010
+
+// setImmutable 设置或者清除文件 name 的不可修改属性，需要 CAP_LINUX_IMMUTABLE 权限
+func setImmutable(name string, on bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fd := int(f.Fd())
+	flags, err := unix.IoctlGetInt(fd, unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return err
+	}
+	if on {
+		flags |= fsImmutableFl
+	} else {
+		flags &^= fsImmutableFl
+	}
+	return unix.IoctlSetPointerInt(fd, unix.FS_IOC_SETFLAGS, flags)
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package rotatefile
+
+// setImmutable 仅 Linux 支持不可修改属性
+func setImmutable(string, bool) error { return nil }
@@ -0,0 +1,129 @@
+package rotatefile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/bingoohuang/rotatefile/disk"
+)
+
+// DirStats 日志目录的容量统计
+type DirStats struct {
+	// Dir 日志目录
+	Dir string `json:"dir"`
+	// DiskTotal 日志目录所在磁盘的总大小
+	DiskTotal uint64 `json:"diskTotal"`
+	// DiskFree 日志目录所在磁盘的空余大小
+	DiskFree uint64 `json:"diskFree"`
+	// DiskInodes 日志目录所在磁盘的 inode 总数，文件系统没有固定 inode 数时为 0
+	DiskInodes uint64 `json:"diskInodes"`
+	// DiskFreeInodes 日志目录所在磁盘的空余 inode 数
+	DiskFreeInodes uint64 `json:"diskFreeInodes"`
+	// Logs 按日志文件名分组的统计
+	Logs []LogStats `json:"logs"`
+}
+
+// LogStats 单个日志文件及其历史文件的统计
+type LogStats struct {
+	// Name 日志文件名
+	Name string `json:"name"`
+	// Size 当前日志文件大小，不存在时为 0
+	Size int64 `json:"size"`
+	// Backups 历史文件个数
+	Backups int `json:"backups"`
+	// BackupsSize 历史文件总大小
+	BackupsSize int64 `json:"backupsSize"`
+	// Compressed 已压缩的历史文件个数
+	Compressed int `json:"compressed"`
+	// CompressedSize 已压缩的历史文件总大小
+	CompressedSize int64 `json:"compressedSize"`
+	// OriginalSize 已压缩的历史文件解压后的总大小，未统计时为 0
+	OriginalSize int64 `json:"originalSize,omitempty"`
+	// Oldest 最老的历史文件的滚动时间
+	Oldest time.Time `json:"oldest"`
+	// Newest 最新的历史文件的滚动时间
+	Newest time.Time `json:"newest"`
+}
+
+// Ratio 压缩率，即压缩后大小与原大小之比，未统计时返回 0
+func (s LogStats) Ratio() float64 {
+	if s.OriginalSize == 0 {
+		return 0
+	}
+	return float64(s.CompressedSize) / float64(s.OriginalSize)
+}
+
+// InspectDir 不写入日志，统计目录 dir 中 rotatefile 格式的历史文件（包括其它程序写入的），
+// 按日志文件名分组，ratio 为 true 时解压已压缩的历史文件以统计压缩率，目录较大时耗时较长
+func InspectDir(dir string, ratio bool, fns ...ConfigFn) (*DirStats, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &file{Config: createConfig(fns...)}
+	l.setDir(dir)
+	stats := &DirStats{Dir: dir}
+	if info, err := disk.GetInfo(dir, false); err == nil {
+		stats.DiskTotal, stats.DiskFree = info.Total, info.Free
+		stats.DiskInodes, stats.DiskFreeInodes = info.Files, info.Ffree
+	}
+
+	logs := map[string]*LogStats{}
+	for _, e := range entries {
+		active, t, ok := l.splitAnyBackupName(e.Name())
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		s := logs[active]
+		if s == nil {
+			s = &LogStats{Name: active, Oldest: t, Newest: t}
+			if fi, err := os.Stat(filepath.Join(dir, active)); err == nil {
+				s.Size = fi.Size()
+			}
+			logs[active] = s
+		}
+		s.Backups++
+		s.BackupsSize += info.Size()
+		if t.Before(s.Oldest) {
+			s.Oldest = t
+		}
+		if t.After(s.Newest) {
+			s.Newest = t
+		}
+		if l.compressorOf(e.Name()) != nil {
+			s.Compressed++
+			s.CompressedSize += info.Size()
+			if ratio {
+				n, _ := l.originalSize(filepath.Join(dir, e.Name()))
+				s.OriginalSize += n
+			}
+		}
+	}
+
+	for _, s := range logs {
+		stats.Logs = append(stats.Logs, *s)
+	}
+	sort.Slice(stats.Logs, func(i, j int) bool {
+		return stats.Logs[i].Name < stats.Logs[j].Name
+	})
+	return stats, nil
+}
+
+// originalSize 解压压缩文件 name，返回解压后的大小
+func (l *file) originalSize(name string) (int64, error) {
+	r, err := l.openBackup(name)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(io.Discard, r)
+}
@@ -0,0 +1,62 @@
+// Package logline 解析 stdlog 格式的日志行，供各个日志导出目标共用
+package logline
+
+import (
+	"bytes"
+	"regexp"
+	"time"
+)
+
+// TimeFormat stdlog 日志行的时间格式
+const TimeFormat = "2006-01-02 15:04:05.000"
+
+// Line 解析后的日志行
+type Line struct {
+	// Time 日志时间，无法解析时为当前时间
+	Time time.Time
+	// Level 日志级别，例如 INFO、ERROR，无法解析时为 INFO
+	Level string
+	// Msg 日志内容，无法解析时为整行
+	Msg []byte
+	// TraceID 日志内容中的 trace id（小写十六进制），没有时为空
+	TraceID string
+}
+
+// traceIDRe 匹配日志内容中的 trace id，例如 trace_id=4bf92f3577b34da6a3ce929d0e0e4736
+var traceIDRe = regexp.MustCompile(`(?i)trace_?id[=:"\s]+([0-9a-f]{32})`)
+
+// Parse 解析 stdlog 格式的日志行：2006-01-02 15:04:05.000 [INFO ] pid --- [gid] [caller] : msg
+func Parse(line []byte) Line {
+	l := Line{Time: time.Now(), Level: "INFO", Msg: line}
+
+	if len(line) > len(TimeFormat) {
+		if t, err := time.ParseInLocation(TimeFormat, string(line[:len(TimeFormat)]), time.Local); err == nil {
+			l.Time = t
+			rest := line[len(TimeFormat):]
+			if i, j := bytes.IndexByte(rest, '['), bytes.IndexByte(rest, ']'); i >= 0 && j > i {
+				if level := bytes.TrimSpace(rest[i+1 : j]); len(level) > 0 {
+					l.Level = string(level)
+				}
+			}
+			if i := bytes.Index(rest, []byte(" : ")); i >= 0 {
+				l.Msg = rest[i+3:]
+			}
+		}
+	}
+
+	if m := traceIDRe.FindSubmatch(l.Msg); m != nil {
+		l.TraceID = string(bytes.ToLower(m[1]))
+	}
+	return l
+}
+
+// Split 将写入内容按行拆分，忽略空行
+func Split(p []byte) [][]byte {
+	var lines [][]byte
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,98 @@
+// Package kafka 将日志行或者已完成的历史文件信息发送到 Kafka 主题，下游可以直接消费，无需单独部署采集代理
+//
+// 为了不绑定具体的 Kafka 客户端，发送由 Producer 接口完成，使用方基于 sarama、franz-go 等客户端实现，例如 franz-go：
+//
+//	sink := &kafka.Sink{Topic: "app-logs", Producer: kafka.ProducerFunc(func(topic string, key, value []byte) error {
+//		return client.ProduceSync(ctx, &kgo.Record{Topic: topic, Key: key, Value: value}).FirstErr()
+//	})}
+//	rotatefile.New(rotatefile.WithTee(sink))                // 发送每一行日志
+//	rotatefile.New(rotatefile.WithOnBackup(sink.Notify))    // 发送已完成的历史文件信息
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Producer Kafka 生产者
+type Producer interface {
+	Produce(topic string, key, value []byte) error
+}
+
+// ProducerFunc 函数形式的 Producer
+type ProducerFunc func(topic string, key, value []byte) error
+
+// Produce 实现 Producer
+func (f ProducerFunc) Produce(topic string, key, value []byte) error { return f(topic, key, value) }
+
+// Sink 发送日志到 Kafka 主题
+type Sink struct {
+	Producer Producer
+	// Topic Kafka 主题
+	Topic string
+	// Key 消息 key，例如主机名，使同一来源的消息进入同一分区，保持顺序
+	Key []byte
+	// OnError Notify 发送失败时的回调
+	OnError func(err error)
+}
+
+// Write 将每一行日志作为一条消息发送，用作 rotatefile 的 Tee 输出目标，
+// 发送失败时返回已经发送的行的字节数
+func (s *Sink) Write(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		line, next := p[n:], len(p)
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line, next = line[:i], n+i+1
+		}
+		if len(line) > 0 {
+			if err := s.Producer.Produce(s.Topic, s.Key, line); err != nil {
+				return n, err
+			}
+		}
+		n = next
+	}
+	return n, nil
+}
+
+// BackupFile 已完成的历史文件信息
+type BackupFile struct {
+	Path    string    `json:"path"`
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Host    string    `json:"host"`
+}
+
+// Notify 将已完成的历史文件信息（JSON）作为一条消息发送，用作 rotatefile.WithOnBackup 的回调
+func (s *Sink) Notify(path string) {
+	if err := s.notify(path); err != nil && s.OnError != nil {
+		s.OnError(err)
+	}
+}
+
+func (s *Sink) notify(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	host, _ := os.Hostname()
+	value, err := json.Marshal(BackupFile{
+		Path:    path,
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Host:    host,
+	})
+	if err != nil {
+		return err
+	}
+	return s.Producer.Produce(s.Topic, s.Key, value)
+}
@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type message struct {
+	topic      string
+	key, value []byte
+}
+
+func TestSink(t *testing.T) {
+	var messages []message
+	s := &Sink{Topic: "logs", Key: []byte("h1"), Producer: ProducerFunc(func(topic string, key, value []byte) error {
+		messages = append(messages, message{topic: topic, key: key, value: value})
+		return nil
+	})}
+
+	if _, err := s.Write([]byte("one\ntwo\n")); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || string(messages[0].value) != "one" || string(messages[1].value) != "two" ||
+		messages[0].topic != "logs" || string(messages[0].key) != "h1" {
+		t.Fatalf("unexpected messages %q", messages)
+	}
+
+	backup := filepath.Join(t.TempDir(), "app.20240102T030405.000.log.gz")
+	if err := os.WriteFile(backup, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.Notify(backup)
+
+	var f BackupFile
+	if err := json.Unmarshal(messages[2].value, &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Path != backup || f.Name != filepath.Base(backup) || f.Size != 4 {
+		t.Fatalf("unexpected backup file %+v", f)
+	}
+
+	// 发送失败时返回已经发送的字节数
+	failed := errors.New("broker down")
+	s.Producer = ProducerFunc(func(topic string, key, value []byte) error {
+		if string(value) == "two" {
+			return failed
+		}
+		return nil
+	})
+	if n, err := s.Write([]byte("one\n\ntwo\nthree\n")); n != 5 || err != failed {
+		t.Fatalf("unexpected write result %d %v", n, err)
+	}
+
+	var notifyErr error
+	s.OnError = func(err error) { notifyErr = err }
+	s.Notify(filepath.Join(t.TempDir(), "missing.log"))
+	if notifyErr == nil {
+		t.Fatal("expected error for missing backup")
+	}
+}
@@ -4,10 +4,16 @@
 package rotatefile
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"syscall"
 	"testing"
 	"time"
+
+	"github.com/bingoohuang/rotatefile/flock"
 )
 
 func TestMaintainMode(t *testing.T) {
@@ -170,10 +176,285 @@ func TestCompressMaintainOwner(t *testing.T) {
 	<-time.After(10 * time.Millisecond)
 
 	// a compressed version of the log file should now exist with the correct
-	// owner.
+	// owner. The owner is set on the temp file, which is then renamed.
 	filename2 := backupFile(dir)
-	equals(555, fakeFS.files[filename2+compressSuffix].uid, t)
-	equals(666, fakeFS.files[filename2+compressSuffix].gid, t)
+	exists(filename2+compressSuffix, t)
+	equals(555, fakeFS.files[filename2+compressSuffix+compressTmpSuffix].uid, t)
+	equals(666, fakeFS.files[filename2+compressSuffix+compressTmpSuffix].gid, t)
+}
+
+func TestExplicitOwner(t *testing.T) {
+	fakeFS := newFakeFS()
+	osChown = fakeFS.Chown
+	osStat = fakeFS.Stat
+	defer func() {
+		osChown = os.Chown
+		osStat = os.Stat
+	}()
+	currentTime = fakeTime
+	dir := makeTempDir("TestExplicitOwner", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename: filename,
+		Compress: true,
+		Owner:    "777",
+		Group:    "888",
+	}}
+	defer l.Close()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	// the new logfile gets the configured owner instead of the one of the old logfile.
+	equals(777, fakeFS.files[filename].uid, t)
+	equals(888, fakeFS.files[filename].gid, t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	tmp := backupFile(dir) + compressSuffix + compressTmpSuffix
+	equals(777, fakeFS.files[tmp].uid, t)
+	equals(888, fakeFS.files[tmp].gid, t)
+}
+
+func TestDisableChown(t *testing.T) {
+	fakeFS := newFakeFS()
+	osChown = fakeFS.Chown
+	osStat = fakeFS.Stat
+	defer func() {
+		osChown = os.Chown
+		osStat = os.Stat
+	}()
+	currentTime = fakeTime
+	dir := makeTempDir("TestDisableChown", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0o644)
+	isNil(err, t)
+	f.Close()
+
+	l := &file{Config: Config{
+		Filename:     filename,
+		DisableChown: true,
+	}}
+	defer l.Close()
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	equals(0, len(fakeFS.files), t)
+}
+
+func TestPreallocate(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestPreallocate", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:    filename,
+		MaxSize:     MB,
+		Preallocate: true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	// the preallocated space doesn't change the file size.
+	existsWithContent(filename, []byte("boo!"), t)
+	info, err := os.Stat(filename)
+	isNil(err, t)
+	preallocated := info.Sys().(*syscall.Stat_t).Blocks * 512
+
+	isNil(l.Close(), t)
+	existsWithContent(filename, []byte("boo!"), t)
+
+	// the unused space is released on close, if the filesystem supports fallocate.
+	info, err = os.Stat(filename)
+	isNil(err, t)
+	if preallocated >= int64(MB) {
+		assert(info.Sys().(*syscall.Stat_t).Blocks*512 < int64(MB), t, "preallocated space not released")
+	}
+}
+
+func TestCloseOnExit(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCloseOnExit", t)
+	defer os.RemoveAll(dir)
+
+	raised := make(chan os.Signal, 1)
+	defaultRaise := raiseSignal
+	raiseSignal = func(sig os.Signal) { raised <- sig }
+	defer func() { raiseSignal = defaultRaise }()
+
+	// 应用自己的信号处理，不受影响
+	app := make(chan os.Signal, 2)
+	signal.Notify(app, syscall.SIGTERM)
+	defer signal.Stop(app)
+
+	l := &file{Config: Config{
+		Filename:    logFile(dir),
+		AsyncWrite:  true,
+		CloseOnExit: true,
+	}}
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	l.flushAsync()
+
+	isNil(syscall.Kill(os.Getpid(), syscall.SIGTERM), t)
+	select {
+	case sig := <-raised:
+		equals(syscall.SIGTERM, sig, t)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for SIGTERM handling")
+	}
+
+	assert(l.file == nil, t, "expected log file closed")
+	existsWithContent(logFile(dir), []byte("boo!"), t)
+	equals(syscall.SIGTERM, <-app, t)
+
+	// 仍然由应用处理，而不是按默认方式退出进程
+	isNil(syscall.Kill(os.Getpid(), syscall.SIGTERM), t)
+	select {
+	case sig := <-app:
+		equals(syscall.SIGTERM, sig, t)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for SIGTERM")
+	}
+}
+
+func TestCaptureCommand(t *testing.T) {
+	dir := makeTempDir("TestCaptureCommand", t)
+	defer os.RemoveAll(dir)
+
+	out := &file{Config: Config{Filename: filepath.Join(dir, "out.log")}}
+	defer out.Close()
+	errFile := &file{Config: Config{Filename: filepath.Join(dir, "err.log")}}
+	defer errFile.Close()
+
+	cmd := exec.Command("sh", "-c", `echo one; printf 'two\nthr'; echo ee >&2; printf ee; echo x`)
+	isNil(CaptureCommand(cmd, out, errFile), t)
+	isNil(cmd.Run(), t)
+	existsWithContent(filepath.Join(dir, "out.log"), []byte("sh: one\nsh: two\nsh: threex\n"), t)
+	existsWithContent(filepath.Join(dir, "err.log"), []byte("sh: ee\n"), t)
+
+	// 写入同一文件时，标准错误使用不同的前缀
+	cmd = exec.Command("sh", "-c", "echo err >&2")
+	isNil(CaptureCommand(cmd, out, nil), t)
+	isNil(cmd.Run(), t)
+	existsWithContent(filepath.Join(dir, "out.log"), []byte("sh: one\nsh: two\nsh: threex\nsh stderr: err\n"), t)
+
+	// 已经启动
+	notNil(CaptureCommand(cmd, out, nil), t)
+}
+
+func TestCaptureStderr(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCaptureStderr", t)
+	defer os.RemoveAll(dir)
+
+	saved, err := syscall.Dup(2)
+	isNil(err, t)
+	defer func() {
+		_ = syscall.Dup2(saved, 2)
+		_ = syscall.Close(saved)
+	}()
+
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		UtcTime:  true,
+	}}
+	defer l.Close()
+
+	isNil(l.CaptureStderr(), t)
+	_, err = os.Stderr.WriteString("panic: boo\n")
+	isNil(err, t)
+	_, err = l.Write([]byte("foo\n"))
+	isNil(err, t)
+	existsWithContent(logFile(dir), []byte("panic: boo\nfoo\n"), t)
+
+	// 滚动后重定向到新的日志文件
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	_, err = os.Stderr.WriteString("after\n")
+	isNil(err, t)
+	existsWithContent(backupFile(dir), []byte("panic: boo\nfoo\n"), t)
+	existsWithContent(logFile(dir), []byte("after\n"), t)
+}
+
+func TestStaleLock(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestStaleLock", t)
+	defer os.RemoveAll(dir)
+
+	// 已经退出的进程
+	cmd := exec.Command("true")
+	isNil(cmd.Run(), t)
+	dead := cmd.Process.Pid
+	hostname, _ := os.Hostname()
+
+	// 锁被持有，即使记录的持有者是本机已经退出的进程（例如同一 Pod 中不同 PID 命名空间的容器），也不删除锁文件
+	lockPath := filepath.Join(dir, "app.log.lock")
+	held := flock.New(lockPath)
+	ok, err := held.TryLock()
+	isNil(err, t)
+	assert(ok, t, "lock not acquired")
+	defer held.Unlock()
+
+	isNil(os.WriteFile(lockPath, []byte(fmt.Sprintf("\n%d@%s", dead, hostname)), 0o600), t)
+	o := FilenameOptions{AppName: "app", DirCandidates: []string{dir}, NameTemplate: "{app}.log", TryLock: true}
+	filename, lock, err := GenerateFilename(o)
+	isNil(err, t)
+	defer lock.Unlock()
+	equals(filepath.Join(dir, "app."+pid+".log"), filename, t)
+	existsWithContent(lockPath, []byte(fmt.Sprintf("\n%d@%s", dead, hostname)), t)
+	// 带进程号的文件名同样被锁定
+	equals(filepath.Join(dir, "app."+pid+".log.lock"), lock.Path(), t)
+	equals(true, lock.Locked(), t)
+
+	l := &file{Config: Config{Filename: filepath.Join(dir, "app.log"), MaxBackups: 10, SyncMill: true}}
+	l.setPaths(filename, lock)
+	mtime := fakeTime().Add(-time.Hour)
+	orphan := func(owner int, holder string) (string, string) {
+		name := filepath.Join(dir, fmt.Sprintf("app.%d.log", owner))
+		isNil(os.WriteFile(name, []byte("orphan"), 0o644), t)
+		isNil(os.Chtimes(name, mtime, mtime), t)
+		if holder != "" {
+			isNil(os.WriteFile(name+".lock", []byte(fmt.Sprintf("\n%d@%s", owner, holder)), 0o600), t)
+		}
+		return name, name + ".lock"
+	}
+	backup := backupName(filepath.Join(dir, "app.log"), mtime, false, "")
+
+	// 没有锁文件，或者锁文件记录的是其它主机（例如共享的 NFS 目录），无法判断写入的进程是否已经退出
+	name, _ := orphan(dead, "")
+	l.adoptOrphanLogs()
+	exists(name, t)
+	name, _ = orphan(dead, "other-host")
+	l.adoptOrphanLogs()
+	exists(name, t)
+
+	// 锁仍被持有，写入的进程仍然存活
+	name, orphanLock := orphan(dead, hostname)
+	live := flock.New(orphanLock)
+	ok, err = live.TryLock()
+	isNil(err, t)
+	assert(ok, t, "lock not acquired")
+	l.adoptOrphanLogs()
+	exists(name, t)
+	isNil(live.Unlock(), t)
+
+	// 本机已经退出的进程遗留的 {name}.{pid}.log 作为历史文件
+	l.adoptOrphanLogs()
+	notExist(name, t)
+	notExist(orphanLock, t)
+	existsWithContent(backup, []byte("orphan"), t)
 }
 
 type fakeFile struct {
@@ -1,61 +1,124 @@
 package rotatefile
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"os/user"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 
-	"github.com/bingoohuang/q"
 	"github.com/bingoohuang/rotatefile/flock"
 )
 
-// getLogFileName 获取可执行文件 binName 的日志文件路径
-func getLogFileName(appName, logDir, prefix, logName string, tryLock bool) (string, *flock.Flock) {
-	if p := FindLogDir(appName, logDir); p != "" {
-		if logName == "" {
-			logName = appName + currentDirBase + ".log"
-		}
+// ErrNoLogDir 所有候选的日志目录均不可写
+var ErrNoLogDir = errors.New("rotatefile: no writable log directory")
+
+// DefaultNameTemplate 自动生成日志文件名的默认模板，{app} 为应用名称，{wd} 为 _{工作目录名}（取不到工作目录时为空），
+// 另外支持 {hostname}、{pod}、{pid} 占位符
+const DefaultNameTemplate = "{app}{wd}.log"
+
+// FilenameOptions 日志文件路径的生成选项，其它工具可以据此计算出与 rotatefile 一致的日志文件路径
+type FilenameOptions struct {
+	// AppName 应用名称，用于默认的日志目录及自动生成的文件名
+	AppName string
+	// Prefix 自动生成的文件名前缀
+	Prefix string
+	// Filename 配置的日志文件（以 .log 结尾）或者日志目录，为空时按 DirCandidates 查找日志目录
+	Filename string
+	// DirCandidates Filename 未指定目录或者目录不可写时，依次尝试的日志目录，为空时使用 LogDirCandidates(AppName)
+	DirCandidates []string
+	// NameTemplate 自动生成的文件名模板，为空时使用 DefaultNameTemplate
+	NameTemplate string
+	// TryLock 是否尝试锁定日志文件，已被其它进程锁定时，在文件名中加上进程号
+	TryLock bool
+	// LockDir 锁文件所在目录，为空时锁文件 {日志文件名}.lock 位于日志目录中
+	LockDir string
+}
+
+// GenerateFilename 根据选项 o 生成日志文件的完整路径
+// 1. Filename 为 /some/path/xxx.log, 则继续保持
+// 2. Filename 为 /some/path/, 则按 NameTemplate 补齐日志文件名，默认为: {appName}{currentDirBase}.log
+// 3. Filename 为 空, 则根据 DirCandidates 查找可写的日志目录，日志文件名见上
+// 所有目录均不可写时，返回 ErrNoLogDir
+func GenerateFilename(o FilenameOptions) (string, *flock.Flock, error) {
+	logDir, logName := o.Filename, ""
+	if strings.HasSuffix(o.Filename, ".log") {
+		// 配置的是具体的日志文件名称（推荐的配置）
+		logDir = filepath.Dir(o.Filename)
+		logName = filepath.Base(o.Filename)
+	}
 
-		var logLock *flock.Flock
-		if tryLock {
-			logLock = flock.New(filepath.Join(p, logName+".lock"))
-			if lock, _ := logLock.TryLock(); !lock {
-				logName = logName[:len(logName)-len(".log")] + "." + pid + ".log"
-			}
+	candidates := o.DirCandidates
+	if len(candidates) == 0 {
+		candidates = LogDirCandidates(o.AppName)
+	}
+	if logDir != "" {
+		candidates = append([]string{logDir}, candidates...)
+	}
+
+	p := firstWritableDir(candidates)
+	if p == "" {
+		return "", nil, fmt.Errorf("%w, tried %s", ErrNoLogDir, strings.Join(candidates, ", "))
+	}
+
+	if logName == "" {
+		// 否则当做日志路径看待，日志文件名自动补全
+		logName = o.name()
+	}
+
+	var logLock *flock.Flock
+	if o.TryLock {
+		var lock bool
+		if logLock, lock = tryLockLogName(lockPath(o.LockDir, p, logName)); !lock {
+			logName = logName[:len(logName)-len(".log")] + "." + pid + ".log"
+			// 同样锁定带进程号的文件名，以便其它进程判断本进程是否已经退出，见 adoptOrphanLogs
+			logLock, _ = tryLockLogName(lockPath(o.LockDir, p, logName))
 		}
-		logFileName := filepath.Join(p, prefix+logName)
-		writeLogFile(logFileName)
-		return logFileName, logLock
 	}
+	logFileName := filepath.Join(p, o.Prefix+logName)
+	registerProcessLog(o.AppName, logFileName)
+	return logFileName, logLock, nil
+}
 
-	panic("日志已经无处安放，君欲何为？")
+// lockPath 返回日志目录 dir 下日志文件 logName 的锁文件路径，
+// 锁文件位于 lockDir（例如 /var/lock）时，文件名中带上日志目录，以免不同目录的同名日志文件冲突
+func lockPath(lockDir, dir, logName string) string {
+	if lockDir == "" {
+		return filepath.Join(dir, logName+".lock")
+	}
+	_ = os.MkdirAll(lockDir, 0o755)
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	name := strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(filepath.Join(dir, logName))
+	return filepath.Join(lockDir, strings.TrimLeft(name, "_")+".lock")
 }
 
-// GetFilename 获得当前进程的日志文件路径
-func GetFilename() string {
-	logdirFile := filepath.Join(os.TempDir(), "logfile."+pid)
-	logfile, _ := os.ReadFile(logdirFile)
-	return string(logfile)
+// name 按 NameTemplate 生成日志文件名
+func (o FilenameOptions) name() string {
+	tmpl := o.NameTemplate
+	if tmpl == "" {
+		tmpl = DefaultNameTemplate
+	}
+	tmpl = strings.NewReplacer("{app}", o.AppName, "{wd}", currentDirBase).Replace(tmpl)
+	return expandPlaceholders(tmpl)
 }
 
 var pid = strconv.Itoa(os.Getpid())
 
-func writeLogFile(logFileName string) {
-	q.Q(logFileName)
-	logdirFile := filepath.Join(os.TempDir(), "logfile."+pid)
-	_ = q.AppendFile(logdirFile, []byte(logFileName+"\n"), os.ModePerm)
-}
-
-func handleSigint(f func()) {
+// handleSigint 收到 SIGINT/SIGTERM 时调用 f，只处理一次，之后停止接收，不影响应用自己的 signal.Notify
+func handleSigint(f func(sig os.Signal)) {
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt)
 	signal.Notify(ch, syscall.SIGTERM)
 	go func() {
-		<-ch
-		f()
+		sig := <-ch
+		signal.Stop(ch)
+		f(sig)
 	}()
 }
 
@@ -65,28 +128,33 @@ func handleSigint(f func()) {
 // 2. $PWD/log/{appName}_{appWorkDirBase}.log
 // 3. /var/log/apps/{appName}/{appName}_{appWorkDirBase}.log
 // 4. $TMPDIR/{appName}/{appName}_{appWorkDirBase}.log
+// 都不可写时返回空
 func FindLogDir(appName, logDir string) string {
+	candidates := LogDirCandidates(appName)
 	if logDir != "" {
-		if IsDirWritable(logDir) {
-			return logDir
-		}
+		candidates = append([]string{logDir}, candidates...)
 	}
+	return firstWritableDir(candidates)
+}
 
+// LogDirCandidates 返回默认依次尝试的日志目录，见 FindLogDir 的 1~4
+func LogDirCandidates(appName string) []string {
+	var dirs []string
 	if home, _ := HomeDir(); home != "" {
-		if p := filepath.Join(home, "log", appName); IsDirWritable(p) {
-			return p
-		}
+		dirs = append(dirs, filepath.Join(home, "log", appName))
 	}
 	if wd, _ := os.Getwd(); wd != "" {
-		if p := filepath.Join(wd, "log", appName); IsDirWritable(p) {
-			return p
-		}
-	}
-	if p := filepath.Join("/var/log/apps", appName); IsDirWritable(p) {
-		return p
+		dirs = append(dirs, filepath.Join(wd, "log", appName))
 	}
-	if p := os.TempDir(); IsDirWritable(p) {
-		return p
+	return append(dirs, filepath.Join("/var/log/apps", appName), os.TempDir())
+}
+
+// firstWritableDir 返回 dirs 中第一个可写的目录，都不可写时返回空
+func firstWritableDir(dirs []string) string {
+	for _, dir := range dirs {
+		if dir != "" && IsDirWritable(dir) {
+			return dir
+		}
 	}
 	return ""
 }
@@ -0,0 +1,246 @@
+package rotatefile
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Manager 管理同一目录下的多个日志流（例如 access.log、error.log、audit.log），
+// 所有日志流共享一个清理协程，以及一个目录级的 TotalSizeCap/MinDiskFree/MinFreeInodes/MaxDiskUsage 额度，
+// 避免各个日志文件独立控制总大小，互相争抢磁盘
+type Manager struct {
+	dir    string
+	config Config
+
+	mu      sync.Mutex
+	streams map[string]*file
+	names   []string
+
+	millOnce sync.Once
+	millCh   chan bool
+	millDone millSignal
+
+	watchMu   sync.Mutex
+	watchStop func()
+}
+
+// NewManager 创建目录 dir 下的日志流管理器，fns 为所有日志流共享的配置
+func NewManager(dir string, fns ...ConfigFn) *Manager {
+	return &Manager{
+		dir:     dir,
+		config:  createConfig(fns...),
+		streams: map[string]*file{},
+	}
+}
+
+// Open 返回名为 name（例如 access.log）的日志流，不存在时按共享配置及 fns 创建
+func (m *Manager) Open(name string, fns ...ConfigFn) RotateFile {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if l, ok := m.streams[name]; ok {
+		return l
+	}
+
+	c := m.config
+	for _, f := range fns {
+		f(&c)
+	}
+	c.Filename = filepath.Join(m.dir, name)
+	// 总大小由 Manager 统一控制
+	c.TotalSizeCap = 0
+	c.MinDiskFree = 0
+	c.MinFreeInodes = 0
+	c.MaxDiskUsage = 0
+
+	l := &file{Config: c, manager: m}
+	m.streams[name] = l
+	m.names = append(m.names, name)
+	return l
+}
+
+// Flush 刷新所有日志流到磁盘
+func (m *Manager) Flush() error {
+	var errs []error
+	for _, l := range m.files() {
+		if err := l.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 关闭所有日志流
+func (m *Manager) Close() error {
+	var errs []error
+	for _, l := range m.files() {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.stopWatchDiskUsage()
+	return errors.Join(errs...)
+}
+
+// files 按创建顺序返回所有日志流
+func (m *Manager) files() []*file {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make([]*file, 0, len(m.names))
+	for _, name := range m.names {
+		files = append(files, m.streams[name])
+	}
+	return files
+}
+
+// readyFiles 返回已生成文件名的日志流，跳过尚未写入（没有文件名）或者生成文件名失败的日志流
+func (m *Manager) readyFiles() []*file {
+	files := m.files()
+	ready := files[:0]
+	for _, l := range files {
+		if l.millReady() {
+			ready = append(ready, l)
+		}
+	}
+	return ready
+}
+
+// millChan 返回共享的清理通知通道，必要时启动清理协程
+func (m *Manager) millChan() chan bool {
+	m.millOnce.Do(func() {
+		m.millCh = make(chan bool, 1)
+		go m.millRun()
+	})
+	return m.millCh
+}
+
+// millRun 在协程中依次清理各个日志流，然后控制目录总大小
+func (m *Manager) millRun() {
+	for range m.millCh {
+		_ = m.millRunOnce()
+		m.millDone.done()
+	}
+}
+
+func (m *Manager) millRunOnce() error {
+	var errs []error
+	for _, l := range m.readyFiles() {
+		if err := l.millRunOnce(); err != nil {
+			l.millError(err)
+			errs = append(errs, err)
+		}
+	}
+	if err := m.keepTotalSizeCap(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// keepTotalSizeCap 按 CleanupStrategy（默认从最老的开始）删除所有日志流的历史文件，直到目录总大小不超过 TotalSizeCap，
+// 且磁盘剩余空间不小于 MinDiskFree（使用率不超过 MaxDiskUsage）、剩余 inode 不少于 MinFreeInodes
+func (m *Manager) keepTotalSizeCap() error {
+	if m.config.TotalSizeCap <= 0 && m.config.MinDiskFree == 0 && m.config.MinFreeInodes == 0 && m.config.MaxDiskUsage == 0 {
+		return nil
+	}
+
+	type backup struct {
+		logInfo
+		owner *file
+	}
+
+	var backups []backup
+	var totalSize int64
+	var err error
+	for _, l := range m.readyFiles() {
+		size, files, errOld := l.sizeAndBackups()
+		totalSize += size
+		if errOld != nil {
+			l.millError(errOld)
+			if err == nil {
+				err = errOld
+			}
+			continue
+		}
+		for _, f := range files {
+			backups = append(backups, backup{logInfo: f, owner: l})
+			totalSize += f.Size
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].timestamp.Before(backups[j].timestamp)
+	})
+	sort.SliceStable(backups, func(i, j int) bool {
+		a, b := backups[i], backups[j]
+		return cleanupBefore(m.config.CleanupStrategy, a.logInfo, b.logInfo,
+			a.owner.compressorOf(a.Name) != nil, b.owner.compressorOf(b.Name) != nil)
+	})
+
+	capacity := m.config.TotalSizeCap
+	minDiskFree := m.config.MinDiskFree
+	dirDiskFree, dirFreeInodes := minDiskFree, m.config.MinFreeInodes
+	if m.config.MinDiskFree > 0 || m.config.MinFreeInodes > 0 || m.config.MaxDiskUsage > 0 {
+		if dirDisk, errDisk := getDiskInfo(m.dir); errDisk == nil {
+			dirDiskFree = dirDisk.Free
+			minDiskFree = m.config.minDiskFree(dirDisk.Total)
+			if dirDisk.Files > 0 {
+				dirFreeInodes = dirDisk.Ffree
+			}
+			if dirDiskFree < minDiskFree || dirFreeInodes < m.config.MinFreeInodes {
+				m.config.emit(Event{Type: DiskLow, Path: m.dir, Free: dirDiskFree, FreeInodes: dirDisk.Ffree})
+			}
+		}
+	}
+
+	now := m.config.now()
+	for _, b := range backups {
+		if (capacity <= 0 || uint64(totalSize) <= capacity) && dirDiskFree >= minDiskFree && dirFreeInodes >= m.config.MinFreeInodes {
+			break
+		}
+		if m.config.tooYoung(b.logInfo, now) || b.owner.keepUnshipped(b.Name) {
+			continue
+		}
+		reason := capReason(capacity, totalSize, dirDiskFree, minDiskFree)
+		if m.config.MillDryRun {
+			m.config.emit(Event{Type: Planned, Path: filepath.Join(b.owner.dir(), b.Name), Reason: ActionDelete + " " + reason})
+			totalSize -= b.Size
+			dirDiskFree += uint64(b.Size)
+			dirFreeInodes++
+			continue
+		}
+		if errRemove := b.owner.removeBackup(b.Name, reason); errRemove == nil {
+			totalSize -= b.Size
+			dirDiskFree += uint64(b.Size)
+			dirFreeInodes++
+		} else {
+			b.owner.millError(errRemove)
+			if err == nil {
+				err = errRemove
+			}
+		}
+	}
+	return err
+}
+
+// sizeAndBackups 返回日志流当前的大小及历史文件，持有日志流的锁读取，以免滚动过程中（已改名、尚未重置大小）重复统计，
+// 同步清理时（SyncMill）调用方已持有锁，且已完成滚动，无需再加锁
+func (l *file) sizeAndBackups() (int64, []logInfo, error) {
+	if !l.inlineMill.Load() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+	files, err := l.oldLogFiles()
+	return l.size.Load(), files, err
+}
+
+// millReady 日志流已生成文件名且没有出错时返回 true，持有日志流的锁读取，以免与写入协程生成文件名同时进行，
+// 同步清理时（SyncMill）调用方已持有锁
+func (l *file) millReady() bool {
+	if !l.inlineMill.Load() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+	return l.filename() != "" && l.setupErr == nil
+}
@@ -0,0 +1,171 @@
+package rotatefile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifestSuffix 清单文件名后缀，完整文件名为 {日志文件名去掉扩展名}.manifest.json
+const manifestSuffix = "manifest.json"
+
+// Manifest 日志目录中历史文件的清单
+type Manifest struct {
+	// Filename 当前日志文件名
+	Filename string `json:"filename"`
+	// Updated 清单更新时间
+	Updated time.Time `json:"updated"`
+	// Backups 历史文件列表，从最老到最新
+	Backups []ManifestEntry `json:"backups"`
+}
+
+// ManifestEntry 历史文件的清单记录
+type ManifestEntry struct {
+	// Name 历史文件名（不含目录）
+	Name string `json:"name"`
+	// First 文件内容的开始时间，即上一次滚动的时间，未知时为零值
+	First time.Time `json:"first"`
+	// Last 文件内容的结束时间，即本文件滚动的时间
+	Last time.Time `json:"last"`
+	// Size 文件大小
+	Size int64 `json:"size"`
+	// SHA256 文件内容（压缩文件为压缩后内容）的 SHA-256 校验和
+	SHA256 string `json:"sha256"`
+	// Compressed 是否已经压缩
+	Compressed bool `json:"compressed"`
+	// Shipped 是否已经由 Archiver 归档
+	Shipped bool `json:"shipped,omitempty"`
+}
+
+// LoadManifest 读取清单文件
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// manifestEntries 读取清单中的历史文件记录，以文件名为键，未启用清单或者读取失败时返回 nil
+func (l *file) manifestEntries() map[string]ManifestEntry {
+	if !l.Manifest {
+		return nil
+	}
+	m, err := LoadManifest(l.manifestPath())
+	if err != nil {
+		return nil
+	}
+
+	entries := make(map[string]ManifestEntry, len(m.Backups))
+	for _, e := range m.Backups {
+		entries[e.Name] = e
+	}
+	return entries
+}
+
+// addManifestBackups 将清单中记录、仍然存在但文件名未被识别的历史文件加入 files，时间取清单中的滚动时间，
+// 例如修改 BackupMatchers、文件名日期模式等配置后，之前的历史文件仍然参与清理及搜索
+func (l *file) addManifestBackups(files []logInfo) []logInfo {
+	entries := l.manifestEntries()
+	if len(entries) == 0 {
+		return files
+	}
+
+	found := make(map[string]bool, len(files))
+	for _, f := range files {
+		found[f.Name] = true
+	}
+	for name, e := range entries {
+		path := filepath.Join(l.dir(), name)
+		if found[name] || e.Last.IsZero() || path == l.filename() || l.clean.isRemoved(name) {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			files = append(files, logInfo{timestamp: e.Last, Name: name, Size: info.Size()})
+		}
+	}
+	return files
+}
+
+// manifestPath 返回清单文件路径
+func (l *file) manifestPath() string {
+	prefix, _ := l.prefixAndExt()
+	return filepath.Join(l.dir(), prefix+manifestSuffix)
+}
+
+// writeManifest 根据当前历史文件更新清单文件，未变化的文件沿用已有的校验和
+func (l *file) writeManifest() error {
+	files, err := l.oldLogFiles()
+	if err != nil {
+		return err
+	}
+
+	path := l.manifestPath()
+	known := map[string]ManifestEntry{}
+	if old, err := LoadManifest(path); err == nil {
+		for _, e := range old.Backups {
+			known[e.Name] = e
+		}
+	}
+
+	m := Manifest{Filename: filepath.Base(l.filename()), Updated: l.now()}
+	var first time.Time
+	for i := len(files) - 1; i >= 0; i-- {
+		f := files[i]
+		e := ManifestEntry{
+			Name:       f.Name,
+			First:      first,
+			Last:       f.timestamp,
+			Size:       f.Size,
+			Compressed: l.compressorOf(f.Name) != nil,
+			Shipped:    l.isShipped(f.Name),
+		}
+		first = f.timestamp
+
+		k, ok := known[f.Name]
+		if ok && e.First.IsZero() { // 之前的历史文件已被清理，沿用记录的开始时间
+			e.First = k.First
+		}
+		if ok && k.Size == f.Size && k.SHA256 != "" {
+			e.SHA256 = k.SHA256
+		} else if sum, err := fileSHA256(filepath.Join(l.dir(), f.Name)); err == nil {
+			e.SHA256 = sum
+		}
+		m.Backups = append(m.Backups, e)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fileSHA256 计算文件内容的 SHA-256 校验和
+func fileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,85 @@
+package rotatefile
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupMatcher 识别其它命名规则的历史文件（例如迁移前已有的归档文件），
+// 使其参与 MaxBackups/MaxDays 清理及 TotalSizeCap 总大小控制
+type BackupMatcher interface {
+	// MatchBackup 判断目录中的文件 name 是否为当前日志文件 filename（不含目录）的历史文件，
+	// 是则返回其滚动时间
+	MatchBackup(filename, name string) (time.Time, bool)
+}
+
+// BackupMatcherFunc 函数形式的 BackupMatcher
+type BackupMatcherFunc func(filename, name string) (time.Time, bool)
+
+// MatchBackup 实现 BackupMatcher
+func (f BackupMatcherFunc) MatchBackup(filename, name string) (time.Time, bool) {
+	return f(filename, name)
+}
+
+// matchBackup 判断文件 name 是否为历史文件，先按 rotatefile 自身的命名规则，再依次尝试 BackupMatchers
+func (l *file) matchBackup(name, prefix, ext string) (time.Time, bool) {
+	if t, err := l.timeFromName(name, prefix, ext); err == nil {
+		return t, true
+	}
+	for _, c := range l.compressors() {
+		if t, err := l.timeFromName(name, prefix, ext+c.Suffix()); err == nil {
+			return t, true
+		}
+	}
+	if l.dateMatcher != nil {
+		if t, ok := l.dateMatcher.MatchBackup(filepath.Base(l.filename()), name); ok {
+			return t, true
+		}
+	}
+	if l.DailyBundle {
+		if t, ok := l.timeFromBundleName(name); ok {
+			return t, true
+		}
+	}
+
+	if len(l.BackupMatchers) > 0 || l.LumberjackCompat {
+		filename := filepath.Base(l.filename())
+		for _, m := range l.BackupMatchers {
+			if t, ok := m.MatchBackup(filename, name); ok {
+				return t, true
+			}
+		}
+		if l.LumberjackCompat {
+			if t, ok := LumberjackMatcher.MatchBackup(filename, name); ok {
+				return t, true
+			}
+		}
+	}
+
+	// 不匹配说明不是 rotatefile 生成的历史文件
+	return time.Time{}, false
+}
+
+// lumberjackTimeFormat lumberjack 历史文件名中的时间格式
+const lumberjackTimeFormat = "2006-01-02T15-04-05.000"
+
+// LumberjackMatcher 识别 lumberjack 风格的历史文件 name-2006-01-02T15-04-05.000.ext（可以是 .gz 压缩的），
+// 便于从 lumberjack 迁移后，遗留的历史文件也能被正常清理
+var LumberjackMatcher BackupMatcher = BackupMatcherFunc(func(filename, name string) (time.Time, bool) {
+	ext := filepath.Ext(filename)
+	prefix := filename[:len(filename)-len(ext)] + "-"
+
+	name = strings.TrimSuffix(name, compressSuffix)
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return time.Time{}, false
+	}
+
+	ts := name[len(prefix) : len(name)-len(ext)]
+	if len(ts) != len(lumberjackTimeFormat) {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(lumberjackTimeFormat, ts)
+	return t, err == nil
+})
@@ -0,0 +1,25 @@
+package rotatefile
+
+// millError 记录后台清理（压缩、删除、总大小控制等）的错误 err，并回调 OnError，err 为 nil 时忽略
+func (l *file) millError(err error) {
+	if err == nil {
+		return
+	}
+
+	l.millErrors.Add(1)
+	l.errMu.Lock()
+	l.lastErr = err
+	l.errMu.Unlock()
+
+	l.emit(Event{Type: MillError, Err: err})
+	if l.OnError != nil {
+		l.OnError(err)
+	}
+}
+
+// LastError 返回最近一次后台清理的错误，没有错误时返回 nil
+func (l *file) LastError() error {
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	return l.lastErr
+}
@@ -0,0 +1,81 @@
+package rotatefile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWouldBlock 非阻塞模式下，写入需要等待（异步写入队列已满，或者磁盘繁忙）时，丢弃本次写入并返回该错误
+var ErrWouldBlock = errors.New("rotatefile: write would block")
+
+// dropSummaryInterval 非阻塞模式下，汇总写入丢弃情况的间隔
+var dropSummaryInterval = time.Minute
+
+// writeStallThreshold 非阻塞模式下，写入耗时超过该时长时，认为磁盘繁忙
+var writeStallThreshold = 50 * time.Millisecond
+
+// wouldBlock 记录一次因阻塞而丢弃的写入
+func (l *file) wouldBlock() (int, error) {
+	l.dropped.Add(1)
+	return 0, ErrWouldBlock
+}
+
+// tryWriteFile 同 writeFile，磁盘繁忙（正在进行的写入或者上次写入耗时超过 writeStallThreshold）时不等待，
+// 丢弃并返回 ErrWouldBlock，其它写入正常进行时，照常等待
+func (l *file) tryWriteFile(p []byte) (int, error) {
+	writeTime := l.now()
+	if !l.mu.TryLock() {
+		if l.writeStalled() {
+			return l.wouldBlock()
+		}
+		l.mu.Lock()
+	}
+	defer l.mu.Unlock()
+
+	start := time.Now()
+	l.writeStart.Store(start.UnixNano())
+	n, err := l.writeFileLocked(p, writeTime)
+	l.writeStart.Store(0)
+	l.slowWrite.Store(time.Since(start) >= writeStallThreshold)
+	return n, err
+}
+
+// writeStalled 判断写入是否需要等待磁盘：正在进行的写入，或者上次写入耗时超过 writeStallThreshold
+func (l *file) writeStalled() bool {
+	if l.slowWrite.Load() {
+		return true
+	}
+	start := l.writeStart.Load()
+	return start != 0 && time.Since(time.Unix(0, start)) >= writeStallThreshold
+}
+
+// startDropSummary 非阻塞模式下，定期在日志文件中记录期间丢弃的写入次数
+func (l *file) startDropSummary(bg *background) {
+	if !l.NonBlocking {
+		return
+	}
+
+	bg.Go(func(done <-chan struct{}) {
+		ticker := time.NewTicker(dropSummaryInterval)
+		defer ticker.Stop()
+
+		var reported int64
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			dropped := l.dropped.Load()
+			if dropped == reported {
+				continue
+			}
+
+			msg := fmt.Sprintf("rotatefile: dropped %d writes in the last %s\n", dropped-reported, dropSummaryInterval)
+			reported = dropped
+			// 汇总信息本身不受非阻塞限制
+			_, _ = l.writeFile([]byte(msg))
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package otlp
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// newGRPCSender 返回通过 conn 以 OTLP/gRPC 导出一批日志的函数
+func newGRPCSender(e *Exporter, conn *grpc.ClientConn) func(context.Context, []logRecord) error {
+	client := collogspb.NewLogsServiceClient(conn)
+	var md metadata.MD
+	if len(e.headers) > 0 {
+		md = metadata.New(e.headers)
+	}
+
+	return func(ctx context.Context, batch []logRecord) error {
+		if md != nil {
+			ctx = metadata.NewOutgoingContext(ctx, md)
+		}
+		_, err := client.Export(ctx, e.protoRequest(batch))
+		return err
+	}
+}
+
+// protoRequest 构造 OTLP/gRPC 请求（ExportLogsServiceRequest）
+func (e *Exporter) protoRequest(batch []logRecord) *collogspb.ExportLogsServiceRequest {
+	var attrs []*commonpb.KeyValue
+	if e.serviceName != "" {
+		attrs = append(attrs, &commonpb.KeyValue{Key: "service.name", Value: stringValue(e.serviceName)})
+	}
+
+	records := make([]*logspb.LogRecord, 0, len(batch))
+	for _, r := range batch {
+		ts, _ := strconv.ParseUint(r.TimeUnixNano, 10, 64)
+		traceID, _ := hex.DecodeString(r.TraceID)
+		records = append(records, &logspb.LogRecord{
+			TimeUnixNano:         ts,
+			ObservedTimeUnixNano: ts,
+			SeverityNumber:       logspb.SeverityNumber(r.SeverityNumber),
+			SeverityText:         r.SeverityText,
+			Body:                 stringValue(r.Body.StringValue),
+			TraceId:              traceID,
+		})
+	}
+
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{Attributes: attrs},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: "github.com/bingoohuang/rotatefile"},
+				LogRecords: records,
+			}},
+		}},
+	}
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
@@ -0,0 +1,63 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// post 以 OTLP/HTTP JSON 编码导出一批日志
+func (e *Exporter) post(ctx context.Context, batch []logRecord) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	rsp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp export failed: %s", rsp.Status)
+	}
+	return nil
+}
+
+// request 构造 OTLP/HTTP JSON 请求体（ExportLogsServiceRequest）
+func (e *Exporter) request(batch []logRecord) map[string]any {
+	var attrs []keyValue
+	if e.serviceName != "" {
+		attrs = append(attrs, keyValue{Key: "service.name", Value: anyValue{StringValue: e.serviceName}})
+	}
+
+	return map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{"attributes": attrs},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": "github.com/bingoohuang/rotatefile"},
+				"logRecords": batch,
+			}},
+		}},
+	}
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
@@ -0,0 +1,256 @@
+// Package otlp 将 stdlog 格式的日志行转换为 OpenTelemetry 日志记录，通过 OTLP/gRPC 导出到 collector，
+// 导出器作为 rotatefile 的 Tee 输出目标使用，日志文件仍然是可靠的落盘副本，collector 不可用时只丢弃导出，不影响文件写入
+//
+//	exp, err := otlp.NewExporter("localhost:4317", otlp.WithServiceName("myapp"))
+//	defer exp.Close()
+//	stdlog.Init(rotatefile.WithTee(exp))
+//
+// 只能访问 OTLP/HTTP(4318) 时，使用 NewHTTPExporter("http://localhost:4318/v1/logs")，以 JSON 编码导出
+package otlp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bingoohuang/rotatefile/internal/logline"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	defaultQueueSize = 1024
+	defaultBatchSize = 512
+	defaultInterval  = time.Second
+	defaultTimeout   = 5 * time.Second
+)
+
+// Exporter 将写入的日志行批量导出到 OTLP 日志接口，实现 io.Writer，写入不阻塞
+type Exporter struct {
+	endpoint    string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+	dialOpts    []grpc.DialOption
+	timeout     time.Duration
+	batchSize   int
+	interval    time.Duration
+
+	// send 导出一批日志，closeConn 关闭到 collector 的连接
+	send      func(ctx context.Context, batch []logRecord) error
+	closeConn func() error
+
+	ch        chan logRecord
+	flushCh   chan chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+	dropped   atomic.Int64
+	failed    atomic.Int64
+}
+
+// Option 导出器选项
+type Option func(*Exporter)
+
+// WithServiceName 指定资源属性 service.name
+func WithServiceName(name string) Option { return func(e *Exporter) { e.serviceName = name } }
+
+// WithHeaders 指定请求头（gRPC 时为 metadata），例如鉴权信息
+func WithHeaders(headers map[string]string) Option { return func(e *Exporter) { e.headers = headers } }
+
+// WithHTTPClient 指定 NewHTTPExporter 使用的 HTTP 客户端
+func WithHTTPClient(c *http.Client) Option { return func(e *Exporter) { e.client = c } }
+
+// WithDialOptions 指定 NewExporter 连接 collector 的 gRPC 选项，例如 TLS 凭据，默认不加密连接
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(e *Exporter) { e.dialOpts = append(e.dialOpts, opts...) }
+}
+
+// WithTimeout 指定每批日志的导出超时，默认 5s
+func WithTimeout(d time.Duration) Option { return func(e *Exporter) { e.timeout = d } }
+
+// WithBatch 指定每批最多导出的日志条数，以及导出间隔
+func WithBatch(size int, interval time.Duration) Option {
+	return func(e *Exporter) {
+		e.batchSize = size
+		e.interval = interval
+	}
+}
+
+// NewExporter 创建通过 OTLP/gRPC 导出到 endpoint（例如 localhost:4317）的导出器，
+// 连接延迟建立，collector 暂不可用时不返回错误
+func NewExporter(endpoint string, opts ...Option) (*Exporter, error) {
+	e := newExporter(endpoint, opts)
+	dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, e.dialOpts...)
+	conn, err := grpc.NewClient(endpoint, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	e.send = newGRPCSender(e, conn)
+	e.closeConn = conn.Close
+
+	go e.run()
+	return e, nil
+}
+
+// NewHTTPExporter 创建通过 OTLP/HTTP（JSON 编码）导出到 endpoint（例如 http://localhost:4318/v1/logs）的导出器
+func NewHTTPExporter(endpoint string, opts ...Option) *Exporter {
+	e := newExporter(endpoint, opts)
+	if e.client == nil {
+		e.client = &http.Client{}
+	}
+	e.send = e.post
+
+	go e.run()
+	return e
+}
+
+func newExporter(endpoint string, opts []Option) *Exporter {
+	e := &Exporter{
+		endpoint:  endpoint,
+		timeout:   defaultTimeout,
+		batchSize: defaultBatchSize,
+		interval:  defaultInterval,
+		ch:        make(chan logRecord, defaultQueueSize),
+		flushCh:   make(chan chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = defaultBatchSize
+	}
+	if e.interval <= 0 {
+		e.interval = defaultInterval
+	}
+	if e.timeout <= 0 {
+		e.timeout = defaultTimeout
+	}
+	return e
+}
+
+// Write 解析日志行并放入导出队列，队列满时丢弃并计数，不阻塞日志文件写入
+func (e *Exporter) Write(p []byte) (int, error) {
+	for _, line := range logline.Split(p) {
+		select {
+		case e.ch <- parseLine(line):
+		default:
+			e.dropped.Add(1)
+		}
+	}
+	return len(p), nil
+}
+
+// Dropped 返回因队列满而丢弃的日志条数
+func (e *Exporter) Dropped() int64 { return e.dropped.Load() }
+
+// Failed 返回因导出失败（例如 collector 不可用）而丢弃的日志条数
+func (e *Exporter) Failed() int64 { return e.failed.Load() }
+
+// Flush 导出队列中已有的日志
+func (e *Exporter) Flush() error {
+	ack := make(chan struct{})
+	select {
+	case e.flushCh <- ack:
+		<-ack
+	case <-e.done:
+	}
+	return nil
+}
+
+// Close 导出队列中已有的日志，停止导出协程，并关闭到 collector 的连接
+func (e *Exporter) Close() (err error) {
+	e.closeOnce.Do(func() {
+		e.Flush()
+		close(e.done)
+		if e.closeConn != nil {
+			err = e.closeConn()
+		}
+	})
+	return err
+}
+
+func (e *Exporter) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	var batch []logRecord
+	drain := func() {
+		for {
+			select {
+			case r := <-e.ch:
+				if batch = append(batch, r); len(batch) >= e.batchSize {
+					e.export(batch)
+					batch = batch[:0]
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case r := <-e.ch:
+			if batch = append(batch, r); len(batch) >= e.batchSize {
+				e.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = batch[:0]
+		case ack := <-e.flushCh:
+			drain()
+			e.export(batch)
+			batch = batch[:0]
+			close(ack)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// export 导出一批日志，失败时丢弃并计数
+func (e *Exporter) export(batch []logRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	if err := e.send(ctx, batch); err != nil {
+		e.failed.Add(int64(len(batch)))
+	}
+}
+
+// logRecord OTLP 日志记录
+type logRecord struct {
+	TimeUnixNano   string   `json:"timeUnixNano"`
+	SeverityNumber int      `json:"severityNumber"`
+	SeverityText   string   `json:"severityText"`
+	Body           anyValue `json:"body"`
+	TraceID        string   `json:"traceId,omitempty"`
+}
+
+// severities stdlog 日志级别对应的 OTLP SeverityNumber
+var severities = map[string]int{
+	"TRACE": 1, "DEBUG": 5, "INFO": 9, "WARN": 13, "ERROR": 17, "FATAL": 21, "PANIC": 24,
+}
+
+// parseLine 解析 stdlog 格式的日志行，转换为 OTLP 日志记录
+func parseLine(line []byte) logRecord {
+	l := logline.Parse(line)
+	r := logRecord{
+		TimeUnixNano: strconv.FormatInt(l.Time.UnixNano(), 10),
+		SeverityText: "INFO",
+		Body:         anyValue{StringValue: string(l.Msg)},
+		TraceID:      l.TraceID,
+	}
+	if _, ok := severities[l.Level]; ok {
+		r.SeverityText = l.Level
+	}
+	r.SeverityNumber = severities[r.SeverityText]
+	return r
+}
@@ -0,0 +1,164 @@
+package otlp
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type logsServer struct {
+	collogspb.UnimplementedLogsServiceServer
+
+	mu      sync.Mutex
+	records []*logspb.LogRecord
+	service string
+	auth    []string
+}
+
+func (s *logsServer) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = md.Get("authorization")
+	for _, rl := range req.ResourceLogs {
+		for _, kv := range rl.Resource.GetAttributes() {
+			if kv.Key == "service.name" {
+				s.service = kv.Value.GetStringValue()
+			}
+		}
+		for _, sl := range rl.ScopeLogs {
+			s.records = append(s.records, sl.LogRecords...)
+		}
+	}
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func TestExporter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	logs := &logsServer{}
+	collogspb.RegisterLogsServiceServer(srv, logs)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	e, err := NewExporter(ln.Addr().String(), WithServiceName("test"),
+		WithHeaders(map[string]string{"authorization": "Bearer x"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Write([]byte("2024-01-02 03:04:05.678 [ERROR] 123 --- [1     ] [-] : boom trace_id=4BF92F3577B34DA6A3CE929D0E0E4736\n" +
+		"not a stdlog line\n"))
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	logs.mu.Lock()
+	defer logs.mu.Unlock()
+	if len(logs.records) != 2 || e.Failed() != 0 {
+		t.Fatalf("expected 2 records, got %d, failed %d", len(logs.records), e.Failed())
+	}
+	if logs.service != "test" || len(logs.auth) != 1 || logs.auth[0] != "Bearer x" {
+		t.Fatalf("unexpected service %q auth %v", logs.service, logs.auth)
+	}
+	if r := logs.records[0]; r.SeverityText != "ERROR" || r.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_ERROR ||
+		hex.EncodeToString(r.TraceId) != "4bf92f3577b34da6a3ce929d0e0e4736" || r.TimeUnixNano == 0 ||
+		r.Body.GetStringValue() != "boom trace_id=4BF92F3577B34DA6A3CE929D0E0E4736" {
+		t.Fatalf("unexpected record %v", r)
+	}
+	if r := logs.records[1]; r.SeverityText != "INFO" || r.Body.GetStringValue() != "not a stdlog line" || len(r.TraceId) != 0 {
+		t.Fatalf("unexpected record %v", r)
+	}
+}
+
+func TestExporterCollectorDown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	e, err := NewExporter(addr, WithTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := e.Write([]byte("hello\n"))
+	if n != 6 || err != nil {
+		t.Fatalf("unexpected write result %d %v", n, err)
+	}
+	e.Close()
+	if e.Failed() != 1 {
+		t.Fatalf("expected 1 failed record, got %d", e.Failed())
+	}
+}
+
+func TestHTTPExporter(t *testing.T) {
+	var mu sync.Mutex
+	var records []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ResourceLogs []struct {
+				ScopeLogs []struct {
+					LogRecords []map[string]any `json:"logRecords"`
+				} `json:"scopeLogs"`
+			} `json:"resourceLogs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		records = append(records, req.ResourceLogs[0].ScopeLogs[0].LogRecords...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	e := NewHTTPExporter(srv.URL, WithServiceName("test"))
+	e.Write([]byte("2024-01-02 03:04:05.678 [ERROR] 123 --- [1     ] [-] : boom trace_id=4BF92F3577B34DA6A3CE929D0E0E4736\n" +
+		"not a stdlog line\n"))
+	e.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if r := records[0]; r["severityText"] != "ERROR" || r["severityNumber"] != float64(17) ||
+		r["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		r["body"].(map[string]any)["stringValue"] != "boom trace_id=4BF92F3577B34DA6A3CE929D0E0E4736" {
+		t.Fatalf("unexpected record %v", r)
+	}
+	if r := records[1]; r["severityText"] != "INFO" || r["body"].(map[string]any)["stringValue"] != "not a stdlog line" {
+		t.Fatalf("unexpected record %v", r)
+	}
+}
+
+func TestHTTPExporterCollectorDown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	e := NewHTTPExporter(srv.URL)
+	n, err := e.Write([]byte("hello\n"))
+	if n != 6 || err != nil {
+		t.Fatalf("unexpected write result %d %v", n, err)
+	}
+	e.Close()
+	if e.Failed() != 1 {
+		t.Fatalf("expected 1 failed record, got %d", e.Failed())
+	}
+}
@@ -0,0 +1,61 @@
+package rotatefile
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// chown 设置日志文件 name 的属主：DisableChown 时不设置；指定了 Owner/Group 时使用指定的属主；
+// 否则沿用原文件 info 的属主（仅 Linux）
+func (l *file) chown(name string, info os.FileInfo) error {
+	if l.DisableChown {
+		return nil
+	}
+
+	if l.Owner == "" && l.Group == "" {
+		if info == nil {
+			return nil
+		}
+		return chown(name, info)
+	}
+
+	uid, gid, err := lookupOwner(l.Owner, l.Group)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0o600)
+	if info != nil {
+		mode = info.Mode()
+	}
+	return chownTo(name, mode, uid, gid)
+}
+
+// lookupOwner 解析用户 owner 及组 group 为 uid/gid，可以是名称或者数字 id，为空时返回 -1
+func lookupOwner(owner, group string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if owner != "" {
+		if uid, err = strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return 0, 0, fmt.Errorf("lookup owner %s: %w", owner, err)
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return 0, 0, fmt.Errorf("owner %s uid %s: %w", owner, u.Uid, err)
+			}
+		}
+	}
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, fmt.Errorf("lookup group %s: %w", group, err)
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("group %s gid %s: %w", group, g.Gid, err)
+			}
+		}
+	}
+	return uid, gid, nil
+}
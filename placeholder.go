@@ -0,0 +1,25 @@
+package rotatefile
+
+import (
+	"os"
+	"strings"
+)
+
+// expandPlaceholders 展开文件名中的占位符，以便多个副本写入共享卷（NFS、hostPath）时互不冲突：
+// {hostname} 主机名，{pod} Pod 名称（环境变量 POD_NAME，未设置时取主机名），{pid} 进程号
+func expandPlaceholders(s string) string {
+	if !strings.Contains(s, "{") {
+		return s
+	}
+
+	hostname, _ := os.Hostname()
+	pod := os.Getenv("POD_NAME")
+	if pod == "" {
+		pod = hostname
+	}
+	return strings.NewReplacer(
+		"{hostname}", hostname,
+		"{pod}", pod,
+		"{pid}", pid,
+	).Replace(s)
+}
@@ -0,0 +1,88 @@
+package rotatefile
+
+// 清理操作
+const (
+	// ActionCompress 压缩历史文件
+	ActionCompress = "compress"
+	// ActionDelete 删除历史文件
+	ActionDelete = "delete"
+	// ActionTrash 历史文件移入隔离目录 TrashDir
+	ActionTrash = "trash"
+)
+
+// 清理原因
+const (
+	ReasonCompress             = "compress-enabled"
+	ReasonMaxBackups           = "max-backups"
+	ReasonMaxAge               = "max-age"
+	ReasonMaxCompressedBackups = "max-compressed-backups"
+	ReasonTotalSizeCap         = "total-size-cap"
+	ReasonDiskFree             = "disk-free"
+	ReasonFreeInodes           = "free-inodes"
+	ReasonCompressBacklog      = "compress-backlog"
+	ReasonDailyBundle          = "daily-bundle"
+	ReasonTrashMaxAge          = "trash-max-age"
+	ReasonEmpty                = "empty"
+	ReasonCorrupt              = "corrupt"
+)
+
+// Action 清理计划中的一项操作
+type Action struct {
+	// Op 操作，compress、delete 或者 trash
+	Op string `json:"op"`
+	// Path 文件路径
+	Path string `json:"path"`
+	// Reason 原因，例如 max-backups、max-age、total-size-cap
+	Reason string `json:"reason"`
+}
+
+// PlanCleanup 不做任何修改，返回按当前配置清理时将要执行的操作（压缩、删除、移入隔离目录）及原因，
+// 用于在生产环境中验证保留策略
+func (l *file) PlanCleanup() ([]Action, error) {
+	l.mu.Lock()
+	if l.filename() == "" {
+		l.mill()
+	}
+	l.mu.Unlock()
+	if l.setupErr != nil {
+		return nil, l.setupErr
+	}
+	return l.planCleanup()
+}
+
+// planCleanup 在副本上预演清理，返回将要执行的操作
+func (l *file) planCleanup() ([]Action, error) {
+	state := &cleanState{dryRun: true, removed: map[string]bool{}}
+	p := &file{
+		Config:      l.Config,
+		datePattern: l.datePattern,
+		dateMatcher: l.dateMatcher,
+		clean:       state,
+	}
+	p.setPaths(l.filename(), nil)
+	p.size.Store(l.size.Load())
+	err := p.millRunOnce()
+	return state.actions, err
+}
+
+// reportPlan MillDryRun 时，清理只发送 Planned 事件报告将要执行的操作，不做任何修改
+func (l *file) reportPlan() error {
+	actions, err := l.planCleanup()
+	for _, a := range actions {
+		debugf("dry run: %s %s (%s)", a.Op, a.Path, a.Reason)
+		l.emit(Event{Type: Planned, Path: a.Path, Reason: a.Op + " " + a.Reason})
+	}
+	return err
+}
+
+// capReason 返回超过额度删除历史文件的原因，capacity 为 TotalSizeCap
+func capReason(capacity uint64, totalSize int64, dirDiskFree, minDiskFree uint64) string {
+	switch {
+	case capacity > 0 && uint64(totalSize) > capacity:
+		return ReasonTotalSizeCap
+	case dirDiskFree < minDiskFree:
+		return ReasonDiskFree
+	default:
+		return ReasonFreeInodes
+	}
+}
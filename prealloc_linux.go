@@ -0,0 +1,18 @@
+package rotatefile
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate 为文件 f 预分配 size 字节的磁盘空间，不改变文件大小，
+// 以减少碎片以及写到一半时磁盘空间不足，文件系统不支持时忽略
+func preallocate(f *os.File, size int64) error {
+	err := unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+	if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+		return nil
+	}
+	return err
+}
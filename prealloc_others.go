@@ -0,0 +1,8 @@
+//go:build !linux
+
+package rotatefile
+
+import "os"
+
+// preallocate 仅 Linux 支持预分配
+func preallocate(*os.File, int64) error { return nil }
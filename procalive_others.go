@@ -0,0 +1,14 @@
+//go:build !windows
+
+package rotatefile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive 判断进程 pid 是否仍在运行
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,18 @@
+package rotatefile
+
+import "golang.org/x/sys/windows"
+
+// stillActive GetExitCodeProcess 返回的进程仍在运行的状态码
+const stillActive = 259
+
+// processAlive 判断进程 pid 是否仍在运行
+func processAlive(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return err == windows.ERROR_ACCESS_DENIED
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	return windows.GetExitCodeProcess(h, &code) == nil && code == stillActive
+}
@@ -0,0 +1,150 @@
+package rotatefile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcessLog 进程的日志文件登记信息，每个进程每个应用一个登记文件 {TMPDIR}/rotatefile-{uid}/{app}.{pid}.json
+type ProcessLog struct {
+	// App 应用名称
+	App string `json:"app"`
+	// Pid 进程号
+	Pid int `json:"pid"`
+	// StartTime 进程启动时间，用于识别进程号被复用的过期登记
+	StartTime time.Time `json:"startTime"`
+	// Filenames 进程写入的日志文件，按登记顺序
+	Filenames []string `json:"filenames"`
+}
+
+// processStart 当前进程的启动时间（近似为包初始化时间）
+var processStart = time.Now()
+
+var processLogMu sync.Mutex
+
+// processLogDir 返回登记文件所在目录，每个用户一个目录，避免第一个创建目录的用户独占
+// Windows 上 TMPDIR 已经是每个用户一个目录
+func processLogDir() string {
+	if uid := os.Getuid(); uid >= 0 {
+		return filepath.Join(os.TempDir(), "rotatefile-"+strconv.Itoa(uid))
+	}
+	return filepath.Join(os.TempDir(), "rotatefile")
+}
+
+// registerProcessLog 登记当前进程应用 app 的日志文件 filename
+func registerProcessLog(app, filename string) {
+	debugf("log file %s", filename)
+
+	processLogMu.Lock()
+	defer processLogMu.Unlock()
+
+	path := filepath.Join(processLogDir(), filepath.Base(app)+"."+pid+".json")
+	p, err := readProcessLog(path)
+	if err != nil || !p.StartTime.Equal(processStart) {
+		// 进程首次登记时，顺便清理已经退出的进程的过期登记
+		pruneProcessLogs()
+		p = ProcessLog{App: app, Pid: os.Getpid(), StartTime: processStart}
+	}
+	for _, f := range p.Filenames {
+		if f == filename {
+			return
+		}
+	}
+	p.Filenames = append(p.Filenames, filename)
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(processLogDir(), 0o700); err != nil {
+		debugf("register log file: %v", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		debugf("register log file: %v", err)
+		return
+	}
+	_ = os.Rename(tmp, path)
+}
+
+func readProcessLog(path string) (ProcessLog, error) {
+	var p ProcessLog
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// staleProcessLog 判断登记 p 的进程是否已经退出，或者进程号已被当前进程复用
+func staleProcessLog(p ProcessLog) bool {
+	if p.Pid == os.Getpid() {
+		return !p.StartTime.Equal(processStart)
+	}
+	return !processAlive(p.Pid)
+}
+
+// ListProcessLogs 返回当前用户仍在运行的进程登记的日志文件，只读，已经退出的进程的过期登记在进程登记时清理
+func ListProcessLogs() ([]ProcessLog, error) {
+	entries, err := os.ReadDir(processLogDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var logs []ProcessLog
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		p, err := readProcessLog(filepath.Join(processLogDir(), e.Name()))
+		if err == nil && !staleProcessLog(p) {
+			logs = append(logs, p)
+		}
+	}
+	return logs, nil
+}
+
+// pruneProcessLogs 删除已经退出的进程的过期登记
+func pruneProcessLogs() {
+	entries, err := os.ReadDir(processLogDir())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(processLogDir(), e.Name())
+		if p, err := readProcessLog(path); err == nil && staleProcessLog(p) {
+			_ = os.Remove(path)
+		}
+	}
+}
+
+// GetFilename 获得当前进程最近登记的日志文件路径，进程号被复用时，不会返回之前进程的日志文件
+func GetFilename() string {
+	paths, _ := filepath.Glob(filepath.Join(processLogDir(), "*."+pid+".json"))
+	var filename string
+	var latest time.Time
+	for _, path := range paths {
+		p, err := readProcessLog(path)
+		if err != nil || p.Pid != os.Getpid() || staleProcessLog(p) || len(p.Filenames) == 0 {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && !info.ModTime().Before(latest) {
+			latest = info.ModTime()
+			filename = p.Filenames[len(p.Filenames)-1]
+		}
+	}
+	return filename
+}
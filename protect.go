@@ -0,0 +1,68 @@
+package rotatefile
+
+import (
+	"os"
+)
+
+// readOnlyMode 只读历史文件的权限
+const readOnlyMode = 0o440
+
+// osChmod exists, so it can be mocked out by tests.
+var osChmod = os.Chmod
+
+// protectBackup 将已完成的历史文件 name 设置为只读，以及不可修改（仅 Linux，需要 root 权限），以防篡改，
+// 尽力而为，失败（例如非 root 用户设置不可修改）时只记录调试日志，不影响滚动、压缩等后续操作
+func (l *file) protectBackup(name string) {
+	if !l.ReadOnlyBackups && !l.ImmutableBackups {
+		return
+	}
+	if err := osChmod(name, readOnlyMode); err != nil {
+		debugf("protect %s: %v", name, err)
+		return
+	}
+	if l.ImmutableBackups {
+		if err := setImmutable(name, true); err != nil {
+			debugf("protect %s: %v", name, err)
+		}
+	}
+}
+
+// unprotectBackup 清除历史文件 name 的不可修改属性，以便清理、归档
+func (l *file) unprotectBackup(name string) error {
+	if !l.ImmutableBackups {
+		return nil
+	}
+	if err := setImmutable(name, false); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// backupDone 历史文件完成（滚动或者压缩完成）后放入 OnBackup 回调队列，
+// 滚动时持有 l.mu，由清理协程（或者 Close）在锁外回调，以免回调阻塞写入
+func (l *file) backupDone(path string) {
+	if l.OnBackup == nil {
+		return
+	}
+	l.backupMu.Lock()
+	l.doneBackups = append(l.doneBackups, path)
+	l.backupMu.Unlock()
+}
+
+// notifyBackups 按完成顺序回调 OnBackup，不能在持有 l.mu 时调用（SyncMill 除外）
+func (l *file) notifyBackups() {
+	if l.OnBackup == nil {
+		return
+	}
+	l.notifyMu.Lock()
+	defer l.notifyMu.Unlock()
+
+	l.backupMu.Lock()
+	paths := l.doneBackups
+	l.doneBackups = nil
+	l.backupMu.Unlock()
+
+	for _, path := range paths {
+		l.OnBackup(path)
+	}
+}
@@ -0,0 +1,81 @@
+package rotatefile
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// corruptDir 未配置 TrashDir 时，损坏的压缩文件移入日志目录下的该子目录，由运维人员检查后删除
+const corruptDir = "corrupt"
+
+// verifiedSet 本进程内已经校验过的压缩文件
+type verifiedSet struct {
+	mu sync.Mutex
+	m  map[string]bool
+}
+
+// pruneBackups 删除空的历史文件（PruneEmptyBackups），隔离无法完整解压的压缩文件（VerifyCompressed），
+// 返回其余的历史文件，每个压缩文件在本进程内只校验一次
+func (l *file) pruneBackups(files []logInfo) ([]logInfo, error) {
+	if !l.PruneEmptyBackups && !l.VerifyCompressed {
+		return files, nil
+	}
+
+	var err error
+	remaining := make([]logInfo, 0, len(files))
+	for _, f := range files {
+		if l.isCompressing(f.Name) {
+			remaining = append(remaining, f)
+			continue
+		}
+
+		if l.PruneEmptyBackups && f.Size == 0 {
+			if errRemove := l.removeBackup(f.Name, ReasonEmpty); errRemove != nil && err == nil {
+				err = errRemove
+			}
+			continue
+		}
+
+		if c := l.compressorOf(f.Name); c != nil && l.VerifyCompressed && !l.isVerified(f.Name) {
+			if errVerify := verifyCompressed(filepath.Join(l.dir(), f.Name), c); errVerify != nil {
+				debugf("corrupt compressed file %s: %v", f.Name, errVerify)
+				if errMove := l.quarantineBackup(f.Name); errMove != nil && err == nil {
+					err = errMove
+				}
+				continue
+			}
+			l.setVerified(f.Name)
+		}
+		remaining = append(remaining, f)
+	}
+	return remaining, err
+}
+
+// quarantineBackup 将损坏的历史文件 name 移入 TrashDir（按 TrashMaxAge 清理），未配置时移入 corrupt 子目录
+func (l *file) quarantineBackup(name string) error {
+	dir := l.trashDir()
+	if dir == "" {
+		dir = filepath.Join(l.dir(), corruptDir)
+	}
+	return l.moveBackup(name, dir, ReasonCorrupt)
+}
+
+// isQuarantineDir 判断日志目录下相对路径为 rel 的子目录是否为隔离目录，查找历史文件时跳过
+func (l *file) isQuarantineDir(rel string) bool {
+	return rel == corruptDir || filepath.Join(l.dir(), rel) == l.trashDir()
+}
+
+func (l *file) isVerified(name string) bool {
+	l.verified.mu.Lock()
+	defer l.verified.mu.Unlock()
+	return l.verified.m[name]
+}
+
+func (l *file) setVerified(name string) {
+	l.verified.mu.Lock()
+	defer l.verified.mu.Unlock()
+	if l.verified.m == nil {
+		l.verified.m = map[string]bool{}
+	}
+	l.verified.m[name] = true
+}
@@ -0,0 +1,71 @@
+package rotatefile
+
+import (
+	"sync"
+	"time"
+)
+
+// tokenBucket 令牌桶限速器，每秒补充 rate 个令牌，最多积攒 burst 个令牌
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst int) *tokenBucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// allow 消耗一个令牌，没有可用令牌时返回 false
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.last.IsZero() {
+		if elapsed := now.Sub(b.last); elapsed > 0 {
+			b.tokens += elapsed.Seconds() * b.rate
+			if b.tokens > b.burst {
+				b.tokens = b.burst
+			}
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimited 判断本次写入是否因超过限速而需要丢弃，丢弃时计数
+func (l *file) rateLimited(now time.Time) bool {
+	if l.RateLimit <= 0 {
+		return false
+	}
+
+	l.limiterOnce.Do(func() {
+		l.limiter = newTokenBucket(l.RateLimit, l.RateBurst)
+	})
+
+	if l.limiter.allow(now) {
+		return false
+	}
+
+	l.dropped.Add(1)
+	return true
+}
+
+// Dropped 返回因限速等原因被丢弃的写入次数
+func (l *file) Dropped() int64 {
+	return l.dropped.Load()
+}
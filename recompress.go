@@ -0,0 +1,131 @@
+package rotatefile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Recompress 不写入日志，将 path（文件，或者目录中 rotatefile 格式的历史文件）按配置的压缩格式（CompressFormat/CompressLevel/Compressor）压缩，
+// 已是其它格式的压缩文件先解压再压缩，例如将 gzip 历史文件迁移为自定义格式，已是目标格式的文件跳过，
+// 返回生成的压缩文件路径
+func Recompress(path string, fns ...ConfigFn) ([]string, error) {
+	return recompress(path, false, fns...)
+}
+
+// RecompressForce 同 Recompress，已是目标格式的文件同样解压后重新压缩，用于调整压缩级别
+func RecompressForce(path string, fns ...ConfigFn) ([]string, error) {
+	return recompress(path, true, fns...)
+}
+
+func recompress(path string, force bool, fns ...ConfigFn) ([]string, error) {
+	c := createConfig(fns...)
+	if err := c.checkCompressFormat(); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &file{Config: c}
+	l.setDir(path)
+	var names []string
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if _, _, ok := l.splitAnyBackupName(e.Name()); ok && e.Type().IsRegular() {
+				names = append(names, filepath.Join(path, e.Name()))
+			}
+		}
+	} else {
+		l.setDir(filepath.Dir(path))
+		names = append(names, path)
+	}
+
+	var compressed []string
+	var errs []error
+	for _, name := range names {
+		dst, err := l.recompressFile(name, force)
+		if err != nil {
+			errs = append(errs, err)
+		} else if dst != "" {
+			compressed = append(compressed, dst)
+		}
+	}
+	return compressed, errors.Join(errs...)
+}
+
+// recompressFile 将文件 name 压缩为配置的格式，返回压缩文件路径，无需处理时返回空，
+// force 时已是目标格式的文件同样重新压缩
+func (l *file) recompressFile(name string, force bool) (string, error) {
+	if strings.HasSuffix(name, compressTmpSuffix) {
+		return "", nil
+	}
+
+	c := l.compressor()
+	old := l.compressorOf(name)
+	same := old != nil && old.Suffix() == c.Suffix()
+	if same && !force {
+		return "", nil
+	}
+
+	dst := l.trimCompressSuffix(name) + c.Suffix()
+	if _, err := os.Stat(dst); err == nil && !same {
+		return "", nil
+	}
+
+	if old == nil {
+		return dst, l.compressLogFile(name, dst, c)
+	}
+
+	// 先解压到临时文件，压缩后删除临时文件
+	plain := l.trimCompressSuffix(name) + compressTmpSuffix
+	if err := l.decompressTo(name, plain); err != nil {
+		os.Remove(plain)
+		return "", err
+	}
+	keep := l.CompressKeepSource
+	l.CompressKeepSource = false
+	err := l.compressLogFile(plain, dst, c)
+	l.CompressKeepSource = keep
+	if err != nil {
+		os.Remove(plain)
+		return "", err
+	}
+	if !keep && !same { // 同一格式时已被新的压缩文件替换
+		if err := os.Remove(name); err != nil {
+			return dst, err
+		}
+	}
+	return dst, nil
+}
+
+// decompressTo 将压缩文件 src 解压到文件 dst，dst 沿用 src 的权限
+func (l *file) decompressTo(src, dst string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	r, err := l.openBackup(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to decompress %s: %v", src, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,107 @@
+package rotatefile
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// compressTmpSuffix 压缩过程中临时文件的扩展名，校验完成后才重命名为正式的压缩文件
+const compressTmpSuffix = ".tmp"
+
+// recoverCompressionsOnce 只处理一次中断的压缩，并发调用时等待处理完成
+func (l *file) recoverCompressionsOnce() {
+	l.recoverOnce.Do(func() {
+		if err := l.recoverCompressions(); err != nil {
+			debugf("recover compressions in %s: %v", l.dir(), err)
+		}
+	})
+}
+
+// recoverCompressions 启动时，处理因进程崩溃而中断的压缩：
+// 删除残留的压缩临时文件；压缩文件与源文件同时存在时，压缩文件完整则删除源文件，
+// 否则删除不完整的压缩文件，由随后的清理重新压缩。
+// 保留源文件（CompressKeepSource）时两者同时存在是正常的，不再逐个解压校验
+func (l *file) recoverCompressions() error {
+	if err := l.removeCompressTmps(); err != nil {
+		return err
+	}
+	if l.CompressKeepSource {
+		return nil
+	}
+
+	files, err := l.oldLogFiles()
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(files))
+	for _, f := range files {
+		names[f.Name] = true
+	}
+
+	for _, f := range files {
+		c := l.compressorOf(f.Name)
+		if c == nil {
+			continue
+		}
+		src := l.trimCompressSuffix(f.Name)
+		if !names[src] {
+			continue
+		}
+
+		dst := filepath.Join(l.dir(), f.Name)
+		if verifyCompressed(dst, c) != nil {
+			err = os.Remove(dst)
+		} else {
+			err = os.Remove(filepath.Join(l.dir(), src))
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyCompressed 完整读取压缩文件 name，校验其是否完整有效
+func verifyCompressed(name string, c Compressor) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := c.Decompress(f)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+// removeCompressTmps 删除日志目录（包括归档子目录）中残留的压缩临时文件
+func (l *file) removeCompressTmps() error {
+	prefix, _ := l.prefixAndExt()
+	return filepath.WalkDir(l.dir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != l.dir() && l.subdirDepth() == 0 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name := d.Name(); strings.HasPrefix(name, prefix) && strings.HasSuffix(name, compressTmpSuffix) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+}
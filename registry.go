@@ -0,0 +1,82 @@
+package rotatefile
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// registry 记录进程中所有已打开日志文件的写入器，用于 FlushAll/CloseAll
+var registry = struct {
+	sync.Mutex
+	files map[*file]struct{}
+}{files: map[*file]struct{}{}}
+
+// register 登记已打开日志文件的写入器
+func register(l *file) {
+	registry.Lock()
+	registry.files[l] = struct{}{}
+	registry.Unlock()
+}
+
+// unregister 注销写入器
+func unregister(l *file) {
+	registry.Lock()
+	delete(registry.files, l)
+	registry.Unlock()
+}
+
+// registered 返回当前登记的所有写入器
+func registered() []*file {
+	registry.Lock()
+	defer registry.Unlock()
+
+	files := make([]*file, 0, len(registry.files))
+	for l := range registry.files {
+		files = append(files, l)
+	}
+	return files
+}
+
+// FlushAll 刷新进程中所有已打开的日志文件到磁盘，可以在 main 退出前或者信号处理中调用
+func FlushAll() error {
+	var errs []error
+	for _, l := range registered() {
+		if err := l.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseAll 刷新并关闭进程中所有已打开的日志文件
+func CloseAll() error {
+	var errs []error
+	for _, l := range registered() {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var closeOnExitOnce sync.Once
+
+// closeOnExit 收到 SIGTERM/SIGINT 时，先关闭所有已登记的日志文件，再重新投递该信号：
+// 应用自己 signal.Notify 了该信号时（例如优雅退出），由应用处理（会再收到一次），否则按默认方式退出进程
+func closeOnExit() {
+	closeOnExitOnce.Do(func() {
+		handleSigint(func(sig os.Signal) {
+			CloseAll()
+			raiseSignal(sig)
+		})
+	})
+}
+
+// raiseSignal is a var so we can mock it out during tests.
+// 不调用 signal.Reset，以免清除应用自己的 signal.Notify
+var raiseSignal = func(sig os.Signal) {
+	if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(sig) != nil {
+		os.Exit(1)
+	}
+}
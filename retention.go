@@ -0,0 +1,44 @@
+package rotatefile
+
+import "time"
+
+// retentionCutoff 返回按日历天保留时的截止时间：RetentionZone 时区中，
+// 今天零点往前推 MaxDays-1 天，早于该时间的历史文件将被删除
+func (l *file) retentionCutoff(now time.Time) time.Time {
+	loc := l.retentionLocation()
+	y, m, d := now.In(loc).Date()
+	return time.Date(y, m, d-(l.MaxDays-1), 0, 0, 0, 0, loc)
+}
+
+// retentionLocation 返回按日历天保留时使用的时区，默认本地时区
+func (l *file) retentionLocation() *time.Location {
+	if l.RetentionZone != "" {
+		if loc, err := time.LoadLocation(l.RetentionZone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// backupTime 返回历史文件名中时间戳对应的真实时间
+// 文件名中的时间戳总是按 UTC 解析，未启用 UtcTime 时，其实际为本地时间
+func (l *file) backupTime(ts time.Time) time.Time {
+	if l.UtcTime {
+		return ts
+	}
+	return time.Date(ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(), time.Local)
+}
+
+// expired 判断滚动时间为 ts 的历史文件是否已超过保留期限（MaxDays/MaxAge）
+func (l *file) expired(ts, now time.Time) bool {
+	if l.CalendarDays && l.MaxDays > 0 {
+		if l.backupTime(ts).Before(l.retentionCutoff(now)) {
+			return true
+		}
+		// MaxDays 已按日历天处理，只需再检查 MaxAge
+		return l.MaxAge > 0 && ts.Before(now.Add(-l.MaxAge))
+	}
+
+	diff := l.maxAge()
+	return diff > 0 && ts.Before(now.Add(-diff))
+}
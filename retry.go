@@ -0,0 +1,103 @@
+package rotatefile
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultWriteRetryBackoff 默认首次重试前的等待时间，之后每次重试翻倍
+const defaultWriteRetryBackoff = 10 * time.Millisecond
+
+// isTransientWriteErr 判断写入错误是否是可以重试的临时错误
+func isTransientWriteErr(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOSPC)
+}
+
+// writeWithRetry 写入 p，遇到临时错误时按 WriteRetries/WriteRetryBackoff 退避重试，
+// 磁盘空间不足时通知清理协程清理历史文件，清理完成后再重试，最终失败时回调 OnWriteError
+func (l *file) writeWithRetry(p []byte) (n int, err error) {
+	backoff := l.WriteRetryBackoff
+	if backoff <= 0 {
+		backoff = defaultWriteRetryBackoff
+	}
+
+	for i := 0; ; i++ {
+		var m int
+		m, err = l.writeWithTimeout(p[n:])
+		n += m
+		if err == nil || i >= l.WriteRetries || !isTransientWriteErr(err) {
+			break
+		}
+		if errors.Is(err, syscall.ENOSPC) {
+			l.waitMill(backoff)
+		} else {
+			time.Sleep(backoff)
+		}
+		backoff *= 2
+	}
+
+	if err != nil {
+		l.emit(Event{Type: WriteError, Path: l.filename(), Err: err})
+		if l.OnWriteError != nil {
+			l.OnWriteError(err)
+		}
+	}
+	return n, err
+}
+
+// waitMill 磁盘空间不足时通知清理协程（Manager 管理的日志流为 Manager 共享的清理协程）清理历史文件，
+// 并等待其完成一次清理，最多等待 timeout，调用方需持有 l.mu。
+// 等待期间标记 inlineMill，清理协程读取本日志流时不再加锁，以免互相等待
+func (l *file) waitMill(timeout time.Duration) {
+	if l.SyncMill {
+		l.mill()
+		return
+	}
+
+	signal := &l.millDone
+	if l.manager != nil {
+		signal = &l.manager.millDone
+	}
+	done := signal.wait()
+
+	l.inlineMill.Store(true)
+	defer l.inlineMill.Store(false)
+	l.mill()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+}
+
+// millSignal 清理完成通知，每次清理完成后关闭当前的通道
+type millSignal struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+// wait 返回下次清理完成时关闭的通道
+func (s *millSignal) wait() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch == nil {
+		s.ch = make(chan struct{})
+	}
+	return s.ch
+}
+
+// done 通知等待的协程，清理已经完成
+func (s *millSignal) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ch != nil {
+		close(s.ch)
+		s.ch = nil
+	}
+}
@@ -0,0 +1,73 @@
+package rotatefile
+
+import "sync"
+
+// ringBuffer 固定容量的环形缓冲区，保留最近写入的内容
+type ringBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	pos  int
+	full bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{buf: make([]byte, size)}
+}
+
+// Write 写入内容，超出容量时覆盖最早的内容
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	if n >= len(r.buf) {
+		copy(r.buf, p[n-len(r.buf):])
+		r.pos, r.full = 0, true
+		return n, nil
+	}
+
+	c := copy(r.buf[r.pos:], p)
+	if c < n {
+		copy(r.buf, p[c:])
+		r.full = true
+	}
+	r.pos = (r.pos + n) % len(r.buf)
+	if r.pos == 0 {
+		r.full = true
+	}
+	return n, nil
+}
+
+// Bytes 返回缓冲区中按写入顺序排列的内容副本
+func (r *ringBuffer) Bytes() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.pos]...)
+	}
+
+	b := make([]byte, 0, len(r.buf))
+	b = append(b, r.buf[r.pos:]...)
+	return append(b, r.buf[:r.pos]...)
+}
+
+// TailBuffer 返回内存中保留的最近写入日志文件的内容，未开启 TailBufferSize 时返回 nil
+func (l *file) TailBuffer() []byte {
+	if r := l.tailBuffer(); r != nil {
+		return r.Bytes()
+	}
+	return nil
+}
+
+// tailBuffer 返回最近写入内容的环形缓冲区，未开启 TailBufferSize 时返回 nil
+func (l *file) tailBuffer() *ringBuffer {
+	if l.TailBufferSize == 0 {
+		return nil
+	}
+
+	l.ringOnce.Do(func() {
+		l.ring = newRingBuffer(int(l.TailBufferSize))
+	})
+	return l.ring
+}
@@ -14,10 +14,10 @@
 package rotatefile
 
 import (
-	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -27,8 +27,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/bingoohuang/q"
-	"github.com/bingoohuang/rotatefile/disk"
 	"github.com/bingoohuang/rotatefile/flock"
 )
 
@@ -68,17 +66,97 @@ type file struct {
 	file   *os.File
 	millCh chan bool
 
-	flock *flock.Flock
+	// manager 非空时，由 Manager 统一清理历史文件
+	manager *Manager
 
-	dir      string
-	filename string
+	// parent 非空时，为 Child 创建的子日志文件，清理后通知父日志文件控制总大小
+	parent   *file
+	children children
+
+	// clean 非空时，为 Clean 创建的独立清理实例
+	clean *cleanState
+
+	// paths 当前日志文件的路径，写入协程切换文件时整体替换，清理协程无需持有 mu 即可读取
+	paths atomic.Pointer[logPaths]
 
 	Config
 
 	size      atomic.Int64
 	startMill sync.Once
-	mu        sync.Mutex
-	lastWrite time.Time
+	// recoverOnce 启动后首次清理时（在清理协程中）处理上次中断的压缩
+	recoverOnce sync.Once
+	// setupErr 配置或者生成日志文件路径的错误，例如不支持的压缩格式、没有可写的日志目录
+	setupErr error
+	// inlineMill 持有 mu 的写入协程正在同步清理（SyncMill），或者在等待清理完成（见 waitMill）
+	inlineMill atomic.Bool
+	millDone   millSignal
+	mu         sync.Mutex
+	lastWrite  time.Time
+
+	// doneBackups 等待回调 OnBackup 的历史文件，notifyMu 保证按顺序回调
+	backupMu    sync.Mutex
+	doneBackups []string
+	notifyMu    sync.Mutex
+
+	// datePattern 活动文件名中带日期模式时（例如 app-%Y%m%d.log），为不含目录的文件名模式，
+	// datedName 为当前日期展开后的文件名（不含前缀及进程号）
+	datePattern string
+	datedName   string
+	dateMatcher *datePatternMatcher
+
+	captureStderr bool
+
+	// watchdog 设置了 WriteTimeout 时执行写入的协程；写入超时后切换到备用目录，
+	// primaryDir 为原来的日志目录，primaryBack 表示挂起的写入已经返回，下次写入时切换回 primaryDir
+	watchdog    *writeWatchdog
+	primaryDir  string
+	primaryBack atomic.Bool
+
+	limiterOnce sync.Once
+	limiter     *tokenBucket
+	dropped     atomic.Int64
+	// writeStart 非阻塞模式下，正在进行的写入的开始时间（UnixNano），slowWrite 上次写入是否耗时过长
+	writeStart atomic.Int64
+	slowWrite  atomic.Bool
+
+	// termQ、asyncQ 运行中的终端输出及异步写入队列，termMu、asyncMu 只在启动、停止时使用
+	termMu sync.Mutex
+	termQ  atomic.Pointer[termQueue]
+
+	asyncMu sync.Mutex
+	asyncQ  atomic.Pointer[asyncQueue]
+
+	teeMu sync.Mutex
+
+	ringOnce sync.Once
+	ring     *ringBuffer
+
+	rotations atomic.Int64
+
+	failures   atomic.Int32
+	failedOver atomic.Bool
+	nextProbe  atomic.Int64
+	dirty      atomic.Bool
+
+	ctlMu       sync.Mutex
+	ctlListener net.Listener
+	ctlPath     string
+
+	// bg 运行中的后台协程，见 startBackground
+	bgMu sync.Mutex
+	bg   *background
+
+	compressOnce sync.Once
+	compressCh   chan string
+	compressMu   sync.Mutex
+	compressing  map[string]bool
+
+	millErrors atomic.Int64
+	errMu      sync.Mutex
+	lastErr    error
+
+	shipped  shippedSet
+	verified verifiedSet
 }
 
 // RotateFile 滚动文件大小
@@ -88,17 +166,77 @@ type RotateFile interface {
 	Rotate() error
 	Flush() error
 
+	// RotateWithSuffix 同 Rotate，历史文件名的时间戳之后带上标签 label，例如 app.20240101T101500.000.deploy-v1.2.3.log
+	RotateWithSuffix(label string) error
+
+	// RotateNow 同 Rotate，返回刚刚滚动出的历史文件路径，便于直接交给上传或分析任务，无需扫描目录
+	RotateNow() (string, error)
+
 	// GetFilename 取得日志文件的距离路径
 	GetFilename() string
+
+	// CaptureStderr 将进程标准错误重定向到当前日志文件，滚动后自动重定向到新的日志文件
+	// 使得未捕获的 panic 调用栈以及 C 库写入 stderr 的内容也能记录到日志文件中
+	CaptureStderr() error
+
+	// Dropped 返回因限速等原因被丢弃的写入次数
+	Dropped() int64
+
+	// TailBuffer 返回内存中保留的最近写入日志文件的内容（最多 TailBufferSize 字节）
+	// 可用于崩溃处理或调试接口展示最近日志，无需回读磁盘文件
+	TailBuffer() []byte
+
+	// TailLines 返回最近写入的 n 行日志，必要时跨越滚动的历史文件（包括压缩文件）读取
+	TailLines(n int) ([]string, error)
+
+	// Grep 在当前日志文件及历史文件中搜索匹配正则 pattern 的行，按时间顺序流式返回
+	// since/until 根据历史文件名中的滚动时间（启用 Manifest 时结合清单记录的起止时间）跳过无关文件，零值表示不限制
+	Grep(pattern string, since, until time.Time) (io.ReadCloser, error)
+
+	// Stats 返回日志文件的运行状态
+	Stats() Stats
+
+	// PlanCleanup 不做任何修改，返回按当前配置清理时将要执行的操作（压缩、删除、移入隔离目录）及原因
+	PlanCleanup() ([]Action, error)
+
+	// Backups 返回历史文件列表，按滚动时间从新到旧排序
+	Backups() ([]BackupInfo, error)
+
+	// ReadFrom 从 r 中分块读取并写入，超过 MaxSize 时按块滚动，而不是报错
+	io.ReaderFrom
+
+	// WriteV 依次写入多个缓冲，不合并复制，写入期间不会穿插其它写入，必要时在缓冲之间滚动
+	WriteV(bufs [][]byte) (int, error)
+
+	// Child 返回分类为 suffix 的子日志文件，例如 app_slow.log，共享配置及 TotalSizeCap 额度
+	Child(suffix string) RotateFile
+
+	// LastError 返回最近一次后台清理（压缩、删除等）的错误，没有错误时返回 nil
+	LastError() error
 }
 
-// New 创建新一个新的滚动文件对象
+// New 创建新一个新的滚动文件对象，日志文件路径在首次写入时确定
 func New(fns ...ConfigFn) RotateFile {
 	return &file{
 		Config: createConfig(fns...),
 	}
 }
 
+// Open 同 New，但立即确定日志文件路径，没有可写的日志目录时返回 ErrNoLogDir，
+// 便于在启动时发现配置问题，而不是在首次写入时
+func Open(fns ...ConfigFn) (RotateFile, error) {
+	l := &file{Config: createConfig(fns...)}
+
+	l.mu.Lock()
+	l.mill()
+	err := l.setupErr
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
 var (
 	// currentTime exists, so it can be mocked out by tests.
 	currentTime = time.Now
@@ -111,10 +249,16 @@ var (
 // than MaxSize, the file is closed, renamed to include a timestamp of the
 // current time, and a new log file is created using the original log file name.
 // If the length of to write is greater than MaxSize, an error is returned.
+// With AsyncWrite, p is queued and written by a single writer goroutine,
+// and errors are not returned to the caller.
 func (l *file) Write(p []byte) (n int, err error) {
-	n, err = l.writeInternal(p)
-	if err != nil {
-		q.Q(err)
+	if l.AsyncWrite {
+		return l.writeAsync(p)
+	}
+
+	n, err = l.failoverWrite(p, l.writeInternal)
+	if err != nil && err != ErrWouldBlock {
+		debugf("write %s: %v", l.filename(), err)
 	}
 	return
 }
@@ -122,15 +266,54 @@ func (l *file) Write(p []byte) (n int, err error) {
 const DAY = 24 * time.Hour
 
 func (l *file) writeInternal(p []byte) (n int, err error) {
+	origLen := len(p)
+	p, ok := l.beforeWrite(p)
+	if !ok {
+		return origLen, nil
+	}
+
+	if l.NonBlocking {
+		n, err = l.tryWriteFile(p)
+	} else {
+		n, err = l.writeFile(p)
+	}
+	if err == nil {
+		n = origLen
+	}
+	return n, err
+}
+
+// beforeWrite 写入文件前的处理：限速、终端输出、Tee 以及清理控制字符，返回 false 表示丢弃
+func (l *file) beforeWrite(p []byte) ([]byte, bool) {
+	if l.rateLimited(l.now()) {
+		return nil, false
+	}
+
 	if l.PrintTerm {
-		os.Stdout.Write(p)
+		l.writeTerm(p)
+	}
+	if len(l.Tee) > 0 {
+		l.writeTee(p)
 	}
 
-	writeTime := currentTime()
+	if l.Sanitize {
+		p = sanitize(p)
+	}
+	return p, true
+}
+
+// writeFile 将 p 写入当前日志文件，必要时滚动
+func (l *file) writeFile(p []byte) (n int, err error) {
+	writeTime := l.now()
 
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	return l.writeFileLocked(p, writeTime)
+}
+
+// writeFileLocked 同 writeFile，调用方需持有 l.mu
+func (l *file) writeFileLocked(p []byte, writeTime time.Time) (n int, err error) {
 	writeLen := int64(len(p))
 	if writeLen > l.max() {
 		return 0, fmt.Errorf(
@@ -138,8 +321,20 @@ func (l *file) writeInternal(p []byte) (n int, err error) {
 		)
 	}
 
+	if l.datePattern != "" && l.file != nil {
+		if err = l.switchDatedFile(writeTime); err != nil {
+			return 0, err
+		}
+	}
+	if err = l.leaveFallback(); err != nil {
+		return 0, err
+	}
 	if l.file == nil {
 		if err = l.openExistingOrNew(); err != nil {
+			if l.setupErr != nil && l.StderrFallback {
+				// 库不应导致宿主程序崩溃或丢失日志，没有可写的日志目录时改为写入标准错误
+				return os.Stderr.Write(p)
+			}
 			return 0, err
 		}
 	}
@@ -151,9 +346,17 @@ func (l *file) writeInternal(p []byte) (n int, err error) {
 		}
 	}
 
-	n, err = l.file.Write(p)
+	n, err = l.writeWithRetry(p)
 	l.lastWrite = writeTime
 	l.size.Add(int64(n))
+	l.dirty.Store(true)
+	if err == nil && l.syncAfterWrite(p) {
+		err = l.file.Sync()
+	}
+
+	if r := l.tailBuffer(); r != nil {
+		r.Write(p[:n])
+	}
 
 	return n, err
 }
@@ -161,6 +364,16 @@ func (l *file) writeInternal(p []byte) (n int, err error) {
 // Flush 刷新文件缓存到磁盘
 // 当写入 warn 级别以上日志时，建议写完后，Flush 刷盘
 func (l *file) Flush() error {
+	if l.AsyncWrite {
+		l.flushAsync()
+	}
+	if l.PrintTerm {
+		l.flushTerm()
+		if s, ok := l.termWriter().(interface{ Sync() error }); ok {
+			s.Sync()
+		}
+	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -168,17 +381,161 @@ func (l *file) Flush() error {
 		return l.file.Sync()
 	}
 
-	if l.PrintTerm {
-		os.Stdout.Sync()
+	return nil
+}
+
+// termWriter 返回终端输出目标，默认 os.Stdout
+func (l *file) termWriter() io.Writer {
+	if l.TermWriter != nil {
+		return l.TermWriter
 	}
+	return os.Stdout
+}
 
-	return nil
+// termQueueSize 终端异步输出队列长度，队列满时丢弃终端输出，以免终端（或管道）缓慢阻塞日志写入
+const termQueueSize = 1024
+
+// termMsg 终端异步输出队列中的消息，done 非空时表示刷新请求，stop 时输出协程随后退出
+type termMsg struct {
+	p    []byte
+	done chan struct{}
+	stop bool
+}
+
+// writeTerm 同时在终端上异步输出，TermFilter 返回 false 时不输出
+func (l *file) writeTerm(p []byte) {
+	if l.TermFilter != nil && !l.TermFilter(p) {
+		return
+	}
+
+	q := l.termQueue()
+	if !q.enter() {
+		return // 正在关闭，丢弃终端输出
+	}
+	select {
+	case q.ch <- termMsg{p: append([]byte(nil), p...)}:
+	default:
+	}
+	q.leave()
+}
+
+// termQueue 终端异步输出队列
+type termQueue struct {
+	ch chan termMsg
+	queueGate
+}
+
+// termQueue 返回终端异步输出队列，输出协程未运行（首次输出或者关闭后）时启动
+func (l *file) termQueue() *termQueue {
+	if q := l.termQ.Load(); q != nil {
+		return q
+	}
+
+	l.termMu.Lock()
+	defer l.termMu.Unlock()
+
+	if q := l.termQ.Load(); q != nil {
+		return q
+	}
+	q := &termQueue{ch: make(chan termMsg, termQueueSize), queueGate: queueGate{exited: make(chan struct{})}}
+	go l.termLoop(q, l.termWriter())
+	l.termQ.Store(q)
+	return q
+}
+
+// termLoop 终端异步输出协程，收到 stop 消息后退出
+func (l *file) termLoop(q *termQueue, w io.Writer) {
+	defer close(q.exited)
+
+	for m := range q.ch {
+		if m.done != nil {
+			close(m.done)
+			if m.stop {
+				return
+			}
+			continue
+		}
+		w.Write(m.p)
+	}
+}
+
+// flushTerm 等待终端异步输出队列中已有的内容输出完毕，输出协程未运行时直接返回
+func (l *file) flushTerm() {
+	q := l.termQ.Load()
+	if q == nil {
+		return
+	}
+	if !q.enter() {
+		<-q.exited
+		return
+	}
+	done := make(chan struct{})
+	q.ch <- termMsg{done: done}
+	q.leave()
+	<-done
+}
+
+// stopTerm 输出队列中已有的内容后，停止终端异步输出协程
+func (l *file) stopTerm() {
+	l.termMu.Lock()
+	q := l.termQ.Swap(nil)
+	l.termMu.Unlock()
+
+	if q != nil {
+		q.stop()
+		q.ch <- termMsg{done: make(chan struct{}), stop: true}
+		<-q.exited
+	}
 }
 
 func (l *file) Close() error {
+	// 先停止后台协程，以免其中的定时刷盘在异步写入协程停止后又将其重新启动
+	l.stopBackground()
+	l.stopAsync()
+	l.stopTerm()
+
+	l.closeCtl()
+	unregister(l)
+	defer l.notifyBackups()
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	return l.close()
+	if l.watchdog != nil {
+		l.watchdog.stop(nil)
+		l.watchdog = nil
+	}
+	if err := l.close(); err != nil {
+		return err
+	}
+	if l.CompressOnClose {
+		return l.compressOnClose()
+	}
+	return nil
+}
+
+// compressOnClose 关闭时，将当前日志文件滚动为历史文件并立即压缩，
+// 以便短时运行的批处理任务结束时，日志文件已经压缩
+func (l *file) compressOnClose() error {
+	if l.filename() == "" {
+		return nil
+	}
+	info, err := osStat(l.filename())
+	if err != nil || info.Size() == 0 {
+		return nil
+	}
+
+	// 等待清理协程中的中断压缩处理完成，以免删除本次压缩的临时文件
+	l.recoverCompressionsOnce()
+	name := backupName(l.filename(), l.now(), l.UtcTime, "")
+	// 标记正在压缩，以免清理 goroutine 同时压缩
+	rel := filepath.Base(name)
+	l.markCompressing(rel)
+	defer l.unmarkCompressing(rel)
+
+	if err := os.Rename(l.filename(), name); err != nil {
+		return fmt.Errorf("can't rename log file: %s", err)
+	}
+	return l.compressFile(rel)
 }
 
 // close closes the file if it is open.
@@ -186,6 +543,12 @@ func (l *file) close() error {
 	if l.file == nil {
 		return nil
 	}
+	if l.Preallocate {
+		// 释放文件末尾之后预分配但未使用的空间
+		if info, err := l.file.Stat(); err == nil {
+			_ = l.file.Truncate(info.Size())
+		}
+	}
 	err := l.file.Close()
 	l.file = nil
 	return err
@@ -202,71 +565,127 @@ func (l *file) Rotate() error {
 	return l.rotate()
 }
 
+// RotateWithSuffix 同 Rotate，但在历史文件名的时间戳之后加上标签 label，
+// 例如 app.20240101T101500.000.deploy-v1.2.3.log，便于找到某次发布之前的日志
+func (l *file) RotateWithSuffix(label string) error {
+	if strings.ContainsAny(label, `/\`) || strings.HasPrefix(label, ".") || strings.HasSuffix(label, ".") {
+		return fmt.Errorf("invalid rotate label %q", label)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := l.rotateLabel(label)
+	return err
+}
+
+// RotateNow 同 Rotate，返回刚刚滚动出的历史文件路径，当前没有日志文件时返回空，
+// 开启压缩时，该文件随后会被压缩为 {路径}{压缩后缀}
+func (l *file) RotateNow() (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rotateLabel("")
+}
+
 // rotate closes the current file, moves it aside with a timestamp in the name,
 // (if it exists), opens a new file with the original filename, and then runs
 // post-rotation processing and removal.
 func (l *file) rotate() error {
+	_, err := l.rotateLabel("")
+	return err
+}
+
+// rotateLabel 滚动日志文件，历史文件名中带上标签 label（可为空），返回滚动出的历史文件路径
+func (l *file) rotateLabel(label string) (string, error) {
+	if l.DropPageCache && l.file != nil {
+		_ = dropPageCache(l.file)
+	}
 	if err := l.close(); err != nil {
-		return err
+		return "", err
 	}
-	if err := l.openNew(); err != nil {
-		return err
+	backup, err := l.openNew(label)
+	if err != nil {
+		return "", err
 	}
+	l.rotations.Add(1)
 	l.mill()
-	return nil
+	return backup, nil
 }
 
 // openNew opens a new log file for writing, moving any old log file out of the
-// way.  These methods assume the file has already been closed.
-func (l *file) openNew() error {
-	err := os.MkdirAll(l.dir, 0o755)
+// way, with the optional label appended to the backup timestamp, and returns
+// the backup path (empty if there was no old log file).  These methods assume
+// the file has already been closed.
+func (l *file) openNew(label string) (string, error) {
+	err := os.MkdirAll(l.dir(), 0o755)
 	if err != nil {
-		return fmt.Errorf("can't make directories for new logfile: %s", err)
+		return "", fmt.Errorf("can't make directories for new logfile: %s", err)
 	}
 
-	name := l.filename
+	name := l.filename()
 	mode := os.FileMode(0o600)
-	if info, err := osStat(name); err == nil && info != nil {
+	info, err := osStat(name)
+	if err != nil {
+		info = nil
+	}
+	var backup string
+	if info != nil {
 		// Copy the mode off the old logfile.
 		mode = info.Mode()
 		// move the existing file
-		newName := backupName(name, l.UtcTime)
+		newName := backupName(name, l.now(), l.UtcTime, label)
 		if err := os.Rename(name, newName); err != nil {
-			return fmt.Errorf("can't rename log file: %s", err)
+			return "", fmt.Errorf("can't rename log file: %s", err)
 		}
-
-		// this is a no-op anywhere but linux
-		if err := chown(name, info); err != nil {
-			return err
+		if err := syncDir(l.dir()); err != nil {
+			return "", fmt.Errorf("can't sync log dir: %s", err)
 		}
+		backup = newName
+		l.emit(Event{Type: Rotated, Path: newName})
+		// 压缩的历史文件，在压缩完成后设置只读并回调 OnBackup
+		if !l.Compress {
+			l.protectBackup(newName)
+			l.backupDone(newName)
+		}
+	}
+
+	// without Owner/Group, this copies the owner of the old logfile,
+	// and is a no-op anywhere but linux
+	if err := l.chown(name, info); err != nil {
+		return "", err
 	}
 
 	// we use truncate here because this should only get called when we've moved
 	// the file ourselves. if someone else creates the file in the meantime,
 	// just wipe out the contents.
-	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|l.syncFlag(), mode)
 	if err != nil {
-		return fmt.Errorf("can't open new logfile: %s", err)
+		return "", fmt.Errorf("can't open new logfile: %s", err)
 	}
-	l.file = f
-	l.size.Store(0)
-	return nil
+	if l.Preallocate {
+		if err := preallocate(f, l.max()); err != nil {
+			debugf("preallocate %s: %v", name, err)
+		}
+	}
+	l.setFile(f, 0)
+	return backup, nil
 }
 
-// backupName creates a new filename from the given name, inserting a timestamp
-// between the filename and the extension, using the local time if requested
-// (otherwise UTC).
-func backupName(name string, utc bool) string {
+// backupName creates a new filename from the given name, inserting the timestamp t
+// (followed by the label, if any) between the filename and the extension, using
+// the local time if requested (otherwise UTC).
+func backupName(name string, t time.Time, utc bool, label string) string {
 	dir := filepath.Dir(name)
 	filename := filepath.Base(name)
 	ext := filepath.Ext(filename)
 	prefix := filename[:len(filename)-len(ext)]
-	t := currentTime()
 	if utc {
 		t = t.UTC()
 	}
 
 	timestamp := t.Format(backupTimeFormat)
+	if label != "" {
+		timestamp += "." + label
+	}
 	return filepath.Join(dir, fmt.Sprintf("%s.%s%s", prefix, timestamp, ext))
 }
 
@@ -275,56 +694,160 @@ func backupName(name string, utc bool) string {
 // put it over the MaxSize, a new file is created.
 func (l *file) openExistingOrNew() error {
 	l.mill()
+	if l.setupErr != nil {
+		return l.setupErr
+	}
+	register(l)
 
-	filename := l.filename
-	info, err := osStat(filename)
-	if os.IsNotExist(err) {
-		return l.openNew()
+	// Open directly and take the size from the file offset, rather than
+	// stat-ing the path first: this saves a path lookup on every reopen,
+	// e.g. after idle Close/Write cycles.
+	file, err := os.OpenFile(l.filename(), os.O_APPEND|os.O_WRONLY|l.syncFlag(), 0o644)
+	if err != nil {
+		// if the file doesn't exist, or we fail to open the old log file for
+		// some reason, just ignore it and open a new log file.
+		_, err = l.openNew("")
+		return err
 	}
+	size, err := file.Seek(0, io.SeekEnd)
 	if err != nil {
+		file.Close()
 		return fmt.Errorf("error getting log file info: %s", err)
 	}
+	l.setFile(file, size)
+	return nil
+}
 
-	var size int64
-	if info != nil {
-		size = info.Size()
+func (l *file) GetFilename() string {
+	return l.filename()
+}
+
+// CaptureStderr 将进程标准错误重定向到当前日志文件，滚动后自动重定向到新的日志文件
+// 注意：直接写入 stderr 的内容不计入当前文件大小
+func (l *file) CaptureStderr() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		if err := l.openExistingOrNew(); err != nil {
+			return err
+		}
 	}
 
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o644)
-	if err != nil {
-		// if we fail to open the old log file for some reason, just ignore
-		// it and open a new log file.
-		return l.openNew()
+	if err := dupStderr(l.file); err != nil {
+		return fmt.Errorf("can't redirect stderr to logfile: %s", err)
 	}
-	l.file = file
-	l.size.Store(size)
+
+	l.captureStderr = true
 	return nil
 }
 
-func (l *file) GetFilename() string {
-	return l.filename
+// setFile 设置当前写入的日志文件，以及其已有大小
+func (l *file) setFile(f *os.File, size int64) {
+	l.file = f
+	l.size.Store(size)
+	l.emit(Event{Type: FileOpened, Path: f.Name()})
+	l.startBackground()
+
+	if l.captureStderr {
+		if err := dupStderr(f); err != nil {
+			debugf("capture stderr: %v", err)
+		}
+	}
 }
 
-// setFileName generates the name of the logfile from the current time.
-func (l *file) setFileName() {
-	l.filename, l.flock = GenerateFilename(l.AppName, l.Prefix, l.Filename, true)
-	l.dir = filepath.Dir(l.filename)
+// logPaths 日志文件路径、所在目录及文件名锁
+type logPaths struct {
+	filename string
+	dir      string
+	lock     *flock.Flock
 }
 
-// GenerateFilename 根据 filename 生成完整的
-// 1. filename 为 /some/path/xxx.log, 则继续保持
-// 2. filename 为 /some/path/, 则补齐日志文件名为: {appName}{currentDirBase}.log
-// 3. filename 为 空, 则根据 FindLogDir 生成指定的日志目录，日志文件名见上
-func GenerateFilename(appName, prefix, filename string, tryLock bool) (string, *flock.Flock) {
-	logDir, logName := filename, ""
-	if strings.HasSuffix(filename, ".log") {
-		// 配置的是具体的日志文件名称（推荐的配置）
-		logDir = filepath.Dir(filename)
-		logName = filepath.Base(filename)
+// setPaths 切换到日志文件 filename，lock 为其文件名锁
+func (l *file) setPaths(filename string, lock *flock.Flock) {
+	l.paths.Store(&logPaths{filename: filename, dir: filepath.Dir(filename), lock: lock})
+}
+
+// setDir 只设置日志目录，用于不写入日志文件、只处理历史文件的实例
+func (l *file) setDir(dir string) {
+	l.paths.Store(&logPaths{dir: dir})
+}
+
+// loadPaths 返回当前的日志文件路径，尚未生成文件名时为零值
+func (l *file) loadPaths() logPaths {
+	if p := l.paths.Load(); p != nil {
+		return *p
 	}
+	return logPaths{}
+}
 
-	// 否则当做日志路径看待，日志文件名自动补全
-	return getLogFileName(appName, logDir, prefix, logName, tryLock)
+// filename 返回当前日志文件路径
+func (l *file) filename() string { return l.loadPaths().filename }
+
+// dir 返回当前日志文件所在目录
+func (l *file) dir() string { return l.loadPaths().dir }
+
+// fileLock 返回当前日志文件名的锁，未锁定时为 nil
+func (l *file) fileLock() *flock.Flock { return l.loadPaths().lock }
+
+// setFileName generates the name of the logfile from the current time,
+// expanding the {hostname}, {pod} and {pid} placeholders in Filename and Prefix.
+// It returns ErrNoLogDir if none of the candidate directories is writable.
+func (l *file) setFileName() error {
+	l.Filename = expandPlaceholders(l.Filename)
+	l.Prefix = expandPlaceholders(l.Prefix)
+	filename := l.Filename
+	if hasDatePattern(filepath.Base(filename)) {
+		l.datePattern = filepath.Base(filename)
+		filename = filepath.Join(filepath.Dir(filename), formatDatePattern(l.datePattern, l.patternTime(l.now())))
+		loc := time.Local
+		if l.UtcTime {
+			loc = time.UTC
+		}
+		var suffixes []string
+		for _, c := range l.compressors() {
+			suffixes = append(suffixes, c.Suffix())
+		}
+		l.dateMatcher = newDatePatternMatcher(l.Prefix+l.datePattern, suffixes, loc)
+		l.datedName = filepath.Base(filename)
+	}
+	return l.generateFilename(filename)
+}
+
+// resolveFileName 检查配置并生成日志文件路径（尚未生成时），返回 setupErr，
+// 不启动清理协程、控制通道等后台任务，供只读接口使用，调用方需持有 l.mu
+func (l *file) resolveFileName() error {
+	if l.filename() == "" && l.setupErr == nil {
+		if l.setupErr = l.checkCompressFormat(); l.setupErr == nil {
+			l.setupErr = l.setFileName()
+		}
+	}
+	return l.setupErr
+}
+
+// generateFilename 按 filename 及配置的候选目录、前缀生成日志文件路径，并锁定文件名
+func (l *file) generateFilename(filename string) error {
+	candidates := l.DirCandidates
+	if l.LogDir != "" {
+		if len(candidates) == 0 {
+			candidates = LogDirCandidates(l.AppName)
+		}
+		candidates = append([]string{l.LogDir}, candidates...)
+	}
+
+	name, lock, err := GenerateFilename(FilenameOptions{
+		AppName:       l.AppName,
+		Prefix:        l.Prefix,
+		Filename:      filename,
+		DirCandidates: candidates,
+		TryLock:       !l.DisableLock,
+		LockDir:       l.LockDir,
+	})
+	if err != nil {
+		return err
+	}
+	l.setPaths(name, lock)
+	return nil
 }
 
 // millRunOnce performs compression and removal of stale log files.
@@ -332,16 +855,35 @@ func GenerateFilename(appName, prefix, filename string, tryLock bool) (string, *
 // files are removed, keeping at most l.MaxBackups files, as long as
 // none of them are older than MaxDays.
 func (l *file) millRunOnce() error {
-	if l.MaxBackups == 0 && l.MaxDays == 0 && !l.Compress {
+	defer l.notifyBackups()
+	if !l.MillDryRun && !l.clean.isDryRun() {
+		l.recoverCompressionsOnce()
+	}
+	if l.MaxBackups == 0 && l.maxAge() == 0 && l.MaxCompressedBackups == 0 &&
+		!l.Compress && !l.Manifest && l.BackupSubdirLayout == "" && !l.DailyBundle && l.Archiver == nil && l.TrashDir == "" &&
+		!l.PruneEmptyBackups && !l.VerifyCompressed {
 		return nil
 	}
+	if l.MillDryRun && l.clean == nil {
+		return l.reportPlan()
+	}
 
+	dryRun := l.clean.isDryRun()
+	if !dryRun {
+		l.adoptOrphanLogs()
+	}
 	files, err := l.oldLogFiles()
 	if err != nil {
 		return err
 	}
 
+	if l.BackupSubdirLayout != "" && !dryRun {
+		files = l.archiveToSubdirs(files)
+	}
+	files, err = l.pruneBackups(files)
+
 	var compress, remove []logInfo
+	why := map[string]string{} // 删除原因
 
 	if l.MaxBackups > 0 && l.MaxBackups < len(files) {
 		preserved := make(map[string]bool)
@@ -349,28 +891,26 @@ func (l *file) millRunOnce() error {
 		for _, f := range files {
 			// Only count the uncompressed log file or the
 			// compressed log file, not both.
-			fn := f.Name
-			if strings.HasSuffix(fn, compressSuffix) {
-				fn = fn[:len(fn)-len(compressSuffix)]
-			}
+			fn := l.trimCompressSuffix(f.Name)
 			preserved[fn] = true
 
 			if len(preserved) > l.MaxBackups {
 				remove = append(remove, f)
+				why[f.Name] = ReasonMaxBackups
 			} else {
 				remaining = append(remaining, f)
 			}
 		}
 		files = remaining
 	}
-	if l.MaxDays > 0 {
-		diff := 24 * time.Hour * time.Duration(l.MaxDays)
-		cutoff := currentTime().Add(-diff)
+	if l.maxAge() > 0 {
+		now := l.now()
 
 		var remaining []logInfo
 		for _, f := range files {
-			if f.timestamp.Before(cutoff) {
+			if l.expired(f.timestamp, now) {
 				remove = append(remove, f)
+				why[f.Name] = ReasonMaxAge
 			} else {
 				remaining = append(remaining, f)
 			}
@@ -378,71 +918,196 @@ func (l *file) millRunOnce() error {
 		files = remaining
 	}
 
+	if l.DailyBundle && !dryRun {
+		var errBundle error
+		if files, errBundle = l.bundleDays(files); errBundle != nil {
+			err = errBundle
+		}
+	}
+
 	if l.Compress {
+		// 最近的历史文件，累计大小在 MaxUncompressedSize 之内的，暂不压缩
+		names := make(map[string]bool, len(files))
 		for _, f := range files {
-			if !strings.HasSuffix(f.Name, compressSuffix) {
-				compress = append(compress, f)
+			names[f.Name] = true
+		}
+
+		var rawSize uint64
+		rawFull := l.MaxUncompressedSize == 0
+		for _, f := range files {
+			if l.compressorOf(f.Name) != nil || l.hasCompressed(f.Name, names) {
+				continue
+			}
+			if !rawFull && rawSize+uint64(f.Size) <= l.MaxUncompressedSize {
+				rawSize += uint64(f.Size)
+				continue
 			}
+			rawFull = true
+			compress = append(compress, f)
+		}
+	}
+
+	if l.MaxCompressedBackups > 0 {
+		n := len(remove)
+		compress, remove = l.keepCompressedBackups(files, compress, remove)
+		for _, f := range remove[n:] {
+			why[f.Name] = ReasonMaxCompressedBackups
 		}
 	}
 
-	dir := l.dir
+	dir := l.dir()
 	for _, f := range remove {
-		removeFile := filepath.Join(dir, f.Name)
-		errRemove := os.Remove(removeFile)
+		if l.keepUnshipped(f.Name) {
+			continue
+		}
+		errRemove := l.retireBackup(f.Name, why[f.Name])
 		if err == nil && errRemove != nil {
 			err = errRemove
 		}
 	}
-	for _, f := range compress {
-		fn := filepath.Join(dir, f.Name)
-		errCompress := compressLogFile(fn, fn+compressSuffix)
-		if err == nil && errCompress != nil {
-			err = errCompress
+	if errCompress := l.compressFiles(compress); err == nil && errCompress != nil {
+		err = errCompress
+	}
+
+	if errPurge := l.purgeTrash(); errPurge != nil && err == nil {
+		err = errPurge
+	}
+
+	if dryRun {
+		if errTotalSizeCap := l.keepTotalSizeCap(dir); errTotalSizeCap != nil && err == nil {
+			err = errTotalSizeCap
 		}
+		return err
+	}
+
+	if errArchive := l.archiveBackups(); errArchive != nil && err == nil {
+		err = errArchive
 	}
 
 	if errTotalSizeCap := l.keepTotalSizeCap(dir); errTotalSizeCap != nil && err == nil {
 		err = errTotalSizeCap
 	}
 
+	if l.Manifest || l.Archiver != nil {
+		if errManifest := l.writeManifest(); errManifest != nil && err == nil {
+			err = errManifest
+		}
+	}
+
 	return err
 }
 
+// keepCompressedBackups 最多保留 MaxCompressedBackups 个压缩的（包括待压缩的）历史文件，
+// 更早的直接删除，不再压缩
+func (l *file) keepCompressedBackups(files, compress, remove []logInfo) ([]logInfo, []logInfo) {
+	toCompress := make(map[string]bool, len(compress))
+	for _, f := range compress {
+		toCompress[f.Name] = true
+	}
+
+	var kept []logInfo
+	count := 0
+	for _, f := range files {
+		if l.compressorOf(f.Name) == nil && !toCompress[f.Name] {
+			continue
+		}
+		if count++; count > l.MaxCompressedBackups {
+			remove = append(remove, f)
+		} else if toCompress[f.Name] {
+			kept = append(kept, f)
+		}
+	}
+
+	return kept, remove
+}
+
 func (l *file) keepTotalSizeCap(dir string) error {
 	var dirDiskFree uint64
+	// 无法获取，或者文件系统没有固定 inode 数时，视为满足 MinFreeInodes
+	dirFreeInodes := l.MinFreeInodes
+	minDiskFree := l.MinDiskFree
 
-	if l.MinDiskFree > 0 {
-		if dirDisk, err := disk.GetInfo(dir, false); err == nil {
+	if l.MinDiskFree > 0 || l.MinFreeInodes > 0 || l.MaxDiskUsage > 0 {
+		if dirDisk, err := getDiskInfo(dir); err == nil {
 			dirDiskFree = dirDisk.Free
+			minDiskFree = l.minDiskFree(dirDisk.Total)
+			if dirDisk.Files > 0 {
+				dirFreeInodes = dirDisk.Ffree
+			}
+			if dirDiskFree < minDiskFree || dirFreeInodes < l.MinFreeInodes {
+				l.emit(Event{Type: DiskLow, Path: dir, Free: dirDiskFree, FreeInodes: dirDisk.Ffree})
+			}
 		}
 	}
 
-	if l.TotalSizeCap <= 0 && (l.MinDiskFree == 0 || dirDiskFree >= l.MinDiskFree) {
+	if l.TotalSizeCap <= 0 && (minDiskFree == 0 || dirDiskFree >= minDiskFree) && dirFreeInodes >= l.MinFreeInodes {
 		return nil
 	}
 
-	files, err := l.oldLogFiles()
+	var files []logInfo
+	var err error
+	totalSize := l.size.Load()
+	if l.AccurateSizeCap {
+		// 以磁盘上的实际大小为准，其它进程也可能追加写入同一个日志文件
+		if info, errStat := osStat(l.filename()); errStat == nil {
+			totalSize = info.Size()
+		}
+	}
+	if l.TotalSizeCapDir {
+		var activeSize int64
+		files, activeSize, err = l.dirLogFiles()
+		totalSize += activeSize
+	} else {
+		files, err = l.oldLogFiles()
+	}
 	if err != nil {
 		return err
 	}
-
-	totalSize := l.size.Load()
-	for _, f := range files {
-		totalSize += f.Size
+	childFiles, childSize, err := l.childLogFiles()
+	totalSize += childSize
+	// TotalSizeCapDir 时，子日志文件及其它实例的历史文件已经包含在目录统计中
+	if !l.TotalSizeCapDir && l.AccurateSizeCap {
+		siblingFiles, siblingSize, errSibling := l.siblingLogFiles()
+		if errSibling != nil && err == nil {
+			err = errSibling
+		}
+		childFiles = append(childFiles, siblingFiles...)
+		totalSize += siblingSize
+	}
+	if !l.TotalSizeCapDir && len(childFiles) > 0 {
+		files = append(files, childFiles...)
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].timestamp.After(files[j].timestamp)
+		})
 	}
 
-	// 从最近的历史文件开始，删除历史文件，以控制总大小
+	// 按 CleanupStrategy 排列删除顺序，files 为从新到旧
+	order := make([]logInfo, 0, len(files))
 	for i := len(files) - 1; i >= 0; i-- {
-		if uint64(totalSize) <= l.TotalSizeCap && (l.MinDiskFree == 0 || dirDiskFree >= l.MinDiskFree) {
+		order = append(order, files[i])
+		totalSize += files[i].Size
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return cleanupBefore(l.CleanupStrategy, order[i], order[j],
+			l.compressorOf(order[i].Name) != nil, l.compressorOf(order[j].Name) != nil)
+	})
+
+	// 删除历史文件，以控制总大小
+	now := l.now()
+	for _, f := range order {
+		if (l.TotalSizeCap <= 0 || uint64(totalSize) <= l.TotalSizeCap) &&
+			(minDiskFree == 0 || dirDiskFree >= minDiskFree) && dirFreeInodes >= l.MinFreeInodes {
 			break
 		}
+		if l.tooYoung(f, now) || l.keepUnshipped(f.Name) {
+			continue
+		}
 
-		f := files[i]
-		if err1 := os.Remove(filepath.Join(dir, f.Name)); err1 == nil {
+		if err1 := l.removeBackup(f.Name, capReason(l.TotalSizeCap, totalSize, dirDiskFree, minDiskFree)); err1 == nil {
 			// 删除成功，从总大小中减去删除文件的大小
 			totalSize -= f.Size
 			dirDiskFree += uint64(f.Size)
+			dirFreeInodes++
 		} else if err == nil {
 			err = err1
 		}
@@ -455,8 +1120,11 @@ func (l *file) keepTotalSizeCap(dir string) error {
 // of old log files.
 func (l *file) millRun() {
 	for range l.millCh {
-		// what am I going to do, log this?
-		_ = l.millRunOnce()
+		l.millError(l.millRunOnce())
+		l.millDone.done()
+		if l.parent != nil {
+			l.parent.mill()
+		}
 	}
 }
 
@@ -464,18 +1132,52 @@ func (l *file) millRun() {
 // starting the mill goroutine if necessary.
 func (l *file) mill() {
 	l.startMill.Do(func() {
-		l.lastWrite = currentTime()
-		l.setFileName()
+		l.lastWrite = l.now()
+		if l.resolveFileName() != nil {
+			return
+		}
 		l.signalRotate()
-		l.millCh = make(chan bool, 1)
-		go l.millRun()
+		l.listenCtl()
+		if l.CloseOnExit {
+			closeOnExit()
+		}
+		if l.SyncMill {
+			// 同步清理，不启动清理协程
+		} else if l.manager != nil {
+			l.millCh = l.manager.millChan()
+		} else {
+			l.millCh = make(chan bool, 1)
+			go l.millRun()
+		}
 	})
+	if l.setupErr != nil {
+		return
+	}
+	if l.SyncMill {
+		l.millSync()
+		return
+	}
 	select {
 	case l.millCh <- true:
 	default:
 	}
 }
 
+// millSync 在调用方（例如 Rotate、Write）中同步执行清理，用于 SyncMill
+func (l *file) millSync() {
+	l.inlineMill.Store(true)
+	defer l.inlineMill.Store(false)
+
+	if l.manager != nil {
+		_ = l.manager.millRunOnce() // 错误已记录到各个日志流
+	} else {
+		l.millError(l.millRunOnce())
+	}
+	if l.parent != nil {
+		l.parent.mill()
+	}
+}
+
 func (l *file) signalRotate() {
 	if len(l.RotateSignals) == 0 {
 		return
@@ -494,17 +1196,41 @@ func (l *file) signalRotate() {
 // oldLogFiles returns the list of backup log files stored in the same
 // directory as the current log file, sorted by ModTime
 // 不包括当前正在写入的日志文件，排序从最新到最老
+// 设置了 BackupSubdirLayout 时，同时查找按日期归档的子目录，Name 为相对于日志目录的路径
 func (l *file) oldLogFiles() ([]logInfo, error) {
-	files, err := os.ReadDir(l.dir)
-	if err != nil {
-		return nil, fmt.Errorf("can't read log file directory: %s", err)
-	}
 	var logFiles []logInfo
 
 	prefix, ext := l.prefixAndExt()
+	if err := l.scanBackups("", l.subdirDepth(), prefix, ext, &logFiles); err != nil {
+		return nil, err
+	}
+	logFiles = l.addManifestBackups(logFiles)
+
+	sort.Slice(logFiles, func(i, j int) bool {
+		return logFiles[i].timestamp.After(logFiles[j].timestamp)
+	})
+
+	return logFiles, nil
+}
+
+// scanBackups 查找日志目录下相对路径为 rel 的目录中的历史文件，depth 为继续查找子目录的层数
+func (l *file) scanBackups(rel string, depth int, prefix, ext string, logFiles *[]logInfo) error {
+	files, err := os.ReadDir(filepath.Join(l.dir(), rel))
+	if err != nil {
+		if rel != "" { // 子目录可能刚好被清理
+			return nil
+		}
+		return fmt.Errorf("can't read log file directory: %s", err)
+	}
 
 	for _, f := range files {
+		name := filepath.Join(rel, f.Name())
 		if f.IsDir() {
+			if depth > 0 && !l.isQuarantineDir(name) {
+				if err := l.scanBackups(name, depth-1, prefix, ext, logFiles); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 		size := int64(0)
@@ -512,23 +1238,12 @@ func (l *file) oldLogFiles() ([]logInfo, error) {
 			size = info.Size()
 		}
 
-		if t, err := l.timeFromName(f.Name(), prefix, ext); err == nil {
-			logFiles = append(logFiles, logInfo{timestamp: t, Name: f.Name(), Size: size})
-			continue
+		if t, ok := l.matchBackup(f.Name(), prefix, ext); ok && !l.clean.isRemoved(name) {
+			*logFiles = append(*logFiles, logInfo{timestamp: t, Name: name, Size: size})
 		}
-		if t, err := l.timeFromName(f.Name(), prefix, ext+compressSuffix); err == nil {
-			logFiles = append(logFiles, logInfo{timestamp: t, Name: f.Name(), Size: size})
-			continue
-		}
-		// error parsing means that the suffix at the end was not generated
-		// by rotatefile, and therefore it's not a backup file.
 	}
 
-	sort.Slice(logFiles, func(i, j int) bool {
-		return logFiles[i].timestamp.After(logFiles[j].timestamp)
-	})
-
-	return logFiles, nil
+	return nil
 }
 
 // ErrMismatched defines the error type for mismatched time format.
@@ -547,6 +1262,9 @@ func (l *file) timeFromName(filename, prefix, ext string) (time.Time, error) {
 	}
 
 	ts := filename[len(prefix):]
+	if len(ts) > len(backupTimeFormat)+1 && ts[len(backupTimeFormat)] == '.' {
+		ts = ts[:len(backupTimeFormat)] // 带标签的历史文件（RotateWithSuffix）
+	}
 	if len(ts) != len(backupTimeFormat) {
 		return time.Time{}, ErrMismatched
 	}
@@ -554,6 +1272,21 @@ func (l *file) timeFromName(filename, prefix, ext string) (time.Time, error) {
 	return time.Parse(backupTimeFormat, ts)
 }
 
+// maxAge returns the maximum age of old log files to retain, the stricter one
+// of MaxDays and MaxAge, or 0 if neither is set.
+func (l *file) maxAge() time.Duration {
+	age := l.MaxAge
+	if l.MaxDays > 0 {
+		if days := DAY * time.Duration(l.MaxDays); age <= 0 || days < age {
+			age = days
+		}
+	}
+	if age < 0 {
+		return 0
+	}
+	return age
+}
+
 // max returns the maximum size in bytes of log files before rolling.
 func (l *file) max() int64 {
 	if l.MaxSize == 0 {
@@ -565,15 +1298,18 @@ func (l *file) max() int64 {
 // prefixAndExt returns the filename part and extension part from the file's
 // filename.
 func (l *file) prefixAndExt() (prefix, ext string) {
-	filename := filepath.Base(l.filename)
+	filename := filepath.Base(l.filename())
 	ext = filepath.Ext(filename)
 	prefix = filename[:len(filename)-len(ext)] + "."
 	return prefix, ext
 }
 
-// compressLogFile compresses the given log file, removing the
-// uncompressed log file if successful.
-func compressLogFile(src, dst string) (err error) {
+// compressLogFile compresses the given log file with the compressor c,
+// removing the uncompressed log file if successful unless CompressKeepSource is set.
+// The compressed data is written to a temp file, synced and verified by
+// reading it back before it is renamed to dst, so the source is never
+// removed before a complete compressed copy exists.
+func (l *file) compressLogFile(src, dst string, c Compressor) (err error) {
 	f, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %v", err)
@@ -585,44 +1321,57 @@ func compressLogFile(src, dst string) (err error) {
 		return fmt.Errorf("failed to stat log file: %v", err)
 	}
 
-	if err := chown(dst, fi); err != nil {
+	tmp := dst + compressTmpSuffix
+	if err := l.chown(tmp, fi); err != nil {
 		return fmt.Errorf("failed to chown compressed log file: %v", err)
 	}
 
 	// If this file already exists, we presume it was created by
 	// a previous attempt to compress the log file.
-	gzf, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	cf, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
 	if err != nil {
 		return fmt.Errorf("failed to open compressed log file: %v", err)
 	}
-	defer gzf.Close()
-
-	gz := gzip.NewWriter(gzf)
+	defer cf.Close()
 
 	defer func() {
 		if err != nil {
-			os.Remove(dst)
+			os.Remove(tmp)
 			err = fmt.Errorf("failed to compress log file: %v", err)
 		}
 	}()
 
-	if _, err := io.Copy(gz, f); err != nil {
+	if err := c.Compress(cf, f); err != nil {
 		return err
 	}
-	if err := gz.Close(); err != nil {
+	if err := cf.Sync(); err != nil {
 		return err
 	}
-	if err := gzf.Close(); err != nil {
+	if l.DropPageCache {
+		_ = dropPageCache(cf)
+		if l.CompressKeepSource {
+			_ = dropPageCache(f)
+		}
+	}
+	if err := cf.Close(); err != nil {
 		return err
 	}
 	if err := f.Close(); err != nil {
 		return err
 	}
-	if err := os.Remove(src); err != nil {
+	if err := verifyCompressed(tmp, c); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
 		return err
 	}
+	if !l.CompressKeepSource {
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	return syncDir(filepath.Dir(dst))
 }
 
 // logInfo is a convenience struct to return the filename and its embedded
@@ -1,14 +1,31 @@
 package rotatefile
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/bingoohuang/rotatefile/disk"
+	"github.com/bingoohuang/rotatefile/flock"
 )
 
 // !!!NOTE!!!
@@ -140,7 +157,8 @@ func TestDefaultFilename(t *testing.T) {
 	currentTime = fakeTime
 
 	appName := filepath.Base(os.Args[0])
-	filename, _ := getLogFileName(appName, "", "", "", false)
+	filename, _, err := GenerateFilename(FilenameOptions{AppName: appName})
+	isNil(err, t)
 	defer os.Remove(filename)
 
 	l := &file{Config: Config{AppName: appName}}
@@ -792,6 +810,3245 @@ func TestJson(t *testing.T) {
 	equals(true, l.Compress, t)
 }
 
+func TestRateLimit(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRateLimit", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:  filename,
+		RateLimit: 1,
+		RateBurst: 2,
+	}}
+	defer l.Close()
+
+	b := []byte("boo!")
+	for i := 0; i < 3; i++ {
+		n, err := l.Write(b)
+		isNil(err, t)
+		equals(len(b), n, t)
+	}
+
+	existsWithContent(filename, append(b, b...), t)
+	equals(int64(1), l.Dropped(), t)
+
+	fakeCurrentTime = fakeCurrentTime.Add(time.Second)
+	_, err := l.Write(b)
+	isNil(err, t)
+	existsWithContent(filename, []byte("boo!boo!boo!"), t)
+	equals(int64(1), l.Dropped(), t)
+}
+
+func TestSanitize(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestSanitize", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename: filename,
+		Sanitize: true,
+	}}
+	defer l.Close()
+
+	b := []byte("\x1b[31mred\x1b[0m\tbo\x00o\x1b]0;title\x07!\n")
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+	existsWithContent(filename, []byte("red\tboo!\n"), t)
+}
+
+func TestPrintTerm(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestPrintTerm", t)
+	defer os.RemoveAll(dir)
+
+	var term bytes.Buffer
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:   filename,
+		PrintTerm:  true,
+		TermWriter: &term,
+		TermFilter: func(p []byte) bool { return !bytes.HasPrefix(p, []byte("D!")) },
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("D! debug\n"))
+	isNil(err, t)
+	_, err = l.Write([]byte("W! warn\n"))
+	isNil(err, t)
+	isNil(l.Flush(), t)
+
+	equals("W! warn\n", term.String(), t)
+	existsWithContent(filename, []byte("D! debug\nW! warn\n"), t)
+
+	// 关闭时输出剩余内容并停止输出协程，再次写入时重新启动
+	_, err = l.Write([]byte("I! info\n"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+	equals("W! warn\nI! info\n", term.String(), t)
+	assert(l.termQ.Load() == nil, t, "expected terminal writer stopped")
+	_, err = l.Write([]byte("E! error\n"))
+	isNil(err, t)
+	isNil(l.Flush(), t)
+	equals("W! warn\nI! info\nE! error\n", term.String(), t)
+}
+
+func TestPrintTermDrop(t *testing.T) {
+	dir := makeTempDir("TestPrintTermDrop", t)
+	defer os.RemoveAll(dir)
+
+	var term bytes.Buffer
+	l := &file{Config: Config{
+		Filename:   logFile(dir),
+		PrintTerm:  true,
+		TermWriter: &term,
+	}}
+	defer l.Close()
+
+	// 不启动输出协程，队列容量为 1，以便队列保持满的状态
+	q := &termQueue{ch: make(chan termMsg, 1)}
+	l.termQ.Store(q)
+	for _, s := range []string{"boo!\n", "foo!\n", "bar!\n"} {
+		_, err := l.Write([]byte(s))
+		isNil(err, t)
+	}
+	equals(1, len(q.ch), t)
+	equals("boo!\n", string((<-q.ch).p), t)
+	l.termQ.Store(nil)
+
+	// 写入日志文件不受影响
+	existsWithContent(logFile(dir), []byte("boo!\nfoo!\nbar!\n"), t)
+	equals("", term.String(), t)
+}
+
+func TestTee(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestTee", t)
+	defer os.RemoveAll(dir)
+
+	var tee1, tee2 bytes.Buffer
+	filename := logFile(dir)
+	l := New(WithFilename(filename), WithPrintTerm(false), WithTee(&tee1), WithTee(&tee2))
+	defer l.Close()
+
+	b := []byte("boo!")
+	n, err := l.Write(b)
+	isNil(err, t)
+	equals(len(b), n, t)
+
+	existsWithContent(filename, b, t)
+	equals(b, tee1.Bytes(), t)
+	equals(b, tee2.Bytes(), t)
+}
+
+func TestTailBuffer(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestTailBuffer", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:       logFile(dir),
+		TailBufferSize: 10,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	equals([]byte("boo!"), l.TailBuffer(), t)
+
+	_, err = l.Write([]byte("foooooo!"))
+	isNil(err, t)
+	equals([]byte("o!foooooo!"), l.TailBuffer(), t)
+
+	_, err = l.Write([]byte("0123456789abc"))
+	isNil(err, t)
+	equals([]byte("3456789abc"), l.TailBuffer(), t)
+}
+
+func TestTailLines(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestTailLines", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Compress: true,
+		Filename: logFile(dir),
+		UtcTime:  true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("line1\nline2\n"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	// we need to wait a little bit since the files get compressed on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+	exists(backupFile(dir)+compressSuffix, t)
+
+	_, err = l.Write([]byte("line3\nline4\n"))
+	isNil(err, t)
+
+	lines, err := l.TailLines(1)
+	isNil(err, t)
+	equals([]string{"line4"}, lines, t)
+
+	lines, err = l.TailLines(3)
+	isNil(err, t)
+	equals([]string{"line2", "line3", "line4"}, lines, t)
+
+	lines, err = l.TailLines(10)
+	isNil(err, t)
+	equals([]string{"line1", "line2", "line3", "line4"}, lines, t)
+}
+
+func TestGrep(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestGrep", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Compress: true,
+		Filename: logFile(dir),
+		UtcTime:  true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("foo 1\nbar 2\n"))
+	isNil(err, t)
+	newFakeTime()
+	rotated := fakeCurrentTime
+	isNil(l.Rotate(), t)
+
+	// we need to wait a little bit since the files get compressed on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+	exists(backupFile(dir)+compressSuffix, t)
+
+	_, err = l.Write([]byte("foo 3\nbar 4\n"))
+	isNil(err, t)
+
+	grep := func(since, until time.Time) string {
+		r, err := l.Grep("^foo", since, until)
+		isNilUp(err, t, 1)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		isNilUp(err, t, 1)
+		return string(b)
+	}
+
+	equals("foo 1\nfoo 3\n", grep(time.Time{}, time.Time{}), t)
+	equals("foo 3\n", grep(rotated.Add(time.Second), time.Time{}), t)
+	equals("foo 1\n", grep(time.Time{}, rotated.Add(-time.Second)), t)
+}
+
+func TestGrepBeforeWrite(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestGrepBeforeWrite", t)
+	defer os.RemoveAll(dir)
+
+	isNil(os.WriteFile(backupFile(dir), []byte("foo 1\nbar 2\n"), 0o644), t)
+
+	l := &file{Config: Config{Filename: logFile(dir), UtcTime: true}}
+	defer l.Close()
+
+	// 只读接口只生成文件名，不启动清理协程
+	r, err := l.Grep("^foo", time.Time{}, time.Time{})
+	isNil(err, t)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	isNil(err, t)
+	equals("foo 1\n", string(b), t)
+	assert(l.millCh == nil, t, "mill goroutine started")
+}
+
+func TestManifest(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestManifest", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		UtcTime:  true,
+		Manifest: true,
+	}}
+	defer l.Close()
+
+	b := []byte("boo!")
+	_, err := l.Write(b)
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	// we need to wait a little bit since the manifest gets written on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	m, err := LoadManifest(filepath.Join(dir, "foobar.manifest.json"))
+	isNil(err, t)
+	equals("foobar.log", m.Filename, t)
+	equals(1, len(m.Backups), t)
+	equals(filepath.Base(backupFile(dir)), m.Backups[0].Name, t)
+	equals(int64(len(b)), m.Backups[0].Size, t)
+	equals(false, m.Backups[0].Compressed, t)
+	equals(64, len(m.Backups[0].SHA256), t)
+}
+
+func TestBackupMatcher(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestBackupMatcher", t)
+	defer os.RemoveAll(dir)
+
+	data := []byte("data")
+	legacy := filepath.Join(dir, "foobar_2014-05-04.log")
+	isNil(os.WriteFile(legacy, data, 0o644), t)
+	other := filepath.Join(dir, "foobar_other.log")
+	isNil(os.WriteFile(other, data, 0o644), t)
+
+	matcher := BackupMatcherFunc(func(filename, name string) (time.Time, bool) {
+		prefix := strings.TrimSuffix(filename, ".log") + "_"
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".log") {
+			return time.Time{}, false
+		}
+		t, err := time.Parse("2006-01-02", name[len(prefix):len(name)-len(".log")])
+		return t, err == nil
+	})
+
+	l := &file{Config: Config{
+		Filename:       logFile(dir),
+		MaxDays:        1,
+		BackupMatchers: []BackupMatcher{matcher},
+	}}
+	defer l.Close()
+
+	_, err := l.Write(data)
+	isNil(err, t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	notExist(legacy, t)
+	exists(other, t)
+}
+
+func TestLumberjackCompat(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestLumberjackCompat", t)
+	defer os.RemoveAll(dir)
+
+	data := []byte("data")
+	legacy := filepath.Join(dir, "foobar-2014-05-04T14-44-33.555.log")
+	isNil(os.WriteFile(legacy, data, 0o644), t)
+	legacyGz := filepath.Join(dir, "foobar-2014-05-05T14-44-33.555.log.gz")
+	isNil(os.WriteFile(legacyGz, data, 0o644), t)
+
+	l := &file{Config: Config{
+		Filename:         logFile(dir),
+		MaxDays:          1,
+		LumberjackCompat: true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write(data)
+	isNil(err, t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	notExist(legacy, t)
+	notExist(legacyGz, t)
+	fileCount(dir, 1, t)
+}
+
+func TestMaxAge(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestMaxAge", t)
+	defer os.RemoveAll(dir)
+
+	data := []byte("data")
+	old := filepath.Join(dir, "foobar."+fakeTime().Add(-2*time.Hour).UTC().Format(backupTimeFormat)+".log")
+	isNil(os.WriteFile(old, data, 0o644), t)
+	recent := filepath.Join(dir, "foobar."+fakeTime().Add(-30*time.Minute).UTC().Format(backupTimeFormat)+".log")
+	isNil(os.WriteFile(recent, data, 0o644), t)
+
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		MaxDays:  30,
+		MaxAge:   time.Hour,
+	}}
+	defer l.Close()
+	equals(time.Hour, l.maxAge(), t)
+
+	_, err := l.Write(data)
+	isNil(err, t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	notExist(old, t)
+	exists(recent, t)
+}
+
+func TestCalendarDays(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	now := time.Date(2024, 1, 3, 1, 0, 0, 0, loc)
+	l := &file{Config: Config{
+		MaxDays:       2,
+		UtcTime:       true,
+		CalendarDays:  true,
+		RetentionZone: "Asia/Shanghai",
+	}}
+
+	// 2 个日历天：2024-01-02 及 2024-01-03 的历史文件保留
+	equals(false, l.expired(time.Date(2024, 1, 2, 0, 0, 1, 0, loc).UTC(), now), t)
+	equals(true, l.expired(time.Date(2024, 1, 1, 23, 59, 59, 0, loc).UTC(), now), t)
+
+	// 按 24 小时计算时，2 天之内的都保留
+	l.CalendarDays = false
+	equals(false, l.expired(time.Date(2024, 1, 1, 23, 59, 59, 0, loc).UTC(), now), t)
+}
+
+func TestCompressedAndUncompressedCaps(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCompressedAndUncompressedCaps", t)
+	defer os.RemoveAll(dir)
+
+	data := []byte("data")
+	var backups []string
+	for i := 5; i >= 1; i-- {
+		name := filepath.Join(dir, "foobar."+fakeTime().Add(-time.Duration(i)*time.Hour).UTC().Format(backupTimeFormat)+".log")
+		isNil(os.WriteFile(name, data, 0o644), t)
+		backups = append(backups, name)
+	}
+
+	l := &file{Config: Config{
+		Filename:             logFile(dir),
+		Compress:             true,
+		MaxCompressedBackups: 2,
+		MaxUncompressedSize:  uint64(2 * len(data)),
+	}}
+	defer l.Close()
+
+	_, err := l.Write(data)
+	isNil(err, t)
+
+	// we need to wait a little bit since the files get compressed on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	// the oldest one is removed, the 2 most recent are kept raw, the others are compressed.
+	notExist(backups[0], t)
+	notExist(backups[0]+compressSuffix, t)
+	exists(backups[1]+compressSuffix, t)
+	exists(backups[2]+compressSuffix, t)
+	existsWithContent(backups[3], data, t)
+	existsWithContent(backups[4], data, t)
+	fileCount(dir, 5, t)
+}
+
+func TestBackupSubdirLayout(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestBackupSubdirLayout", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:           logFile(dir),
+		MaxBackups:         1,
+		UtcTime:            true,
+		BackupSubdirLayout: "2006/01/02",
+	}}
+	defer l.Close()
+
+	b := []byte("boo!")
+	_, err := l.Write(b)
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	// we need to wait a little bit since the files get moved on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	first := fakeTime().UTC()
+	subdir1 := filepath.Join(dir, first.Format("2006/01/02"))
+	existsWithContent(filepath.Join(subdir1, filepath.Base(backupFile(dir))), b, t)
+	notExist(backupFile(dir), t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	// the older backup is removed due to MaxBackups, as well as its empty subdir.
+	exists(filepath.Join(dir, fakeTime().UTC().Format("2006/01/02"), filepath.Base(backupFile(dir))), t)
+	notExist(subdir1, t)
+}
+
+func TestDailyBundle(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDailyBundle", t)
+	defer os.RemoveAll(dir)
+
+	data := []byte("data")
+	yesterday := fakeTime().UTC().Add(-DAY)
+	var backups []string
+	for i := 0; i < 2; i++ {
+		name := filepath.Join(dir, "foobar."+yesterday.Add(time.Duration(i)*time.Millisecond).Format(backupTimeFormat)+".log")
+		isNil(os.WriteFile(name, data, 0o644), t)
+		backups = append(backups, name)
+	}
+
+	l := &file{Config: Config{
+		Filename:    logFile(dir),
+		UtcTime:     true,
+		Compress:    true,
+		DailyBundle: true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write(data)
+	isNil(err, t)
+
+	// we need to wait a little bit since the files get bundled on a different
+	// goroutine.
+	<-time.After(300 * time.Millisecond)
+
+	for _, backup := range backups {
+		notExist(backup, t)
+	}
+	bundle := filepath.Join(dir, "foobar-"+yesterday.Format("20060102")+".tar.gz")
+	f, err := os.Open(bundle)
+	isNil(err, t)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	isNil(err, t)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		isNil(err, t)
+		names = append(names, hdr.Name)
+	}
+	equals([]string{filepath.Base(backups[0]), filepath.Base(backups[1])}, names, t)
+
+	files, err := l.oldLogFiles()
+	isNil(err, t)
+	equals(1, len(files), t)
+	fileCount(dir, 2, t)
+}
+
+func TestUnknownCompressFormat(t *testing.T) {
+	dir := makeTempDir("TestUnknownCompressFormat", t)
+	defer os.RemoveAll(dir)
+
+	// 不支持的压缩格式返回错误，而不是改用 gzip 压缩
+	_, err := Open(WithFilename(logFile(dir)), WithCompressFormat("lz4"))
+	assert(err != nil && strings.Contains(err.Error(), `"lz4"`), t, "expected unknown compress format error, got %v", err)
+	_, err = Recompress(dir, WithCompressFormat("lz4"))
+	notNil(err, t)
+
+	RegisterCompressFormat("lz4", func(level int) Compressor { return NewGzipCompressor(level) })
+	defer func() {
+		formatsMu.Lock()
+		delete(formats, "lz4")
+		formatCompressors = formatCompressors[:len(formatCompressors)-1]
+		formatsMu.Unlock()
+	}()
+	l, err := Open(WithFilename(logFile(dir)), WithCompressFormat("LZ4"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+}
+
+func TestCompressZip(t *testing.T) {
+	currentTime = fakeTime
+
+	dir := makeTempDir("TestCompressZip", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Compress:       true,
+		CompressFormat: "zip",
+		Filename:       filename,
+		MaxSize:        10,
+		UtcTime:        true,
+	}}
+	defer l.Close()
+	b := []byte("boo!\n")
+	_, err := l.Write(b)
+	isNil(err, t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	zipped := backupFile(dir) + ".zip"
+	exists(zipped, t)
+	notExist(backupFile(dir), t)
+	fileCount(dir, 2, t)
+
+	zr, err := zip.OpenReader(zipped)
+	isNil(err, t)
+	defer zr.Close()
+	equals(1, len(zr.File), t)
+	rc, err := zr.File[0].Open()
+	isNil(err, t)
+	content, err := io.ReadAll(rc)
+	rc.Close()
+	isNil(err, t)
+	equals(string(b), string(content), t)
+
+	// 压缩后的历史文件依然可以被识别和读取
+	lines, err := l.TailLines(5)
+	isNil(err, t)
+	equals([]string{"boo!"}, lines, t)
+}
+
+func TestParallelGzipCompressor(t *testing.T) {
+	dir := makeTempDir("TestParallelGzipCompressor", t)
+	defer os.RemoveAll(dir)
+
+	for _, size := range []int{0, 10, 64, 1000} {
+		src := filepath.Join(dir, "src.log")
+		content := bytes.Repeat([]byte("0123456789abcdef"), size)
+		isNil(os.WriteFile(src, content, 0o644), t)
+		dst := filepath.Join(dir, "src.log.gz")
+
+		c := NewParallelGzipCompressor(4, 100)
+		isNil((&file{}).compressLogFile(src, dst, c), t)
+		notExist(src, t)
+
+		f, err := os.Open(dst)
+		isNil(err, t)
+		r, err := c.Decompress(f)
+		isNil(err, t)
+		got, err := io.ReadAll(r)
+		isNil(err, t)
+		r.Close()
+		f.Close()
+		equals(len(content), len(got), t)
+		assert(bytes.Equal(content, got), t, "content mismatch for size %d", size)
+	}
+}
+
+func TestCompressBacklog(t *testing.T) {
+	dir := makeTempDir("TestCompressBacklog", t)
+	defer os.RemoveAll(dir)
+
+	for _, backlog := range []string{CompressBacklogSkip, CompressBacklogDeleteOldest} {
+		l := &file{Config: Config{
+			Compress:        true,
+			CompressWorkers: 1,
+			CompressBacklog: backlog,
+		}}
+		l.setDir(dir)
+		// 不启动工作 goroutine，队列容量为 1，以便队列保持满的状态
+		l.compressOnce.Do(func() {
+			l.compressing = make(map[string]bool)
+			l.compressCh = make(chan string, 1)
+		})
+
+		var files []logInfo
+		for _, name := range []string{"c.log", "b.log", "a.log"} {
+			isNil(os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644), t)
+			files = append(files, logInfo{Name: name})
+		}
+
+		isNil(l.compressFiles(files), t)
+		equals("c.log", <-l.compressCh, t)
+		equals(true, l.isCompressing("c.log"), t)
+
+		if backlog == CompressBacklogSkip {
+			exists(filepath.Join(dir, "b.log"), t)
+			exists(filepath.Join(dir, "a.log"), t)
+		} else {
+			notExist(filepath.Join(dir, "b.log"), t)
+			notExist(filepath.Join(dir, "a.log"), t)
+		}
+		exists(filepath.Join(dir, "c.log"), t)
+		os.Remove(filepath.Join(dir, "c.log"))
+		os.Remove(filepath.Join(dir, "b.log"))
+		os.Remove(filepath.Join(dir, "a.log"))
+	}
+}
+
+func TestCompressWorkers(t *testing.T) {
+	currentTime = fakeTime
+
+	dir := makeTempDir("TestCompressWorkers", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Compress:        true,
+		CompressWorkers: 2,
+		Filename:        filename,
+		MaxSize:         10,
+		UtcTime:         true,
+	}}
+	defer l.Close()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	exists(backupFile(dir)+compressSuffix, t)
+	notExist(backupFile(dir), t)
+	equals(false, l.isCompressing(filepath.Base(backupFile(dir))), t)
+}
+
+func TestRecoverCompressions(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecoverCompressions", t)
+	defer os.RemoveAll(dir)
+
+	data := []byte("data")
+	gzData := func() []byte {
+		bc := new(bytes.Buffer)
+		gz := gzip.NewWriter(bc)
+		_, err := gz.Write(data)
+		isNil(err, t)
+		isNil(gz.Close(), t)
+		return bc.Bytes()
+	}()
+
+	// 压缩已完成，但源文件尚未删除
+	done := backupFile(dir)
+	isNil(os.WriteFile(done, data, 0o644), t)
+	isNil(os.WriteFile(done+compressSuffix, gzData, 0o644), t)
+
+	// 压缩中断，压缩文件不完整
+	newFakeTime()
+	broken := backupFile(dir)
+	isNil(os.WriteFile(broken, data, 0o644), t)
+	isNil(os.WriteFile(broken+compressSuffix, gzData[:len(gzData)/2], 0o644), t)
+
+	// 残留的压缩临时文件
+	stale := broken + compressSuffix + compressTmpSuffix
+	isNil(os.WriteFile(stale, gzData[:1], 0o644), t)
+
+	newFakeTime()
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		Compress: true,
+		UtcTime:  true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write(data)
+	isNil(err, t)
+
+	<-time.After(300 * time.Millisecond)
+
+	notExist(stale, t)
+	notExist(done, t)
+	existsWithContent(done+compressSuffix, gzData, t)
+	notExist(broken, t)
+	existsWithContent(broken+compressSuffix, gzData, t)
+	fileCount(dir, 3, t)
+}
+
+// brokenCompressor 输出无法解压的内容
+type brokenCompressor struct{ gzipCompressor }
+
+func (brokenCompressor) Compress(dst io.Writer, src *os.File) error {
+	_, err := dst.Write([]byte("not gzip"))
+	return err
+}
+
+func TestCompressVerify(t *testing.T) {
+	dir := makeTempDir("TestCompressVerify", t)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src.log")
+	isNil(os.WriteFile(src, []byte("data"), 0o644), t)
+	dst := src + compressSuffix
+
+	notNil((&file{}).compressLogFile(src, dst, brokenCompressor{}), t)
+	existsWithContent(src, []byte("data"), t)
+	notExist(dst, t)
+	notExist(dst+compressTmpSuffix, t)
+
+	isNil((&file{}).compressLogFile(src, dst, GzipCompressor), t)
+	notExist(src, t)
+	exists(dst, t)
+	notExist(dst+compressTmpSuffix, t)
+}
+
+func TestCompressKeepSource(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCompressKeepSource", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Compress:           true,
+		CompressKeepSource: true,
+		Filename:           filename,
+		MaxBackups:         1,
+		UtcTime:            true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!\n"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	first := backupFile(dir)
+	existsWithContent(first, []byte("boo!\n"), t)
+	exists(first+compressSuffix, t)
+	fileCount(dir, 3, t)
+
+	// 同时存在源文件与压缩文件时，只读取一次
+	lines, err := l.TailLines(10)
+	isNil(err, t)
+	equals([]string{"boo!"}, lines, t)
+
+	// MaxBackups 将源文件与压缩文件视为同一个历史文件
+	_, err = l.Write([]byte("foo!\n"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	notExist(first, t)
+	notExist(first+compressSuffix, t)
+	existsWithContent(backupFile(dir), []byte("foo!\n"), t)
+	exists(backupFile(dir)+compressSuffix, t)
+	fileCount(dir, 3, t)
+}
+
+func TestCompressOnClose(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCompressOnClose", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		CompressOnClose: true,
+		Filename:        filename,
+		UtcTime:         true,
+	}}
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+
+	notExist(filename, t)
+	notExist(backupFile(dir), t)
+	exists(backupFile(dir)+compressSuffix, t)
+	fileCount(dir, 1, t)
+
+	// 再次关闭，没有需要压缩的文件
+	isNil(l.Close(), t)
+	fileCount(dir, 1, t)
+}
+
+func TestReadOnlyBackups(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestReadOnlyBackups", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:        filename,
+		MaxBackups:      1,
+		ReadOnlyBackups: true,
+		UtcTime:         true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	first := backupFile(dir)
+	info, err := os.Stat(first)
+	isNil(err, t)
+	equals(os.FileMode(readOnlyMode), info.Mode().Perm(), t)
+
+	// 只读的历史文件依然可以被清理
+	l.Compress = true
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	notExist(first, t)
+	info, err = os.Stat(backupFile(dir) + compressSuffix)
+	isNil(err, t)
+	equals(os.FileMode(readOnlyMode), info.Mode().Perm(), t)
+	fileCount(dir, 2, t)
+}
+
+func TestRotateTrigger(t *testing.T) {
+	currentTime = fakeTime
+	rotateTriggerInterval = 10 * time.Millisecond
+	defer func() { rotateTriggerInterval = time.Second }()
+
+	dir := makeTempDir("TestRotateTrigger", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:      filename,
+		RotateTrigger: "foobar.log.rotate",
+		UtcTime:       true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	trigger := filepath.Join(dir, "foobar.log.rotate")
+	isNil(os.WriteFile(trigger, nil, 0o644), t)
+	<-time.After(300 * time.Millisecond)
+
+	notExist(trigger, t)
+	existsWithContent(backupFile(dir), []byte("boo!"), t)
+	existsWithContent(filename, []byte{}, t)
+
+	// 关闭后不再检查，也不会重新打开日志文件，再次写入后恢复检查
+	isNil(l.Close(), t)
+	isNil(os.WriteFile(trigger, nil, 0o644), t)
+	<-time.After(100 * time.Millisecond)
+	exists(trigger, t)
+	assert(l.file == nil, t, "expected log file closed")
+
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	<-time.After(300 * time.Millisecond)
+	notExist(trigger, t)
+}
+
+func TestCtlSocket(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCtlSocket", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:  filename,
+		CtlSocket: "ctl.sock",
+		UtcTime:   true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(dir, "ctl.sock"))
+		isNil(err, t)
+		equals(os.FileMode(0o600), info.Mode().Perm(), t)
+	}
+
+	conn, err := net.Dial("unix", filepath.Join(dir, "ctl.sock"))
+	isNil(err, t)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	call := func(cmd string) string {
+		_, err := fmt.Fprintln(conn, cmd)
+		isNil(err, t)
+		reply, err := r.ReadString('\n')
+		isNil(err, t)
+		return strings.TrimSuffix(reply, "\n")
+	}
+
+	newFakeTime()
+	equals("ok", call("rotate"), t)
+	existsWithContent(backupFile(dir), []byte("boo!"), t)
+	equals("ok", call("flush"), t)
+	equals("error unknown command foo", call("foo"), t)
+
+	var s Stats
+	reply := call("stats")
+	isNil(json.Unmarshal([]byte(strings.TrimPrefix(reply, "ok ")), &s), t)
+	equals(int64(1), s.Rotations, t)
+	equals(1, s.Backups, t)
+	equals(int64(4), s.BackupsSize, t)
+
+	// 临时目录已删除，关闭后删除 socket 文件
+	tmps, err := filepath.Glob(filepath.Join(dir, ".ctl-*"))
+	isNil(err, t)
+	equals(0, len(tmps), t)
+	isNil(l.Close(), t)
+	notExist(filepath.Join(dir, "ctl.sock"), t)
+}
+
+func TestAdminHandler(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestAdminHandler", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		UtcTime:  true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	h := AdminHandler(l)
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	equals(http.StatusMethodNotAllowed, serve(http.MethodGet, "/rotate").Code, t)
+	newFakeTime()
+	equals(http.StatusNoContent, serve(http.MethodPost, "/rotate").Code, t)
+	existsWithContent(backupFile(dir), []byte("boo!"), t)
+	equals(http.StatusNoContent, serve(http.MethodPost, "/flush").Code, t)
+
+	w := serve(http.MethodGet, "/stats")
+	equals(http.StatusOK, w.Code, t)
+	var s Stats
+	isNil(json.Unmarshal(w.Body.Bytes(), &s), t)
+	equals(int64(1), s.Rotations, t)
+
+	w = serve(http.MethodGet, "/backups")
+	equals(http.StatusOK, w.Code, t)
+	var backups []BackupInfo
+	isNil(json.Unmarshal(w.Body.Bytes(), &backups), t)
+	equals(1, len(backups), t)
+	equals(filepath.Base(backupFile(dir)), backups[0].Name, t)
+	equals(int64(4), backups[0].Size, t)
+}
+
+func BenchmarkWriteAfterClose(b *testing.B) {
+	// stat 模拟之前打开已有日志文件前先 stat 的方式，作为对比的基准
+	for _, stat := range []bool{false, true} {
+		b.Run(fmt.Sprintf("stat=%v", stat), func(b *testing.B) {
+			dir := makeTempDir("BenchmarkWriteAfterClose", b)
+			defer os.RemoveAll(dir)
+
+			filename := logFile(dir)
+			l := &file{Config: Config{
+				Filename: filename,
+				MaxSize:  GB,
+			}}
+			defer l.Close()
+
+			p := []byte("boo!\n")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if stat {
+					if _, err := osStat(filename); err != nil && !os.IsNotExist(err) {
+						b.Fatal(err)
+					}
+				}
+				if _, err := l.Write(p); err != nil {
+					b.Fatal(err)
+				}
+				if err := l.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAsyncWrite(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestAsyncWrite", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:       filename,
+		AsyncWrite:     true,
+		AsyncQueueSize: 8,
+	}}
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := []byte("boo!\n")
+			for j := 0; j < 100; j++ {
+				n, err := l.Write(p)
+				isNil(err, t)
+				equals(len(p), n, t)
+			}
+		}()
+	}
+	wg.Wait()
+	isNil(l.Flush(), t)
+
+	existsWithContent(filename, bytes.Repeat([]byte("boo!\n"), 1000), t)
+
+	// 关闭时写入剩余内容并停止写入协程，再次写入时重新启动
+	_, err := l.Write([]byte("foo!\n"))
+	isNil(err, t)
+	isNil(l.Close(), t)
+	assert(l.asyncQ.Load() == nil, t, "expected async writer stopped")
+	existsWithContent(filename, append(bytes.Repeat([]byte("boo!\n"), 1000), "foo!\n"...), t)
+	_, err = l.Write([]byte("bar!\n"))
+	isNil(err, t)
+	isNil(l.Flush(), t)
+	existsWithContent(filename, append(bytes.Repeat([]byte("boo!\n"), 1000), "foo!\nbar!\n"...), t)
+}
+
+func BenchmarkWriteParallel(b *testing.B) {
+	for _, async := range []bool{false, true} {
+		b.Run(fmt.Sprintf("async=%v", async), func(b *testing.B) {
+			dir := makeTempDir("BenchmarkWriteParallel", b)
+			defer os.RemoveAll(dir)
+
+			l := &file{Config: Config{
+				Filename:   logFile(dir),
+				MaxSize:    GB,
+				AsyncWrite: async,
+			}}
+			defer l.Close()
+
+			p := []byte("boo!\n")
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					l.Write(p)
+				}
+			})
+			l.Flush()
+		})
+	}
+}
+
+// tickReader 每次读取前推进假时间，以便每次滚动生成不同的历史文件名
+type tickReader struct{ r io.Reader }
+
+func (r tickReader) Read(p []byte) (int, error) {
+	newFakeTime()
+	return r.r.Read(p)
+}
+
+func TestReadFrom(t *testing.T) {
+	currentTime = fakeTime
+
+	dir := makeTempDir("TestReadFrom", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename: filename,
+		MaxSize:  10,
+	}}
+	defer l.Close()
+
+	// 超过 MaxSize 的内容，按块滚动写入
+	n, err := l.ReadFrom(tickReader{strings.NewReader("0123456789abcdefghijklmno")})
+	isNil(err, t)
+	equals(int64(25), n, t)
+	existsWithContent(filename, []byte("klmno"), t)
+	fileCount(dir, 3, t)
+}
+
+func TestWriteV(t *testing.T) {
+	currentTime = fakeTime
+
+	dir := makeTempDir("TestWriteV", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename: filename,
+		MaxSize:  10,
+		UtcTime:  true,
+	}}
+	defer l.Close()
+
+	n, err := l.WriteV([][]byte{[]byte("boo!"), []byte("foo!")})
+	isNil(err, t)
+	equals(8, n, t)
+	existsWithContent(filename, []byte("boo!foo!"), t)
+
+	// 缓冲不会被拆分，在缓冲之间滚动
+	newFakeTime()
+	n, err = l.WriteV([][]byte{[]byte("bar!")})
+	isNil(err, t)
+	equals(4, n, t)
+	existsWithContent(backupFile(dir), []byte("boo!foo!"), t)
+	existsWithContent(filename, []byte("bar!"), t)
+}
+
+func TestDropPageCache(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDropPageCache", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:      filename,
+		Compress:      true,
+		DropPageCache: true,
+		UtcTime:       true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	<-time.After(300 * time.Millisecond)
+
+	exists(backupFile(dir)+compressSuffix, t)
+	notExist(backupFile(dir), t)
+	existsWithContent(filename, []byte{}, t)
+}
+
+func TestSyncPolicy(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestSyncPolicy", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:   filename,
+		SyncPolicy: SyncLevel,
+		SyncFilter: func(p []byte) bool { return bytes.HasPrefix(p, []byte("E!")) },
+	}}
+	defer l.Close()
+
+	equals(false, l.syncAfterWrite([]byte("boo!")), t)
+	equals(true, l.syncAfterWrite([]byte("E! boo!")), t)
+
+	_, err := l.Write([]byte("E! boo!"))
+	isNil(err, t)
+	existsWithContent(filename, []byte("E! boo!"), t)
+
+	l.SyncPolicy = SyncEveryWrite
+	equals(true, l.syncAfterWrite([]byte("boo!")), t)
+	equals(0, l.syncFlag(), t)
+
+	// dsync 模式打开的文件，照常写入
+	isNil(l.Close(), t)
+	l.SyncPolicy = SyncDsync
+	equals(dsyncFlag, l.syncFlag(), t)
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	existsWithContent(filename, []byte("E! boo!foo!"), t)
+}
+
+func TestSyncInterval(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestSyncInterval", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:     logFile(dir),
+		SyncPolicy:   SyncInterval,
+		SyncInterval: 10 * time.Millisecond,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	equals(true, l.dirty.Load(), t)
+
+	<-time.After(100 * time.Millisecond)
+	equals(false, l.dirty.Load(), t)
+
+	// 关闭后不再刷盘，也不会重新打开日志文件
+	isNil(l.Close(), t)
+	l.dirty.Store(true)
+	<-time.After(100 * time.Millisecond)
+	equals(true, l.dirty.Load(), t)
+	assert(l.file == nil, t, "expected log file closed")
+}
+
+func TestWriteTimeout(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestWriteTimeout", t)
+	defer os.RemoveAll(dir)
+	fallback := filepath.Join(dir, "fallback")
+	isNil(os.MkdirAll(fallback, 0o755), t)
+
+	l := &file{Config: Config{
+		Filename:     logFile(dir),
+		WriteTimeout: 50 * time.Millisecond,
+		FallbackDir:  fallback,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	exists(logFile(dir)+".lock", t)
+
+	// 模拟挂起的文件系统：写入一个没有读取方的管道，管道缓冲满后写入阻塞
+	r, w, err := os.Pipe()
+	isNil(err, t)
+	defer r.Close()
+	l.mu.Lock()
+	l.file.Close()
+	l.file = w
+	l.mu.Unlock()
+
+	_, err = l.Write(bytes.Repeat([]byte("x"), 1024*1024))
+	equals(ErrWriteTimeout, err, t)
+
+	// 后续写入切换到备用目录
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	existsWithContent(filepath.Join(fallback, "foobar.log"), []byte("foo!"), t)
+	existsWithContent(logFile(dir), []byte("boo!"), t)
+
+	// 挂起的写入返回后，关闭挂起的文件，释放其文件名锁，后续写入切换回主目录
+	go io.Copy(io.Discard, r)
+	for deadline := time.Now().Add(time.Second); !l.primaryBack.Load() && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	notExist(logFile(dir)+".lock", t)
+	_, err = l.Write([]byte("bar!"))
+	isNil(err, t)
+	existsWithContent(logFile(dir), []byte("boo!bar!"), t)
+	existsWithContent(filepath.Join(fallback, "foobar.log"), []byte("foo!"), t)
+	notExist(filepath.Join(fallback, "foobar.log.lock"), t)
+}
+
+func TestNonBlocking(t *testing.T) {
+	currentTime = fakeTime
+	dropSummaryInterval = 50 * time.Millisecond
+	defer func() { dropSummaryInterval = time.Minute }()
+
+	dir := makeTempDir("TestNonBlocking", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:    filename,
+		NonBlocking: true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!\n"))
+	isNil(err, t)
+
+	// 其它写入正常进行时，等待而不丢弃
+	l.mu.Lock()
+	time.AfterFunc(10*time.Millisecond, l.mu.Unlock)
+	_, err = l.Write([]byte("foo!\n"))
+	isNil(err, t)
+	equals(int64(0), l.Stats().Dropped, t)
+
+	// 磁盘繁忙，正在进行的写入耗时过长时，丢弃
+	writeStallThreshold = 20 * time.Millisecond
+	defer func() { writeStallThreshold = 50 * time.Millisecond }()
+	fileWrite = func(f *os.File, p []byte) (int, error) {
+		time.Sleep(100 * time.Millisecond)
+		return f.Write(p)
+	}
+	slow := make(chan error, 1)
+	go func() {
+		_, err := l.Write([]byte("slow\n"))
+		slow <- err
+	}()
+	<-time.After(50 * time.Millisecond)
+	n, err := l.Write([]byte("bar!\n"))
+	equals(ErrWouldBlock, err, t)
+	equals(0, n, t)
+	isNil(<-slow, t)
+	fileWrite = (*os.File).Write
+	equals(int64(1), l.Stats().Dropped, t)
+
+	<-time.After(200 * time.Millisecond)
+	existsWithContent(filename, []byte("boo!\nfoo!\nslow\nrotatefile: dropped 1 writes in the last 50ms\n"), t)
+
+	// 关闭后不再汇总，也不会重新打开日志文件
+	l.dropped.Add(1)
+	isNil(l.Close(), t)
+	<-time.After(200 * time.Millisecond)
+	assert(l.file == nil, t, "expected log file closed")
+}
+
+func TestAsyncWriteCloseRace(t *testing.T) {
+	dir := makeTempDir("TestAsyncWriteCloseRace", t)
+	defer os.RemoveAll(dir)
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:     filename,
+		AsyncWrite:   true,
+		PrintTerm:    true,
+		TermWriter:   io.Discard,
+		SyncInterval: time.Millisecond,
+	}}
+
+	// 写入、刷新与关闭同时进行，写入不丢失，也不会永久阻塞
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				_, err := l.Write([]byte("boo!\n"))
+				isNil(err, t)
+				if j%50 == 0 {
+					isNil(l.Flush(), t)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		isNil(l.Close(), t)
+	}
+	wg.Wait()
+	isNil(l.Close(), t)
+
+	assert(l.asyncQ.Load() == nil, t, "expected async writer stopped")
+	assert(l.termQ.Load() == nil, t, "expected terminal writer stopped")
+	existsWithContent(filename, bytes.Repeat([]byte("boo!\n"), 800), t)
+}
+
+func TestNonBlockingAsync(t *testing.T) {
+	dir := makeTempDir("TestNonBlockingAsync", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:    logFile(dir),
+		NonBlocking: true,
+		AsyncWrite:  true,
+	}}
+	// 不启动写入协程，队列容量为 1，以便队列保持满的状态
+	l.asyncQ.Store(&asyncQueue{ch: make(chan writeMsg, 1)})
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	_, err = l.Write([]byte("foo!"))
+	equals(ErrWouldBlock, err, t)
+	equals(int64(1), l.Dropped(), t)
+}
+
+func TestFailover(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestFailover", t)
+	defer os.RemoveAll(dir)
+
+	secondary := &file{Config: Config{Filename: filepath.Join(dir, "secondary.log")}}
+	defer secondary.Close()
+
+	l := &file{Config: Config{
+		Filename:      logFile(dir),
+		MaxSize:       10,
+		Failover:      secondary,
+		FailoverAfter: 2,
+		FailoverProbe: time.Hour,
+	}}
+	defer l.Close()
+
+	// 超过 MaxSize 的写入会失败，用于模拟主目标写入失败
+	_, err := l.Write([]byte("first write"))
+	notNil(err, t)
+	n, err := l.Write([]byte("second write"))
+	isNil(err, t)
+	equals(12, n, t)
+
+	// 切换期间，未到探测时间时直接写入备用目标
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	existsWithContent(filepath.Join(dir, "secondary.log"), []byte("second writeboo!"), t)
+	notExist(logFile(dir), t)
+
+	// 到达探测时间，主目标写入成功后切换回来
+	newFakeTime()
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	existsWithContent(logFile(dir), []byte("foo!"), t)
+	_, err = l.Write([]byte("bar"))
+	isNil(err, t)
+	existsWithContent(logFile(dir), []byte("foo!bar"), t)
+}
+
+func TestWriteRetry(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestWriteRetry", t)
+	defer os.RemoveAll(dir)
+
+	failures := 2
+	fileWrite = func(f *os.File, p []byte) (int, error) {
+		if failures > 0 {
+			failures--
+			return 0, &os.PathError{Op: "write", Path: f.Name(), Err: syscall.EAGAIN}
+		}
+		return f.Write(p)
+	}
+	defer func() { fileWrite = (*os.File).Write }()
+
+	var hookErr error
+	l := &file{Config: Config{
+		Filename:          logFile(dir),
+		WriteRetries:      2,
+		WriteRetryBackoff: time.Millisecond,
+		OnWriteError:      func(err error) { hookErr = err },
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	isNil(hookErr, t)
+	existsWithContent(logFile(dir), []byte("boo!"), t)
+
+	// 超过重试次数后返回错误，并回调 OnWriteError
+	failures = 3
+	_, err = l.Write([]byte("foo!"))
+	assert(errors.Is(err, syscall.EAGAIN), t, "expected EAGAIN, got %v", err)
+	equals(err, hookErr, t)
+	existsWithContent(logFile(dir), []byte("boo!"), t)
+}
+
+func TestWriteRetryNoSpace(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestWriteRetryNoSpace", t)
+	defer os.RemoveAll(dir)
+
+	var backups []string
+	for i := 1; i <= 3; i++ {
+		name := filepath.Join(dir, "foobar."+fakeTime().UTC().Add(-time.Duration(i)*time.Hour).Format(backupTimeFormat)+".log")
+		isNil(os.WriteFile(name, []byte("0123456789"), 0o644), t)
+		backups = append(backups, name)
+	}
+
+	var mu sync.Mutex
+	failures := 1
+	fileWrite = func(f *os.File, p []byte) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			return 0, &os.PathError{Op: "write", Path: f.Name(), Err: syscall.ENOSPC}
+		}
+		return f.Write(p)
+	}
+	defer func() { fileWrite = (*os.File).Write }()
+
+	m := NewManager(dir, WithTotalSizeCap(15), WithUtcTime(true), WithCompress(false))
+	defer m.Close()
+	l := m.Open("foobar.log", func(c *Config) {
+		c.WriteRetries = 1
+		c.WriteRetryBackoff = time.Minute
+	})
+
+	// 磁盘空间不足时，等待 Manager 共享的清理协程清理完成后重试，而不是等待整个退避时间
+	start := time.Now()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	assert(time.Since(start) < 10*time.Second, t, "expected retry after cleanup, waited %s", time.Since(start))
+	existsWithContent(logFile(dir), []byte("boo!"), t)
+	exists(backups[0], t)
+	notExist(backups[1], t)
+	notExist(backups[2], t)
+}
+
+func TestFlushAllCloseAll(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestFlushAllCloseAll", t)
+	defer os.RemoveAll(dir)
+
+	l1 := &file{Config: Config{Filename: filepath.Join(dir, "one.log"), AsyncWrite: true}}
+	l2 := &file{Config: Config{Filename: filepath.Join(dir, "two.log")}}
+
+	_, err := l1.Write([]byte("boo!"))
+	isNil(err, t)
+	_, err = l2.Write([]byte("foo!"))
+	isNil(err, t)
+
+	isNil(FlushAll(), t)
+	existsWithContent(filepath.Join(dir, "one.log"), []byte("boo!"), t)
+	existsWithContent(filepath.Join(dir, "two.log"), []byte("foo!"), t)
+
+	isNil(CloseAll(), t)
+	assert(l1.file == nil && l2.file == nil, t, "expected all files closed")
+	equals(0, len(registered()), t)
+}
+
+func TestManagerTotalSizeCap(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestManagerTotalSizeCap", t)
+	defer os.RemoveAll(dir)
+
+	m := NewManager(dir, WithTotalSizeCap(8), WithUtcTime(true), WithCompress(false))
+	defer m.Close()
+
+	access := m.Open("access.log")
+	equals(access, m.Open("access.log"), t)
+	errorLog := m.Open("error.log")
+
+	_, err := access.Write([]byte("aaaa"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(access.Rotate(), t)
+
+	_, err = errorLog.Write([]byte("eeee"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(errorLog.Rotate(), t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+
+	accessBackups, _ := filepath.Glob(filepath.Join(dir, "access.*.log"))
+	errorBackups, _ := filepath.Glob(filepath.Join(dir, "error.*.log"))
+	equals(1, len(accessBackups), t)
+	equals(1, len(errorBackups), t)
+
+	// 超过目录总大小后，删除所有日志流中最老的历史文件
+	_, err = access.Write([]byte("bbbb"))
+	isNil(err, t)
+	isNil(access.Rotate(), t)
+	<-time.After(10 * time.Millisecond)
+
+	notExist(accessBackups[0], t)
+	exists(errorBackups[0], t)
+	isNil(m.Flush(), t)
+}
+
+func TestManagerUnwrittenStream(t *testing.T) {
+	dir := makeTempDir("TestManagerUnwrittenStream", t)
+	defer os.RemoveAll(dir)
+
+	var mu sync.Mutex
+	var errs []error
+	var ticks atomic.Int64
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := ClockFunc(func() time.Time { return start.Add(time.Duration(ticks.Add(1)) * time.Second) })
+	m := NewManager(dir, WithMaxBackups(1), WithTotalSizeCap(100), WithClock(clock), WithCompress(false),
+		WithOnError(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}))
+	defer m.Close()
+
+	access := m.Open("access.log")
+	audit := m.Open("audit.log")
+	_ = m.Open("error.log") // 从未写入
+
+	// audit.log 首次写入时生成文件名，与清理协程读取其它日志流同时进行
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := audit.Write([]byte("audit"))
+		isNil(err, t)
+	}()
+	for i := 0; i < 5; i++ {
+		_, err := access.Write([]byte("access"))
+		isNil(err, t)
+		isNil(access.Rotate(), t)
+	}
+	<-done
+	isNil(audit.Rotate(), t)
+	<-time.After(10 * time.Millisecond)
+	isNil(m.Flush(), t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	equals(0, len(errs), t)
+}
+
+func TestTotalSizeCapDir(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestTotalSizeCapDir", t)
+	defer os.RemoveAll(dir)
+
+	ts := func(d time.Duration) string { return fakeTime().UTC().Add(-d).Format(backupTimeFormat) }
+	oldest := filepath.Join(dir, "other."+ts(3*time.Hour)+".log.gz")
+	older := filepath.Join(dir, "other."+ts(2*time.Hour)+".log")
+	own := filepath.Join(dir, "foobar."+ts(time.Hour)+".log")
+	unmanaged := filepath.Join(dir, "notes.txt")
+	for _, name := range []string{oldest, older, own, unmanaged} {
+		isNil(os.WriteFile(name, []byte("0123456789"), 0o644), t)
+	}
+
+	l := &file{Config: Config{
+		Filename:        logFile(dir),
+		TotalSizeCap:    25,
+		TotalSizeCapDir: true,
+	}}
+	defer l.Close()
+	l.setFileName()
+
+	isNil(l.keepTotalSizeCap(dir), t)
+	notExist(oldest, t)
+	exists(older, t)
+	exists(own, t)
+	exists(unmanaged, t)
+}
+
+func TestChild(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestChild", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:     logFile(dir),
+		MaxBackups:   10,
+		MaxSize:      100,
+		UtcTime:      true,
+		TotalSizeCap: 8,
+	}}
+	defer l.Close()
+
+	slow := l.Child("slow")
+	defer slow.Close()
+	equals(slow, l.Child("slow"), t)
+	equals(filepath.Join(dir, "foobar_slow.log"), slow.(*file).Filename, t)
+
+	_, err := l.Write([]byte("aaaa"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	parentBackup := backupFile(dir)
+
+	_, err = slow.Write([]byte("ssss"))
+	isNil(err, t)
+	existsWithContent(filepath.Join(dir, "foobar_slow.log"), []byte("ssss"), t)
+	newFakeTime()
+	isNil(slow.Rotate(), t)
+
+	// we need to wait a little bit since the files get deleted on a different
+	// goroutine.
+	<-time.After(10 * time.Millisecond)
+	exists(parentBackup, t)
+
+	// 子日志文件的历史文件计入父日志文件的总大小，超过后删除最老的历史文件
+	_, err = slow.Write([]byte("tttt"))
+	isNil(err, t)
+	<-time.After(10 * time.Millisecond)
+	newFakeTime()
+	isNil(slow.Rotate(), t)
+	<-time.After(10 * time.Millisecond)
+	notExist(parentBackup, t)
+	fileCount(dir, 4, t)
+}
+
+func TestOnBackup(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestOnBackup", t)
+	defer os.RemoveAll(dir)
+
+	backups := make(chan string, 1)
+	release := make(chan struct{})
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		MaxSize:  10,
+		UtcTime:  true,
+		Compress: false,
+		OnBackup: func(path string) {
+			backups <- path
+			<-release
+		},
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	select {
+	case path := <-backups:
+		equals(backupFile(dir), path, t)
+	case <-time.After(time.Second):
+		t.Fatal("OnBackup not called")
+	}
+
+	// 回调阻塞时不影响写入及滚动
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	isNil(l.Rotate(), t)
+	close(release)
+}
+
+func TestClean(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestClean", t)
+	defer os.RemoveAll(dir)
+
+	ts := func(d time.Duration) string { return fakeTime().UTC().Add(-d).Format(backupTimeFormat) }
+	expired := filepath.Join(dir, "app."+ts(10*DAY)+".log")
+	oldest := filepath.Join(dir, "other."+ts(3*DAY)+".log.gz")
+	recent := filepath.Join(dir, "app."+ts(time.Hour)+".log")
+	unmanaged := filepath.Join(dir, "notes.txt")
+	for _, name := range []string{expired, oldest, recent, unmanaged} {
+		isNil(os.WriteFile(name, []byte("0123456789"), 0o644), t)
+	}
+
+	fns := []ConfigFn{WithMaxDays(7), WithTotalSizeCap(15), WithMinDiskFree(0), WithCompress(false), WithUtcTime(true)}
+	removed, err := Clean(dir, true, fns...)
+	isNil(err, t)
+	equals([]string{expired, oldest}, removed, t)
+	exists(expired, t)
+	exists(oldest, t)
+
+	removed, err = Clean(dir, false, fns...)
+	isNil(err, t)
+	equals([]string{expired, oldest}, removed, t)
+	notExist(expired, t)
+	notExist(oldest, t)
+	exists(recent, t)
+	exists(unmanaged, t)
+}
+
+func TestCat(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestCat", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Compress: true,
+		Filename: logFile(dir),
+		UtcTime:  true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("foo 1\n"))
+	isNil(err, t)
+	newFakeTime()
+	rotated := fakeCurrentTime
+	isNil(l.Rotate(), t)
+
+	<-time.After(300 * time.Millisecond)
+	exists(backupFile(dir)+compressSuffix, t)
+
+	_, err = l.Write([]byte("bar 2"))
+	isNil(err, t)
+
+	cat := func(since, until time.Time) string {
+		r, err := Cat(logFile(dir), since, until, WithUtcTime(true))
+		isNilUp(err, t, 1)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		isNilUp(err, t, 1)
+		return string(b)
+	}
+
+	equals("foo 1\nbar 2\n", cat(time.Time{}, time.Time{}), t)
+	equals("bar 2\n", cat(rotated.Add(time.Second), time.Time{}), t)
+	equals("foo 1\n", cat(time.Time{}, rotated.Add(-time.Second)), t)
+}
+
+func TestRecompress(t *testing.T) {
+	dir := makeTempDir("TestRecompress", t)
+	defer os.RemoveAll(dir)
+
+	gz := filepath.Join(dir, "app.20240101T000000.000.log")
+	plain := filepath.Join(dir, "app.20240102T000000.000.log")
+	active := filepath.Join(dir, "app.log")
+	for _, name := range []string{gz, plain, active} {
+		isNil(os.WriteFile(name, []byte(filepath.Base(name)), 0o644), t)
+	}
+	l := &file{}
+	isNil(l.compressLogFile(gz, gz+compressSuffix, NewGzipCompressor(1)), t)
+
+	compressed, err := Recompress(dir, WithCompressFormat("zip"), WithCompressLevel(9))
+	isNil(err, t)
+	equals([]string{gz + ".zip", plain + ".zip"}, compressed, t)
+	notExist(gz+compressSuffix, t)
+	notExist(plain, t)
+	existsWithContent(active, []byte("app.log"), t)
+
+	for _, name := range []string{gz, plain} {
+		r, err := l.openBackup(name + ".zip")
+		isNil(err, t)
+		b, err := io.ReadAll(r)
+		isNil(err, t)
+		isNil(r.Close(), t)
+		equals(filepath.Base(name), string(b), t)
+	}
+
+	// 已是目标格式时跳过
+	compressed, err = Recompress(dir, WithCompressFormat("zip"))
+	isNil(err, t)
+	equals(0, len(compressed), t)
+
+	// RecompressForce 按新的压缩级别重新压缩
+	compressed, err = RecompressForce(dir, WithCompressFormat("zip"), WithCompressLevel(1))
+	isNil(err, t)
+	equals([]string{gz + ".zip", plain + ".zip"}, compressed, t)
+	for _, name := range []string{gz, plain} {
+		r, err := l.openBackup(name + ".zip")
+		isNil(err, t)
+		b, err := io.ReadAll(r)
+		isNil(err, t)
+		isNil(r.Close(), t)
+		equals(filepath.Base(name), string(b), t)
+		notExist(name+compressTmpSuffix, t)
+	}
+}
+
+func TestInspectDir(t *testing.T) {
+	dir := makeTempDir("TestInspectDir", t)
+	defer os.RemoveAll(dir)
+
+	old := filepath.Join(dir, "app.20240101T000000.000.log")
+	newest := filepath.Join(dir, "app.20240102T000000.000.log")
+	for _, name := range []string{old, newest, filepath.Join(dir, "app.log"), filepath.Join(dir, "other.20240103T000000.000.log")} {
+		isNil(os.WriteFile(name, bytes.Repeat([]byte("a"), 100), 0o644), t)
+	}
+	l := &file{}
+	isNil(l.compressLogFile(old, old+compressSuffix, GzipCompressor), t)
+
+	stats, err := InspectDir(dir, true)
+	isNil(err, t)
+	equals(2, len(stats.Logs), t)
+	app := stats.Logs[0]
+	equals("app.log", app.Name, t)
+	equals(int64(100), app.Size, t)
+	equals(2, app.Backups, t)
+	equals(1, app.Compressed, t)
+	equals(int64(100), app.OriginalSize, t)
+	assert(app.Ratio() > 0 && app.Ratio() < 1, t, "unexpected ratio %v", app.Ratio())
+	equals(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), app.Oldest.UTC(), t)
+	equals("other.log", stats.Logs[1].Name, t)
+	equals(int64(0), stats.Logs[1].Size, t)
+	assert(stats.DiskTotal > 0, t, "expected disk total")
+
+	equals("83 MB", Bytes(82854982), t)
+	equals("9 B", Bytes(9), t)
+}
+
+func TestVerify(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestVerify", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		UtcTime:  true,
+		Manifest: true,
+		Compress: true,
+	}}
+	defer l.Close()
+
+	for i := 0; i < 2; i++ {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		<-time.After(300 * time.Millisecond)
+	}
+	isNil(l.writeManifest(), t)
+
+	problems, err := Verify(dir)
+	isNil(err, t)
+	equals(0, len(problems), t)
+
+	backup := backupFile(dir) + compressSuffix
+	sum, err := fileSHA256(backup)
+	isNil(err, t)
+	isNil(os.WriteFile(backup+checksumSuffix, []byte(sum+"  "+filepath.Base(backup)+"\n"), 0o644), t)
+	problems, err = Verify(dir)
+	isNil(err, t)
+	equals(0, len(problems), t)
+
+	// 损坏压缩文件，同时清单与校验和文件不再匹配，并放置无法解析时间戳的历史文件
+	isNil(os.WriteFile(backup, []byte("not gzip"), 0o644), t)
+	isNil(os.WriteFile(filepath.Join(dir, "foobar.2024-01-01.log"), []byte("x"), 0o644), t)
+	isNil(os.WriteFile(filepath.Join(dir, "foobar.20240101T000000.000.log"), []byte("x"), 0o644), t)
+	problems, err = Verify(dir)
+	isNil(err, t)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	equals(5, len(got), t)
+	want := []string{
+		filepath.Join(dir, "foobar.2024-01-01.log") + ": invalid backup timestamp",
+		backup + ": corrupt compressed file",
+		backup + checksumSuffix + ": checksum mismatch",
+		filepath.Join(dir, "foobar.manifest.json") + ": " + filepath.Base(backup) + ": size mismatch",
+		filepath.Join(dir, "foobar.manifest.json") + ": foobar.20240101T000000.000.log: not listed in manifest",
+	}
+	for i, w := range want {
+		assert(strings.HasPrefix(got[i], w), t, "problem %d: got %q, want prefix %q", i, got[i], w)
+	}
+}
+
+func TestClock(t *testing.T) {
+	dir := makeTempDir("TestClock", t)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2024, 1, 1, 23, 59, 0, 0, time.UTC)
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithClock(ClockFunc(func() time.Time { return now })),
+	)
+	defer l.Close()
+
+	_, err := l.Write([]byte("day 1\n"))
+	isNil(err, t)
+
+	// 跨天后写入，按天滚动，历史文件名使用时钟的时间
+	now = now.Add(2 * time.Minute)
+	_, err = l.Write([]byte("day 2\n"))
+	isNil(err, t)
+	existsWithContent(filepath.Join(dir, "foobar.20240102T000100.000.log"), []byte("day 1\n"), t)
+	existsWithContent(logFile(dir), []byte("day 2\n"), t)
+}
+
+func TestSyncMill(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestSyncMill", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:     logFile(dir),
+		UtcTime:      true,
+		Compress:     true,
+		MaxBackups:   1,
+		TotalSizeCap: GB,
+		SyncMill:     true,
+	}}
+	defer l.Close()
+
+	var backups []string
+	for i := 0; i < 3; i++ {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		backups = append(backups, backupFile(dir)+compressSuffix)
+	}
+
+	// 无需等待，滚动返回时已完成压缩及清理
+	notExist(backups[0], t)
+	notExist(backups[1], t)
+	exists(backups[2], t)
+	fileCount(dir, 2, t)
+
+	// 子日志文件同步清理时，父日志文件的总大小控制不会死锁
+	slow := l.Child("slow")
+	defer slow.Close()
+	_, err := slow.Write([]byte("ssss"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(slow.Rotate(), t)
+	exists(filepath.Join(dir, "foobar_slow."+fakeTime().UTC().Format(backupTimeFormat)+".log"+compressSuffix), t)
+}
+
+func TestMillErrors(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestMillErrors", t)
+	defer os.RemoveAll(dir)
+
+	// 压缩时无法读取历史文件的属性
+	errStat := errors.New("permission denied")
+	osStat = func(name string) (os.FileInfo, error) {
+		if name != logFile(dir) {
+			return nil, errStat
+		}
+		return os.Stat(name)
+	}
+	defer func() { osStat = os.Stat }()
+
+	var hooked []error
+	l := &file{Config: Config{
+		Filename: logFile(dir),
+		UtcTime:  true,
+		Compress: true,
+		SyncMill: true,
+		OnError:  func(err error) { hooked = append(hooked, err) },
+	}}
+	defer l.Close()
+
+	isNil(l.LastError(), t)
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+
+	notNil(l.LastError(), t)
+	assert(strings.Contains(l.LastError().Error(), "permission denied"), t, "unexpected error %v", l.LastError())
+	equals(1, len(hooked), t)
+	stats := l.Stats()
+	equals(int64(1), stats.MillErrors, t)
+	equals(l.LastError().Error(), stats.LastError, t)
+}
+
+func TestInternalLogger(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestInternalLogger", t)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	SetInternalLogger(&buf)
+	defer SetInternalLogger(nil)
+
+	l := &file{Config: Config{
+		Filename:   logFile(dir),
+		UtcTime:    true,
+		MaxBackups: 1,
+		SyncMill:   true,
+	}}
+	defer l.Close()
+
+	var backups []string
+	for i := 0; i < 2; i++ {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		backups = append(backups, backupFile(dir))
+	}
+	notExist(backups[0], t)
+	assert(strings.Contains(buf.String(), "rotatefile: "), t, "missing prefix in %q", buf.String())
+	assert(strings.Contains(buf.String(), "removed backup "+backups[0]), t, "missing removal in %q", buf.String())
+
+	equals(nil, selfDebugWriter("0"), t)
+	equals(io.Writer(os.Stderr), selfDebugWriter("stderr"), t)
+	w := selfDebugWriter(filepath.Join(dir, "debug", "self.log"))
+	f, ok := w.(*os.File)
+	assert(ok, t, "expected file, got %T", w)
+	equals(filepath.Join(dir, "debug", "self.log"), f.Name(), t)
+	isNil(f.Close(), t)
+}
+
+func TestEvents(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestEvents", t)
+	defer os.RemoveAll(dir)
+
+	ch := make(chan Event, 100)
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(true),
+		WithMaxBackups(1),
+		WithSyncMill(true),
+		WithEventChan(ch),
+	)
+	defer l.Close()
+
+	var backups []string
+	for i := 0; i < 2; i++ {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		backups = append(backups, backupFile(dir))
+	}
+
+	var got []string
+	for len(ch) > 0 {
+		e := <-ch
+		assert(!e.Time.IsZero(), t, "event time not set")
+		got = append(got, e.Type.String()+" "+e.Path)
+	}
+	equals([]string{
+		"FileOpened " + logFile(dir),
+		"Rotated " + backups[0],
+		"FileOpened " + logFile(dir),
+		"Compressed " + backups[0] + compressSuffix,
+		"Rotated " + backups[1],
+		"FileOpened " + logFile(dir),
+		"Deleted " + backups[0] + compressSuffix,
+		"Compressed " + backups[1] + compressSuffix,
+	}, got, t)
+	equals("EventType(0)", EventType(0).String(), t)
+}
+
+func TestRotateWithSuffix(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRotateWithSuffix", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{Filename: logFile(dir), MaxBackups: 1, SyncMill: true}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("before deploy"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.RotateWithSuffix("deploy-v1.2.3"), t)
+
+	labeled := filepath.Join(dir, "foobar."+fakeTime().Format(backupTimeFormat)+".deploy-v1.2.3.log")
+	existsWithContent(labeled, []byte("before deploy"), t)
+
+	backups, err := l.Backups()
+	isNil(err, t)
+	equals(1, len(backups), t)
+	equals(filepath.Base(labeled), backups[0].Name, t)
+
+	active, ts, ok := l.splitAnyBackupName(filepath.Base(labeled))
+	assert(ok, t, "labeled backup not parsed")
+	equals("foobar.log", active, t)
+	equals(fakeTime().Format(backupTimeFormat), ts.Format(backupTimeFormat), t)
+
+	// 带标签的历史文件同样参与清理
+	_, err = l.Write([]byte("after deploy"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	notExist(labeled, t)
+	existsWithContent(backupFileLocal(dir), []byte("after deploy"), t)
+
+	notNil(l.RotateWithSuffix("../evil"), t)
+}
+
+func TestBackupsBeforeWrite(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestBackupsBeforeWrite", t)
+	defer os.RemoveAll(dir)
+
+	backup := backupFile(dir)
+	isNil(os.WriteFile(backup, []byte("boo!"), 0o644), t)
+
+	l := &file{Config: Config{Filename: logFile(dir), CtlSocket: "ctl.sock", UtcTime: true}}
+	defer l.Close()
+
+	// 只读接口只生成文件名，不启动清理协程及控制通道
+	backups, err := l.Backups()
+	isNil(err, t)
+	equals(1, len(backups), t)
+	equals(filepath.Base(backup), backups[0].Name, t)
+	assert(l.millCh == nil, t, "mill goroutine started")
+	notExist(filepath.Join(dir, "ctl.sock"), t)
+
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	assert(l.millCh != nil, t, "mill goroutine not started")
+	exists(filepath.Join(dir, "ctl.sock"), t)
+}
+
+func TestRotateNow(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRotateNow", t)
+	defer os.RemoveAll(dir)
+
+	// 不压缩，否则历史文件可能在检查前已被压缩
+	l := New(WithFilename(logFile(dir)), WithUtcTime(true), WithCompress(false))
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	backup, err := l.RotateNow()
+	isNil(err, t)
+	equals(backupFile(dir), backup, t)
+	existsWithContent(backup, []byte("boo!"), t)
+	existsWithContent(logFile(dir), []byte{}, t)
+}
+
+func TestFilenamePlaceholders(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestFilenamePlaceholders", t)
+	defer os.RemoveAll(dir)
+
+	t.Setenv("POD_NAME", "web-0")
+	hostname, _ := os.Hostname()
+
+	l := New(WithFilename(filepath.Join(dir, "app-{pod}-{hostname}-{pid}.log")))
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	filename := filepath.Join(dir, "app-web-0-"+hostname+"-"+fmt.Sprint(os.Getpid())+".log")
+	equals(filename, l.GetFilename(), t)
+	existsWithContent(filename, []byte("boo!"), t)
+
+	t.Setenv("POD_NAME", "")
+	equals("app-"+hostname+".log", expandPlaceholders("app-{pod}.log"), t)
+	equals("app.log", expandPlaceholders("app.log"), t)
+}
+
+func TestDatePatternFilename(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDatePatternFilename", t)
+	defer os.RemoveAll(dir)
+
+	l := New(
+		WithFilename(filepath.Join(dir, "app-%Y%m%d.log")),
+		WithCompress(false),
+		WithMaxBackups(1),
+		WithSyncMill(true),
+	)
+	defer l.Close()
+
+	dated := func() string {
+		return filepath.Join(dir, "app-"+fakeTime().Format("20060102")+".log")
+	}
+
+	_, err := l.Write([]byte("day1"))
+	isNil(err, t)
+	day1 := dated()
+	equals(day1, l.GetFilename(), t)
+
+	newFakeTime()
+	_, err = l.Write([]byte("day2"))
+	isNil(err, t)
+	day2 := dated()
+	equals(day2, l.GetFilename(), t)
+	existsWithContent(day1, []byte("day1"), t)
+	existsWithContent(day2, []byte("day2"), t)
+
+	backups, err := l.Backups()
+	isNil(err, t)
+	equals(1, len(backups), t)
+	equals(filepath.Base(day1), backups[0].Name, t)
+
+	// 之前日期的文件作为历史文件参与清理
+	newFakeTime()
+	_, err = l.Write([]byte("day3"))
+	isNil(err, t)
+	notExist(day1, t)
+	existsWithContent(day2, []byte("day2"), t)
+	existsWithContent(dated(), []byte("day3"), t)
+
+	m := newDatePatternMatcher("app-%Y%m%d.log", []string{compressSuffix}, time.UTC)
+	ts, ok := m.MatchBackup("app-20240103.log", "app-20240102.log.gz")
+	assert(ok, t, "compressed dated file not matched")
+	equals(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), ts, t)
+	ts, ok = m.MatchBackup("app-20240103.log", "app-20240103.20240103T101500.000.log")
+	assert(ok, t, "size rotated dated file not matched")
+	equals(time.Date(2024, 1, 3, 10, 15, 0, 0, time.UTC), ts, t)
+	_, ok = m.MatchBackup("app-20240103.log", "app-20240103.log")
+	assert(!ok, t, "active file matched")
+	_, ok = m.MatchBackup("app-20240103.log", "other-20240102.log")
+	assert(!ok, t, "other file matched")
+}
+
+func TestGenerateFilenameOptions(t *testing.T) {
+	dir := makeTempDir("TestGenerateFilenameOptions", t)
+	defer os.RemoveAll(dir)
+
+	notDir := filepath.Join(dir, "not-a-dir")
+	isNil(os.WriteFile(notDir, nil, 0o644), t)
+
+	filename, lock, err := GenerateFilename(FilenameOptions{
+		AppName:       "app",
+		Prefix:        "x-",
+		DirCandidates: []string{notDir, dir},
+		NameTemplate:  "{app}-{pid}.log",
+	})
+	isNil(err, t)
+	assert(lock == nil, t, "lock without TryLock")
+	equals(filepath.Join(dir, "x-app-"+fmt.Sprint(os.Getpid())+".log"), filename, t)
+
+	// 指定的日志文件优先
+	filename, _, err = GenerateFilename(FilenameOptions{AppName: "app", Filename: logFile(dir), DirCandidates: []string{notDir}})
+	isNil(err, t)
+	equals(logFile(dir), filename, t)
+
+	// 已被锁定时，文件名中加上进程号
+	o := FilenameOptions{AppName: "app", DirCandidates: []string{dir}, NameTemplate: "{app}.log", TryLock: true}
+	filename, lock, err = GenerateFilename(o)
+	isNil(err, t)
+	defer lock.Unlock()
+	equals(filepath.Join(dir, "app.log"), filename, t)
+	filename, lock2, err := GenerateFilename(o)
+	isNil(err, t)
+	defer lock2.Unlock()
+	equals(filepath.Join(dir, "app."+fmt.Sprint(os.Getpid())+".log"), filename, t)
+
+	_, _, err = GenerateFilename(FilenameOptions{AppName: "app", DirCandidates: []string{notDir}})
+	assert(errors.Is(err, ErrNoLogDir), t, "expected ErrNoLogDir, got %v", err)
+}
+
+func TestLogDirCandidates(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestLogDirCandidates", t)
+	defer os.RemoveAll(dir)
+
+	notDir := filepath.Join(dir, "not-a-dir")
+	isNil(os.WriteFile(notDir, nil, 0o644), t)
+	logDir := filepath.Join(dir, "logs")
+
+	t.Setenv("LOG_DIR_CANDIDATES", notDir+string(filepath.ListSeparator)+dir)
+	t.Setenv("LOG_DIR", logDir)
+	c := NewConfig()
+	equals([]string{notDir, dir}, c.DirCandidates, t)
+	equals(logDir, c.LogDir, t)
+
+	l := New(WithAppName("app"), WithLogDir(notDir), WithDirCandidates(notDir, logDir))
+	defer l.Close()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	equals(logDir, filepath.Dir(l.GetFilename()), t)
+	existsWithContent(l.GetFilename(), []byte("boo!"), t)
+
+	// 没有可写的日志目录时，返回错误而不是 panic
+	l2 := New(WithAppName("app"), WithLogDir(""), WithDirCandidates(notDir), WithStderrFallback(false))
+	defer l2.Close()
+	_, err = l2.Write([]byte("boo!"))
+	assert(errors.Is(err, ErrNoLogDir), t, "expected ErrNoLogDir, got %v", err)
+}
+
+func TestEnvMinFreeInodes(t *testing.T) {
+	// inode 数不是字节大小，不按 K/M 等单位换算
+	t.Setenv("LOG_MIN_FREE_INODES", "1000")
+	equals(uint64(1000), NewConfig().MinFreeInodes, t)
+	t.Setenv("LOG_MIN_FREE_INODES", "1K")
+	equals(uint64(0), NewConfig().MinFreeInodes, t)
+	t.Setenv("LOG_MIN_FREE_INODES", "-1")
+	equals(uint64(0), NewConfig().MinFreeInodes, t)
+}
+
+func TestNoLogDir(t *testing.T) {
+	dir := makeTempDir("TestNoLogDir", t)
+	defer os.RemoveAll(dir)
+
+	notDir := filepath.Join(dir, "not-a-dir")
+	isNil(os.WriteFile(notDir, nil, 0o644), t)
+
+	_, err := Open(WithAppName("app"), WithLogDir(""), WithDirCandidates(notDir))
+	assert(errors.Is(err, ErrNoLogDir), t, "expected ErrNoLogDir, got %v", err)
+
+	rf, err := Open(WithAppName("app"), WithLogDir(""), WithDirCandidates(dir))
+	isNil(err, t)
+	defer rf.Close()
+	equals(dir, filepath.Dir(rf.GetFilename()), t)
+
+	// 默认改为写入标准错误
+	stderr := os.Stderr
+	defer func() { os.Stderr = stderr }()
+	os.Stderr, err = os.Create(filepath.Join(dir, "stderr"))
+	isNil(err, t)
+	defer os.Stderr.Close()
+
+	l := New(WithAppName("app"), WithLogDir(""), WithDirCandidates(notDir))
+	defer l.Close()
+	n, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	equals(4, n, t)
+	existsWithContent(filepath.Join(dir, "stderr"), []byte("boo!"), t)
+}
+
+func TestLockDir(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestLockDir", t)
+	defer os.RemoveAll(dir)
+
+	lockDir := filepath.Join(dir, "lock")
+	logDir := filepath.Join(dir, "logs")
+	l := New(WithFilename(filepath.Join(logDir, "app.log")), WithLockDir(lockDir))
+	defer l.Close()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	notExist(filepath.Join(logDir, "app.log.lock"), t)
+	exists(lockPath(lockDir, logDir, "app.log"), t)
+	assert(filepath.Dir(lockPath(lockDir, logDir, "app.log")) == lockDir, t, "lock file not in lock dir")
+
+	// 禁止锁文件
+	l2 := New(WithFilename(filepath.Join(dir, "other.log")), WithDisableLock(true))
+	defer l2.Close()
+	_, err = l2.Write([]byte("boo!"))
+	isNil(err, t)
+	notExist(filepath.Join(dir, "other.log.lock"), t)
+	entries, err := os.ReadDir(lockDir)
+	isNil(err, t)
+	equals(1, len(entries), t)
+}
+
+func TestProcessLogs(t *testing.T) {
+	registerProcessLog("TestProcessLogs", "/var/log/a.log")
+	registerProcessLog("TestProcessLogs", "/var/log/b.log")
+	registerProcessLog("TestProcessLogs", "/var/log/a.log")
+
+	// 已经退出的进程，以及进程号被复用前的过期登记
+	stale := []ProcessLog{
+		{App: "TestProcessLogsDead", Pid: 1 << 30, StartTime: processStart},
+		{App: "TestProcessLogsReused", Pid: os.Getpid(), StartTime: processStart.Add(-time.Hour)},
+	}
+	for _, p := range stale {
+		data, err := json.Marshal(p)
+		isNil(err, t)
+		isNil(os.WriteFile(filepath.Join(processLogDir(), fmt.Sprintf("%s.%d.json", p.App, p.Pid)), data, 0o644), t)
+	}
+
+	logs, err := ListProcessLogs()
+	isNil(err, t)
+	var found bool
+	for _, p := range logs {
+		assert(p.App != "TestProcessLogsDead" && p.App != "TestProcessLogsReused", t, "stale entry listed: %+v", p)
+		if p.App == "TestProcessLogs" {
+			found = true
+			equals(os.Getpid(), p.Pid, t)
+			equals([]string{"/var/log/a.log", "/var/log/b.log"}, p.Filenames, t)
+		}
+	}
+	assert(found, t, "entry not listed")
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(processLogDir())
+		isNil(err, t)
+		equals(os.FileMode(0o700), info.Mode().Perm(), t)
+	}
+
+	// 查询是只读的，过期登记在进程登记时清理
+	for _, p := range stale {
+		exists(filepath.Join(processLogDir(), fmt.Sprintf("%s.%d.json", p.App, p.Pid)), t)
+	}
+	pruneProcessLogs()
+	for _, p := range stale {
+		notExist(filepath.Join(processLogDir(), fmt.Sprintf("%s.%d.json", p.App, p.Pid)), t)
+	}
+	os.Remove(filepath.Join(processLogDir(), "TestProcessLogs."+pid+".json"))
+}
+
+func TestMinFreeInodes(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestMinFreeInodes", t)
+	defer os.RemoveAll(dir)
+
+	info, err := disk.GetInfo(dir, false)
+	isNil(err, t)
+	if info.Files == 0 {
+		t.Skip("file system without fixed inodes")
+	}
+
+	ch := make(chan Event, 100)
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithTotalSizeCap(0),
+		WithMinDiskFree(0),
+		WithSyncMill(true),
+		WithEventChan(ch),
+	)
+	defer l.Close()
+
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	first := backupFile(dir)
+	existsWithContent(first, []byte("boo!"), t)
+
+	// 空余 inode 永远不足时，删除所有历史文件
+	l.(*file).MinFreeInodes = info.Ffree + 1<<40
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	notExist(first, t)
+	notExist(backupFile(dir), t)
+	existsWithContent(logFile(dir), []byte{}, t)
+
+	var low *Event
+	for len(ch) > 0 {
+		if e := <-ch; e.Type == DiskLow {
+			low = &e
+		}
+	}
+	notNil(low, t)
+	assert(low.FreeInodes > 0, t, "expected free inodes, got %d", low.FreeInodes)
+}
+
+func TestMaxDiskUsage(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestMaxDiskUsage", t)
+	defer os.RemoveAll(dir)
+
+	c := Config{MinDiskFree: 10, MaxDiskUsage: 90}
+	equals(uint64(100), c.minDiskFree(1000), t)
+	equals(uint64(10), c.minDiskFree(50), t)
+	c.MaxDiskUsage = 0
+	equals(uint64(10), c.minDiskFree(1000), t)
+
+	info, err := disk.GetInfo(dir, false)
+	isNil(err, t)
+	if info.Used*100 < 2*info.Total {
+		t.Skip("disk usage below 2%")
+	}
+
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithTotalSizeCap(0),
+		WithMinDiskFree(0),
+		WithSyncMill(true),
+	)
+	defer l.Close()
+
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	first := backupFile(dir)
+	existsWithContent(first, []byte("boo!"), t)
+
+	// 使用率超过上限时，删除历史文件
+	l.(*file).MaxDiskUsage = 1
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	notExist(first, t)
+	notExist(backupFile(dir), t)
+}
+
+func TestDiskInfoCache(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDiskInfoCache", t)
+	defer os.RemoveAll(dir)
+
+	first, err := getDiskInfo(dir)
+	isNil(err, t)
+	second, err := getDiskInfo(dir)
+	isNil(err, t)
+	equals(first.At, second.At, t)
+
+	invalidateDiskInfo(dir)
+	third, err := getDiskInfo(dir)
+	isNil(err, t)
+	assert(third.At.After(first.At), t, "expected refreshed disk info")
+
+	l := New(WithFilename(logFile(dir)))
+	defer l.Close()
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	s := l.Stats()
+	assert(s.DiskTotal > 0, t, "expected disk total")
+	equals(third.At, s.DiskCheckedAt, t)
+}
+
+func TestCleanupStrategy(t *testing.T) {
+	currentTime = fakeTime
+	for _, c := range []struct {
+		strategy string
+		suffixes []string
+		sizes    []int
+		cap      uint64
+		kept     []bool
+	}{
+		{CleanupOldest, []string{"", "", ""}, []int{10, 50, 10}, 55, []bool{false, false, true}},
+		{CleanupLargest, []string{"", "", ""}, []int{10, 50, 10}, 55, []bool{true, false, true}},
+		{CleanupUncompressed, []string{"", compressSuffix, ""}, []int{10, 30, 30}, 50, []bool{false, true, false}},
+	} {
+		dir := makeTempDir("TestCleanupStrategy", t)
+
+		var names []string
+		for i, size := range c.sizes {
+			name := backupName(logFile(dir), fakeTime().Add(time.Duration(i-3)*time.Hour), true, "") + c.suffixes[i]
+			isNil(os.WriteFile(name, bytes.Repeat([]byte("a"), size), 0o644), t)
+			names = append(names, name)
+		}
+
+		l := New(
+			WithFilename(logFile(dir)),
+			WithUtcTime(true),
+			WithCompress(false),
+			WithTotalSizeCap(c.cap),
+			WithMinDiskFree(0),
+			WithSyncMill(true),
+			WithCleanupStrategy(c.strategy),
+		)
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+		isNil(l.Close(), t)
+
+		for i, name := range names {
+			_, err := os.Stat(name)
+			assert(c.kept[i] == (err == nil), t, "%s: %s kept %v, expected %v", c.strategy, name, err == nil, c.kept[i])
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestMinBackupAge(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestMinBackupAge", t)
+	defer os.RemoveAll(dir)
+
+	var names []string
+	for i := 3; i > 0; i-- {
+		name := backupName(logFile(dir), fakeTime().Add(-time.Duration(i)*time.Hour), true, "")
+		isNil(os.WriteFile(name, []byte("0123456789"), 0o644), t)
+		names = append(names, name)
+	}
+
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithTotalSizeCap(5),
+		WithMinDiskFree(0),
+		WithSyncMill(true),
+		WithMinBackupAge(90*time.Minute),
+	)
+	defer l.Close()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	// 超过 TotalSizeCap，但最近 90 分钟内的历史文件不删除
+	notExist(names[0], t)
+	notExist(names[1], t)
+	exists(names[2], t)
+}
+
+func TestArchiver(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestArchiver", t)
+	defer os.RemoveAll(dir)
+
+	var mu sync.Mutex
+	ok := false
+	var archived []string
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithMaxBackups(1),
+		WithSyncMill(true),
+		WithArchiver(ArchiverFunc(func(path string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if !ok {
+				return errors.New("upload failed")
+			}
+			archived = append(archived, path)
+			return nil
+		})),
+	)
+	defer l.Close()
+	f := l.(*file)
+
+	var backups []string
+	rotate := func() {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		backups = append(backups, backupFile(dir))
+	}
+
+	// 归档失败时，超过 MaxBackups 的历史文件也不删除
+	rotate()
+	rotate()
+	exists(backups[0], t)
+	exists(backups[1], t)
+
+	mu.Lock()
+	ok = true
+	mu.Unlock()
+	isNil(f.millRunOnce(), t)
+	equals(backups, archived, t)
+	isNil(f.millRunOnce(), t)
+	notExist(backups[0], t)
+	exists(backups[1], t)
+
+	m, err := LoadManifest(f.manifestPath())
+	isNil(err, t)
+	equals(1, len(m.Backups), t)
+	equals(true, m.Backups[0].Shipped, t)
+
+	// 磁盘空余低于 ArchiveEmergencyFree 时，删除尚未归档的历史文件
+	mu.Lock()
+	ok = false
+	mu.Unlock()
+	rotate()
+	notExist(backups[1], t)
+	f.ArchiveEmergencyFree = 1 << 62
+	rotate()
+	notExist(backups[2], t)
+	exists(backups[3], t)
+}
+
+func TestTrashDir(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestTrashDir", t)
+	defer os.RemoveAll(dir)
+
+	ch := make(chan Event, 100)
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithMaxBackups(1),
+		WithSyncMill(true),
+		WithTrashDir(".trash", 48*time.Hour),
+		WithEventChan(ch),
+	)
+	defer l.Close()
+
+	var backups []string
+	for i := 0; i < 2; i++ {
+		_, err := l.Write([]byte("boo!"))
+		isNil(err, t)
+		newFakeTime()
+		isNil(l.Rotate(), t)
+		backups = append(backups, backupFile(dir))
+	}
+
+	// 超过 MaxBackups 的历史文件移入隔离目录
+	trashed := filepath.Join(dir, ".trash", filepath.Base(backups[0]))
+	notExist(backups[0], t)
+	existsWithContent(trashed, []byte("boo!"), t)
+	exists(backups[1], t)
+
+	var got []string
+	for len(ch) > 0 {
+		if e := <-ch; e.Type == Trashed || e.Type == Deleted {
+			got = append(got, e.Type.String()+" "+e.Path)
+		}
+	}
+	equals([]string{"Trashed " + trashed}, got, t)
+
+	// 移入超过 TrashMaxAge 后删除（newFakeTime 前进 2 天）
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	notExist(trashed, t)
+	exists(filepath.Join(dir, ".trash", filepath.Base(backups[1])), t)
+}
+
+func TestPlanCleanup(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestPlanCleanup", t)
+	defer os.RemoveAll(dir)
+
+	var names []string
+	for i := 3; i > 0; i-- {
+		name := backupName(logFile(dir), fakeTime().Add(-time.Duration(i)*time.Hour), true, "")
+		isNil(os.WriteFile(name, []byte("0123456789"), 0o644), t)
+		names = append(names, name)
+	}
+
+	ch := make(chan Event, 100)
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(true),
+		WithMaxBackups(2),
+		WithTotalSizeCap(0),
+		WithMinDiskFree(0),
+		WithSyncMill(true),
+		WithMillDryRun(true),
+		WithEventChan(ch),
+	)
+	defer l.Close()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	expected := []Action{
+		{Op: ActionDelete, Path: names[0], Reason: ReasonMaxBackups},
+		{Op: ActionCompress, Path: names[2], Reason: ReasonCompress},
+		{Op: ActionCompress, Path: names[1], Reason: ReasonCompress},
+	}
+	actions, err := l.PlanCleanup()
+	isNil(err, t)
+	equals(expected, actions, t)
+
+	// MillDryRun 时清理只报告，不做任何修改
+	var planned []string
+	for len(ch) > 0 {
+		if e := <-ch; e.Type == Planned {
+			planned = append(planned, e.Reason+" "+e.Path)
+		}
+	}
+	equals([]string{
+		"delete max-backups " + names[0],
+		"compress compress-enabled " + names[2],
+		"compress compress-enabled " + names[1],
+	}, planned, t)
+	for _, name := range names {
+		exists(name, t)
+		notExist(name+compressSuffix, t)
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestPruneBackups", t)
+	defer os.RemoveAll(dir)
+
+	var names []string
+	for i := 4; i > 0; i-- {
+		names = append(names, backupName(logFile(dir), fakeTime().Add(-time.Duration(i)*time.Hour), true, ""))
+	}
+	empty, corrupt, valid, raw := names[0], names[1]+compressSuffix, names[2]+compressSuffix, names[3]
+	isNil(os.WriteFile(empty, nil, 0o644), t)
+	isNil(os.WriteFile(corrupt, []byte("not gzip"), 0o644), t)
+	isNil(os.WriteFile(names[2], []byte("boo!"), 0o644), t)
+	isNil((&file{}).compressLogFile(names[2], valid, GzipCompressor), t)
+	isNil(os.WriteFile(raw, []byte("boo!"), 0o644), t)
+
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithSyncMill(true),
+		WithPruneEmptyBackups(true),
+		WithVerifyCompressed(true),
+	)
+	defer l.Close()
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+
+	notExist(empty, t)
+	notExist(corrupt, t)
+	existsWithContent(filepath.Join(dir, corruptDir, filepath.Base(corrupt)), []byte("not gzip"), t)
+	exists(valid, t)
+	exists(raw, t)
+	equals(true, l.(*file).isVerified(filepath.Base(valid)), t)
+}
+
+func TestAccurateSizeCap(t *testing.T) {
+	currentTime = fakeTime
+	ts := func(d time.Duration) string { return fakeTime().UTC().Add(-d).Format(backupTimeFormat) }
+
+	for _, accurate := range []bool{false, true} {
+		dir := makeTempDir(fmt.Sprintf("TestAccurateSizeCap%v", accurate), t)
+		defer os.RemoveAll(dir)
+
+		// 其它进程追加写入的当前日志文件，本实例写入的大小为 0
+		active := logFile(dir)
+		isNil(os.WriteFile(active, []byte("01234"), 0o644), t)
+		sibling := filepath.Join(dir, "foobar.4242.log")
+		siblingBackup := filepath.Join(dir, "foobar.4242."+ts(3*time.Hour)+".log")
+		own := filepath.Join(dir, "foobar."+ts(2*time.Hour)+".log")
+		unrelated := filepath.Join(dir, "other.4242.log")
+		for _, name := range []string{sibling, siblingBackup, own, unrelated} {
+			isNil(os.WriteFile(name, []byte("0123456789"), 0o644), t)
+		}
+
+		l := &file{Config: Config{
+			Filename:        active,
+			TotalSizeCap:    30,
+			AccurateSizeCap: accurate,
+		}}
+		l.setFileName()
+
+		isNil(l.keepTotalSizeCap(dir), t)
+		if accurate {
+			// 5 + 10 + 10 + 10 = 35 > 30，删除最老的其它实例的历史文件
+			notExist(siblingBackup, t)
+		} else {
+			exists(siblingBackup, t)
+		}
+		exists(own, t)
+		exists(active, t)
+		exists(sibling, t)
+		exists(unrelated, t)
+		l.Close()
+	}
+}
+
+func TestProtectBackupBestEffort(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestProtectBackupBestEffort", t)
+	defer os.RemoveAll(dir)
+
+	// 例如非 root 用户设置不可修改属性失败
+	osChmod = func(string, os.FileMode) error { return syscall.EPERM }
+	defer func() { osChmod = os.Chmod }()
+
+	filename := logFile(dir)
+	l := &file{Config: Config{
+		Filename:        filename,
+		ReadOnlyBackups: true,
+		UtcTime:         true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	newFakeTime()
+	isNil(l.Rotate(), t)
+	_, err = l.Write([]byte("foo!"))
+	isNil(err, t)
+
+	existsWithContent(backupFile(dir), []byte("boo!"), t)
+	existsWithContent(filename, []byte("foo!"), t)
+}
+
+func TestDiskWatchStop(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDiskWatchStop", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:     logFile(dir),
+		MaxDiskUsage: 99,
+	}}
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	bg := l.bg
+	assert(bg != nil, t, "expected background goroutines started")
+	isNil(l.Close(), t)
+	assert(l.bg == nil, t, "expected background goroutines stopped")
+	select {
+	case <-bg.done:
+	default:
+		t.Fatal("expected disk watcher stopped")
+	}
+
+	// Manager 的日志流由 Manager 统一检查，关闭时停止，再次写入时重新启动
+	m := NewManager(dir, WithMaxDiskUsage(99))
+	access := m.Open("access.log")
+	_, err = access.Write([]byte("boo!"))
+	isNil(err, t)
+	assert(m.watchStop != nil, t, "expected disk watcher started")
+	isNil(m.Close(), t)
+	assert(m.watchStop == nil, t, "expected disk watcher stopped")
+	_, err = access.Write([]byte("foo!"))
+	isNil(err, t)
+	assert(m.watchStop != nil, t, "expected disk watcher restarted")
+	isNil(m.Close(), t)
+}
+
+func TestDatePatternPrefix(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestDatePatternPrefix", t)
+	defer os.RemoveAll(dir)
+
+	l := New(
+		WithFilename(filepath.Join(dir, "app-%Y%m%d.log")),
+		WithPrefix("svc-"),
+		WithCompress(false),
+		WithMaxBackups(1),
+		WithSyncMill(true),
+	)
+	defer l.Close()
+
+	day := func() string { return "app-" + fakeTime().Format("20060102") + ".log" }
+
+	_, err := l.Write([]byte("day1"))
+	isNil(err, t)
+	day1 := day()
+	equals(filepath.Join(dir, "svc-"+day1), l.GetFilename(), t)
+
+	newFakeTime()
+	_, err = l.Write([]byte("day2"))
+	isNil(err, t)
+	day2 := day()
+	equals(filepath.Join(dir, "svc-"+day2), l.GetFilename(), t)
+	existsWithContent(filepath.Join(dir, "svc-"+day1), []byte("day1"), t)
+	existsWithContent(filepath.Join(dir, "svc-"+day2), []byte("day2"), t)
+
+	// 锁随文件名切换到新日期，之前日期的锁已释放
+	notExist(filepath.Join(dir, day1+".lock"), t)
+	lock := flock.New(filepath.Join(dir, day2+".lock"))
+	ok, err := lock.TryLock()
+	isNil(err, t)
+	assert(!ok, t, "lock of the new dated file not held")
+
+	// 带前缀的之前日期文件同样参与清理
+	newFakeTime()
+	_, err = l.Write([]byte("day3"))
+	isNil(err, t)
+	notExist(filepath.Join(dir, "svc-"+day1), t)
+	existsWithContent(filepath.Join(dir, "svc-"+day()), []byte("day3"), t)
+}
+
+func TestTrashCrossDevice(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestTrashCrossDevice", t)
+	defer os.RemoveAll(dir)
+
+	// 模拟隔离目录在另一个文件系统上
+	defer func(f func(string, string) error) { trashRename = f }(trashRename)
+	trashRename = func(oldpath, newpath string) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+
+	l := New(
+		WithFilename(logFile(dir)),
+		WithUtcTime(true),
+		WithCompress(false),
+		WithMaxBackups(1),
+		WithSyncMill(true),
+		WithBackupSubdirLayout("2006/01/02"),
+		WithTrashDir(".trash", 0),
+	)
+	defer l.Close()
+
+	var subdirs []string
+	for i := 0; i < 2; i++ {
+		_, err := l.Write([]byte(fmt.Sprint("boo", i)))
+		isNil(err, t)
+		newFakeTime()
+		subdirs = append(subdirs, filepath.FromSlash(fakeTime().UTC().Format("2006/01/02")))
+		isNil(l.Rotate(), t)
+	}
+
+	// 复制后删除源文件，隔离目录中保留归档子目录
+	var trashed []string
+	isNil(filepath.WalkDir(filepath.Join(dir, ".trash"), func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			trashed = append(trashed, path)
+		}
+		return err
+	}), t)
+	equals(1, len(trashed), t)
+	rel, err := filepath.Rel(filepath.Join(dir, ".trash"), trashed[0])
+	isNil(err, t)
+	equals(subdirs[0], filepath.Dir(rel), t)
+	existsWithContent(trashed[0], []byte("boo0"), t)
+	notExist(filepath.Join(dir, rel), t)
+
+	backups, err := l.Backups()
+	isNil(err, t)
+	equals(1, len(backups), t)
+}
+
+// blockingCompressor 解压时等待 release，用于检查中断压缩的处理不阻塞写入
+type blockingCompressor struct {
+	gzipCompressor
+	once             sync.Once
+	started, release chan struct{}
+}
+
+func (*blockingCompressor) Suffix() string { return ".blk" }
+
+func (c *blockingCompressor) Decompress(src *os.File) (io.ReadCloser, error) {
+	c.once.Do(func() { close(c.started) })
+	<-c.release
+	return c.gzipCompressor.Decompress(src)
+}
+
+func TestRecoverCompressionsAsync(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecoverCompressionsAsync", t)
+	defer os.RemoveAll(dir)
+
+	// 压缩中断，压缩文件不完整
+	broken := backupFile(dir)
+	isNil(os.WriteFile(broken, []byte("data"), 0o644), t)
+	isNil(os.WriteFile(broken+".blk", []byte("not gzip"), 0o644), t)
+
+	newFakeTime()
+	c := &blockingCompressor{started: make(chan struct{}), release: make(chan struct{})}
+	l := &file{Config: Config{
+		Filename:   logFile(dir),
+		Compress:   true,
+		Compressor: c,
+		UtcTime:    true,
+	}}
+	defer l.Close()
+
+	// 在清理协程中校验，首次写入不等待
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	<-c.started
+	_, err = l.Write([]byte("boo!"))
+	isNil(err, t)
+	exists(broken, t)
+	close(c.release)
+
+	<-time.After(300 * time.Millisecond)
+	notExist(broken, t)
+	r, err := l.openBackup(broken + ".blk")
+	isNil(err, t)
+	b, err := io.ReadAll(r)
+	isNil(err, t)
+	isNil(r.Close(), t)
+	equals("data", string(b), t)
+}
+
+func TestRecoverCompressionsKeepSource(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestRecoverCompressionsKeepSource", t)
+	defer os.RemoveAll(dir)
+
+	// 保留源文件时，源文件与压缩文件同时存在是正常的，不逐个解压校验
+	kept := backupFile(dir)
+	isNil(os.WriteFile(kept, []byte("data"), 0o644), t)
+	isNil(os.WriteFile(kept+compressSuffix, []byte("not verified"), 0o644), t)
+	stale := kept + compressSuffix + compressTmpSuffix
+	isNil(os.WriteFile(stale, []byte("x"), 0o644), t)
+
+	newFakeTime()
+	l := &file{Config: Config{
+		Filename:           logFile(dir),
+		Compress:           true,
+		CompressKeepSource: true,
+		UtcTime:            true,
+		SyncMill:           true,
+	}}
+	defer l.Close()
+
+	_, err := l.Write([]byte("boo!"))
+	isNil(err, t)
+	notExist(stale, t)
+	existsWithContent(kept, []byte("data"), t)
+	existsWithContent(kept+compressSuffix, []byte("not verified"), t)
+}
+
+func TestManifestConsumed(t *testing.T) {
+	currentTime = fakeTime
+	dir := makeTempDir("TestManifestConsumed", t)
+	defer os.RemoveAll(dir)
+
+	l := &file{Config: Config{
+		Filename:   logFile(dir),
+		UtcTime:    true,
+		Manifest:   true,
+		MaxBackups: 2,
+		SyncMill:   true,
+	}}
+	defer l.Close()
+
+	var rotated []time.Time
+	for _, s := range []string{"one\n", "two\n", "three\n"} {
+		_, err := l.Write([]byte(s))
+		isNil(err, t)
+		newFakeTime()
+		rotated = append(rotated, fakeCurrentTime.Truncate(time.Millisecond)) // 文件名中的时间精确到毫秒
+		isNil(l.Rotate(), t)
+	}
+
+	// 最早的历史文件已被清理，清单中仍记录第二个历史文件的开始时间，搜索时据此跳过
+	m, err := LoadManifest(l.manifestPath())
+	isNil(err, t)
+	equals(2, len(m.Backups), t)
+	assert(m.Backups[0].First.Equal(rotated[0]), t, "first time of %s: %v", m.Backups[0].Name, m.Backups[0].First)
+
+	grep := func(until time.Time) string {
+		r, err := l.Grep("", time.Time{}, until)
+		isNilUp(err, t, 1)
+		defer r.Close()
+		b, err := io.ReadAll(r)
+		isNilUp(err, t, 1)
+		return string(b)
+	}
+	equals("", grep(rotated[0].Add(-time.Second)), t)
+	equals("two\n", grep(rotated[0].Add(time.Second)), t)
+
+	// 清单中记录、文件名未被识别的历史文件同样参与清理
+	legacy := "foobar-legacy.log"
+	isNil(os.WriteFile(filepath.Join(dir, legacy), []byte("legacy\n"), 0o644), t)
+	m.Backups = append([]ManifestEntry{{Name: legacy, Last: rotated[0].Add(-time.Hour)}}, m.Backups...)
+	data, err := json.Marshal(m)
+	isNil(err, t)
+	isNil(os.WriteFile(l.manifestPath(), data, 0o644), t)
+
+	files, err := l.oldLogFiles()
+	isNil(err, t)
+	equals(3, len(files), t)
+	equals(legacy, files[2].Name, t)
+
+	_, err = l.Write([]byte("four\n"))
+	isNil(err, t)
+	isNil(l.Rotate(), t)
+	notExist(filepath.Join(dir, legacy), t)
+}
+
 // makeTempDir creates a file with a semi-unique name in the OS temp directory.
 // It should be based on the name of the test, to keep parallel tests from
 // colliding, and must be cleaned up after the test is finished.
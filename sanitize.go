@@ -0,0 +1,65 @@
+package rotatefile
+
+// sanitize 去除 p 中的 ANSI 转义序列（颜色等）以及除 \t、\n 之外的不可打印控制字符
+// p 中不包含需要去除的字符时，直接返回 p
+func sanitize(p []byte) []byte {
+	i := 0
+	for ; i < len(p); i++ {
+		if isControl(p[i]) {
+			break
+		}
+	}
+	if i == len(p) {
+		return p
+	}
+
+	out := make([]byte, i, len(p))
+	copy(out, p[:i])
+
+	for ; i < len(p); i++ {
+		c := p[i]
+		if c == 0x1b { // ESC
+			i = skipEscape(p, i)
+			continue
+		}
+		if !isControl(c) {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// isControl 判断 c 是否为需要去除的控制字符
+func isControl(c byte) bool {
+	return (c < 0x20 && c != '\t' && c != '\n') || c == 0x7f
+}
+
+// skipEscape 跳过从 p[i]（ESC）开始的转义序列，返回序列最后一个字节的位置
+func skipEscape(p []byte, i int) int {
+	if i+1 >= len(p) {
+		return i
+	}
+
+	switch p[i+1] {
+	case '[': // CSI: ESC [ 参数字节 中间字节 结束字节(0x40-0x7e)
+		for j := i + 2; j < len(p); j++ {
+			if p[j] >= 0x40 && p[j] <= 0x7e {
+				return j
+			}
+		}
+		return len(p) - 1
+	case ']': // OSC: ESC ] ... BEL 或 ESC \
+		for j := i + 2; j < len(p); j++ {
+			if p[j] == 0x07 {
+				return j
+			}
+			if p[j] == 0x1b && j+1 < len(p) && p[j+1] == '\\' {
+				return j + 1
+			}
+		}
+		return len(p) - 1
+	default: // 其它两字节转义序列
+		return i + 1
+	}
+}
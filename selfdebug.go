@@ -0,0 +1,56 @@
+package rotatefile
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// internalLogger 库自身的诊断输出（内部错误及滚动、删除等决策），为 nil 时丢弃
+var internalLogger atomic.Pointer[log.Logger]
+
+func init() {
+	SetInternalLogger(selfDebugWriter(os.Getenv("LOG_SELF_DEBUG")))
+}
+
+// SetInternalLogger 指定库自身诊断输出的目标，例如 os.Stderr 或者文件，nil 表示丢弃（默认），
+// 也可以通过环境变量 LOG_SELF_DEBUG 指定：1/stderr 输出到标准错误，stdout 输出到标准输出，其它值为文件路径
+func SetInternalLogger(w io.Writer) {
+	if w == nil {
+		internalLogger.Store(nil)
+		return
+	}
+	internalLogger.Store(log.New(w, "rotatefile: ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile))
+}
+
+// selfDebugWriter 根据 LOG_SELF_DEBUG 的值 v 返回诊断输出的目标
+func selfDebugWriter(v string) io.Writer {
+	switch strings.ToLower(v) {
+	case "", "no", "n", "0", "off", "false", "f":
+		return nil
+	case "yes", "y", "1", "on", "true", "t", "stderr":
+		return os.Stderr
+	case "stdout":
+		return os.Stdout
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v), 0o755); err != nil {
+		return os.Stderr
+	}
+	f, err := os.OpenFile(v, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return os.Stderr
+	}
+	return f
+}
+
+// debugf 输出诊断信息
+func debugf(format string, a ...interface{}) {
+	if l := internalLogger.Load(); l != nil {
+		_ = l.Output(2, fmt.Sprintf(format, a...))
+	}
+}
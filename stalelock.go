@@ -0,0 +1,105 @@
+package rotatefile
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bingoohuang/rotatefile/flock"
+)
+
+// tryLockLogName 尝试锁定日志文件名 lockPath，锁文件中记录持有者的进程号、主机名及时间（见 flock.WithHolderInfo），
+// 锁定失败时不删除锁文件：锁定失败说明持有者仍然存活（NFS 上或者同一 Pod 不同 PID 命名空间的容器中，进程号无法判断存活）
+func tryLockLogName(lockPath string) (*flock.Flock, bool) {
+	lock := flock.New(lockPath, flock.WithHolderInfo())
+	if ok, _ := lock.TryLock(); ok {
+		return lock, true
+	}
+
+	if holder, err := lock.Holder(); err == nil {
+		debugf("lock %s held by %d@%s since %s", lockPath, holder.Pid, holder.Host, holder.Time)
+	}
+	return lock, false
+}
+
+// lockOrphan 锁定其它实例的日志文件 name（{name}.{pid}.log）的锁文件，锁文件记录的持有者是本机进程，
+// 并且能够锁定（持有者已经退出）时成功，调用方负责解锁
+func (l *file) lockOrphan(name string) (*flock.Flock, bool) {
+	lock := flock.New(lockPath(l.LockDir, l.dir(), strings.TrimPrefix(name, l.Prefix)), flock.WithHolderInfo())
+	hostname, _ := os.Hostname()
+	if holder, err := lock.Holder(); err != nil || holder.Host != hostname {
+		return nil, false
+	}
+	if ok, _ := lock.TryLock(); !ok {
+		return nil, false
+	}
+	return lock, true
+}
+
+// adoptOrphanLogs 将本机已经退出的进程因文件名被锁定而写入的 {name}.{pid}.log，改名为 {name}.log 的历史文件，
+// 以便按保留策略压缩及清理，而不是一直遗留在日志目录中（多个主机共享日志目录时，应使用 {hostname} 占位符区分文件名），
+// 只有能够锁定该文件自己的锁文件时（见 GenerateFilename），才认为写入的进程已经退出
+func (l *file) adoptOrphanLogs() {
+	if l.fileLock() == nil {
+		return
+	}
+
+	stem, ext := l.instanceStem()
+	base := filepath.Join(l.dir(), stem+ext)
+
+	entries, err := os.ReadDir(l.dir())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		owner, ok := instancePid(name, stem, ext)
+		if !ok || !e.Type().IsRegular() || strconv.Itoa(owner) == pid || processAlive(owner) {
+			continue
+		}
+
+		lock, ok := l.lockOrphan(name)
+		if !ok {
+			continue
+		}
+		l.adoptOrphan(name, base)
+		// 锁文件随日志文件名中的进程号一次性使用，持有时删除，其它进程无法再锁定该文件
+		_ = os.Remove(lock.Path())
+		_ = lock.Unlock()
+	}
+}
+
+// adoptOrphan 将其它实例遗留的日志文件 name 改名为 base 的历史文件
+func (l *file) adoptOrphan(name, base string) {
+	info, err := os.Stat(filepath.Join(l.dir(), name))
+	if err != nil {
+		return
+	}
+	backup := backupName(base, info.ModTime(), l.UtcTime, "")
+	if _, err := os.Stat(backup); err == nil {
+		return
+	}
+	if err := os.Rename(filepath.Join(l.dir(), name), backup); err != nil {
+		debugf("adopt orphan %s: %v", name, err)
+		return
+	}
+	debugf("adopt orphan %s as %s", name, backup)
+	l.emit(Event{Type: Rotated, Path: backup})
+}
+
+// instanceStem 返回日志文件名去掉本进程号后缀及扩展名的部分，例如 app.1234.log 返回 app 及 .log
+func (l *file) instanceStem() (stem, ext string) {
+	filename := filepath.Base(l.filename())
+	ext = filepath.Ext(filename)
+	return strings.TrimSuffix(filename[:len(filename)-len(ext)], "."+pid), ext
+}
+
+// instancePid 解析同一日志文件其它实例的带进程号的日志文件名 {stem}.{pid}{ext}，返回进程号
+func instancePid(name, stem, ext string) (int, bool) {
+	if len(name) <= len(stem)+1+len(ext) || !strings.HasPrefix(name, stem+".") || !strings.HasSuffix(name, ext) {
+		return 0, false
+	}
+	owner, err := strconv.Atoi(name[len(stem)+1 : len(name)-len(ext)])
+	return owner, err == nil && owner > 0
+}
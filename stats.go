@@ -0,0 +1,68 @@
+package rotatefile
+
+import "time"
+
+// Stats 日志文件的运行状态
+type Stats struct {
+	// Filename 当前日志文件
+	Filename string `json:"filename"`
+	// Size 当前日志文件大小
+	Size int64 `json:"size"`
+	// Rotations 本进程内的滚动次数
+	Rotations int64 `json:"rotations"`
+	// Dropped 因限速等原因被丢弃的写入次数
+	Dropped int64 `json:"dropped"`
+	// Backups 历史文件个数
+	Backups int `json:"backups"`
+	// BackupsSize 历史文件总大小
+	BackupsSize int64 `json:"backupsSize"`
+	// MillErrors 后台清理（压缩、删除等）失败的次数
+	MillErrors int64 `json:"millErrors"`
+	// LastError 最近一次后台清理的错误
+	LastError string `json:"lastError,omitempty"`
+	// DiskTotal 日志目录所在磁盘的总大小，与清理共享缓存的磁盘信息（缓存 1 秒）
+	DiskTotal uint64 `json:"diskTotal"`
+	// DiskFree 日志目录所在磁盘的空余大小
+	DiskFree uint64 `json:"diskFree"`
+	// DiskFreeInodes 日志目录所在磁盘的空余 inode 数，文件系统没有固定 inode 数时为 0
+	DiskFreeInodes uint64 `json:"diskFreeInodes"`
+	// DiskCheckedAt 磁盘信息的查询时间
+	DiskCheckedAt time.Time `json:"diskCheckedAt,omitempty"`
+}
+
+// Stats 返回日志文件的运行状态
+func (l *file) Stats() Stats {
+	l.mu.Lock()
+	if l.filename() == "" {
+		l.mill()
+	}
+	filename := l.filename()
+	l.mu.Unlock()
+
+	s := Stats{
+		Filename:   filename,
+		Size:       l.size.Load(),
+		Rotations:  l.rotations.Load(),
+		Dropped:    l.Dropped(),
+		MillErrors: l.millErrors.Load(),
+	}
+	if err := l.LastError(); err != nil {
+		s.LastError = err.Error()
+	}
+
+	if files, err := l.oldLogFiles(); err == nil {
+		s.Backups = len(files)
+		for _, f := range files {
+			s.BackupsSize += f.Size
+		}
+	}
+
+	if l.dir() != "" {
+		if info, err := getDiskInfo(l.dir()); err == nil {
+			s.DiskTotal, s.DiskFree, s.DiskFreeInodes = info.Total, info.Free, info.Ffree
+			s.DiskCheckedAt = info.At
+		}
+	}
+
+	return s
+}
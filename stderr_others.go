@@ -0,0 +1,14 @@
+//go:build !windows
+
+package rotatefile
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// dupStderr 将进程标准错误（fd 2）重定向到文件 f
+func dupStderr(f *os.File) error {
+	return unix.Dup2(int(f.Fd()), 2)
+}
@@ -0,0 +1,16 @@
+package rotatefile
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// dupStderr 将进程标准错误句柄重定向到文件 f
+func dupStderr(f *os.File) error {
+	if err := windows.SetStdHandle(windows.STD_ERROR_HANDLE, windows.Handle(f.Fd())); err != nil {
+		return err
+	}
+	os.Stderr = f
+	return nil
+}
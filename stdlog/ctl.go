@@ -0,0 +1,28 @@
+package stdlog
+
+import (
+	"errors"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+func init() {
+	rotatefile.RegisterCtlCommand("level", ctlLevel)
+}
+
+// ctlLevel 控制通道命令 level [T|D|I|W|E|F|P]，无参数时返回当前日志级别，否则设置日志级别
+func ctlLevel(_ rotatefile.RotateFile, args []string) (string, error) {
+	if len(args) == 0 {
+		return GetLevel().String(), nil
+	}
+	if args[0] == "" {
+		return "", errors.New("empty level")
+	}
+
+	level, err := ParseLevel(args[0][0])
+	if err != nil {
+		return "", err
+	}
+	SetLevel(level)
+	return level.String(), nil
+}
@@ -0,0 +1,91 @@
+package stdlog
+
+import (
+	"bytes"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow 重复日志合并窗口，窗口内连续相同的日志只输出一次，
+// 随后输出一行 "last message repeated N times"，0 表示不合并，FATAL/PANIC 日志总是输出
+var DefaultDedupWindow time.Duration
+
+// SetDedupWindow 设置重复日志合并窗口
+func SetDedupWindow(d time.Duration) {
+	DefaultDedupWindow = d
+}
+
+// dedup 合并连续重复的日志消息，类似 syslogd
+type dedup struct {
+	mu       sync.Mutex
+	last     []byte
+	level    Level
+	since    time.Time
+	repeated int
+	timer    *time.Timer
+}
+
+// suppress 判断日志消息是否与上一条重复而需要被合并，
+// 不重复时，先输出上一条消息的重复次数汇总
+func (d *dedup) suppress(w *wrapper, level Level, msg []byte) bool {
+	window := DefaultDedupWindow
+	if level <= FatalLevel { // FATAL/PANIC 不合并，以免跳过 OnFatal 回调
+		window = 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if window > 0 && level == d.level && bytes.Equal(msg, d.last) && now.Sub(d.since) < window {
+		d.repeated++
+		if d.timer == nil {
+			d.timer = time.AfterFunc(d.since.Add(window).Sub(now), func() { d.expire(w) })
+		}
+		return true
+	}
+
+	d.flush(w)
+	if window > 0 {
+		d.last = append(d.last[:0], msg...)
+		d.level = level
+		d.since = now
+	} else {
+		d.last = d.last[:0]
+	}
+	return false
+}
+
+// expire 合并窗口到期，输出重复次数汇总，并开始新的窗口
+func (d *dedup) expire(w *wrapper) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.timer = nil
+	d.flush(w)
+	d.last = d.last[:0]
+}
+
+// flush 输出重复次数汇总
+func (d *dedup) flush(w *wrapper) {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.repeated == 0 {
+		return
+	}
+
+	msg := GetBuffer()
+	defer PutBuffer(msg)
+	*msg = append(*msg, "last message repeated "...)
+	*msg = strconv.AppendInt(*msg, int64(d.repeated), 10)
+	*msg = append(*msg, " times"...)
+	d.repeated = 0
+
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+	levelBytes, _ := d.level.MarshalText()
+	_, _ = WriteLogLine(w.Writer, -1, levelBytes, *msg, buf)
+}
@@ -0,0 +1,29 @@
+package stdlog
+
+import "sync"
+
+var (
+	fatalHooksMu sync.RWMutex
+	fatalHooks   []func(msg []byte)
+)
+
+// OnFatal 注册 FATAL/PANIC 级别（F!/P! 标记）日志写入后的回调函数
+// 回调在 log.Fatal 等导致进程退出之前执行，可用于刷盘（RotateWriter.Flush）、通知告警系统，
+// 或在测试中通过 panic 将致命错误转换为可捕获的错误
+func OnFatal(f func(msg []byte)) {
+	fatalHooksMu.Lock()
+	defer fatalHooksMu.Unlock()
+
+	fatalHooks = append(fatalHooks, f)
+}
+
+// runFatalHooks 依次执行已注册的 FATAL/PANIC 回调函数
+func runFatalHooks(msg []byte) {
+	fatalHooksMu.RLock()
+	hooks := fatalHooks
+	fatalHooksMu.RUnlock()
+
+	for _, f := range hooks {
+		f(msg)
+	}
+}
@@ -0,0 +1,62 @@
+package stdlog
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	filtersMu sync.Mutex
+	filters   atomic.Pointer[[]*regexp.Regexp]
+)
+
+// AddFilter 添加日志过滤正则，匹配的日志消息（不含级别标记）将被直接丢弃
+// 例如用于过滤健康检查、心跳等噪音日志
+func AddFilter(re *regexp.Regexp) {
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+
+	var list []*regexp.Regexp
+	if p := filters.Load(); p != nil {
+		list = append(list, *p...)
+	}
+	list = append(list, re)
+	filters.Store(&list)
+}
+
+// ClearFilters 清除所有日志过滤正则
+func ClearFilters() {
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+
+	filters.Store(nil)
+}
+
+// addFilterPatterns 添加以英文逗号分隔的多个过滤正则，无效的正则被忽略
+func addFilterPatterns(patterns string) {
+	for _, pattern := range strings.Split(patterns, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if re, err := regexp.Compile(pattern); err == nil {
+			AddFilter(re)
+		}
+	}
+}
+
+// filtered 判断日志消息 msg 是否需要被丢弃
+func filtered(msg []byte) bool {
+	p := filters.Load()
+	if p == nil {
+		return false
+	}
+
+	for _, re := range *p {
+		if re.Match(msg) {
+			return true
+		}
+	}
+	return false
+}
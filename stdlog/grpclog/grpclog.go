@@ -0,0 +1,157 @@
+// Package grpclog 将 gRPC 内部日志及调用日志按 stdlog 格式写入滚动日志文件，
+// 独立于 stdlog，只有使用 gRPC 的程序才引入 gRPC 依赖
+package grpclog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bingoohuang/rotatefile/stdlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/status"
+)
+
+var _ grpclog.LoggerV2 = (*GrpcLogger)(nil)
+
+// GrpcLogger 实现 grpclog.LoggerV2 接口，
+// 使 gRPC 内部日志按 stdlog 格式写入滚动日志文件，用法：
+//
+//	grpclog.SetLoggerV2(stdgrpclog.NewGrpcLogger(nil, 0))
+type GrpcLogger struct {
+	w         io.Writer
+	verbosity int
+}
+
+// NewGrpcLogger 创建 gRPC 日志适配器，w 为空时使用 Init 创建的 RotateWriter，
+// verbosity 为 gRPC 详细日志级别，对应 GRPC_GO_LOG_VERBOSITY_LEVEL
+func NewGrpcLogger(w io.Writer, verbosity int) *GrpcLogger {
+	return &GrpcLogger{w: w, verbosity: verbosity}
+}
+
+// Info 记录 INFO 级别日志
+func (g *GrpcLogger) Info(args ...any) {
+	g.output(stdlog.InfoLevel, fmt.Sprint(args...))
+}
+
+// Infoln 记录 INFO 级别日志
+func (g *GrpcLogger) Infoln(args ...any) {
+	g.output(stdlog.InfoLevel, fmt.Sprintln(args...))
+}
+
+// Infof 记录 INFO 级别日志
+func (g *GrpcLogger) Infof(format string, args ...any) {
+	g.output(stdlog.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// Warning 记录 WARN 级别日志
+func (g *GrpcLogger) Warning(args ...any) {
+	g.output(stdlog.WarnLevel, fmt.Sprint(args...))
+}
+
+// Warningln 记录 WARN 级别日志
+func (g *GrpcLogger) Warningln(args ...any) {
+	g.output(stdlog.WarnLevel, fmt.Sprintln(args...))
+}
+
+// Warningf 记录 WARN 级别日志
+func (g *GrpcLogger) Warningf(format string, args ...any) {
+	g.output(stdlog.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+// Error 记录 ERROR 级别日志
+func (g *GrpcLogger) Error(args ...any) {
+	g.output(stdlog.ErrorLevel, fmt.Sprint(args...))
+}
+
+// Errorln 记录 ERROR 级别日志
+func (g *GrpcLogger) Errorln(args ...any) {
+	g.output(stdlog.ErrorLevel, fmt.Sprintln(args...))
+}
+
+// Errorf 记录 ERROR 级别日志
+func (g *GrpcLogger) Errorf(format string, args ...any) {
+	g.output(stdlog.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+// Fatal 记录日志后退出进程，符合 grpclog.LoggerV2 的约定
+func (g *GrpcLogger) Fatal(args ...any) {
+	g.output(stdlog.FatalLevel, fmt.Sprint(args...))
+	g.exit()
+}
+
+// Fatalln 记录日志后退出进程，符合 grpclog.LoggerV2 的约定
+func (g *GrpcLogger) Fatalln(args ...any) {
+	g.output(stdlog.FatalLevel, fmt.Sprintln(args...))
+	g.exit()
+}
+
+// Fatalf 记录日志后退出进程，符合 grpclog.LoggerV2 的约定
+func (g *GrpcLogger) Fatalf(format string, args ...any) {
+	g.output(stdlog.FatalLevel, fmt.Sprintf(format, args...))
+	g.exit()
+}
+
+// V 返回 gRPC 详细日志级别 l 是否开启
+func (g *GrpcLogger) V(l int) bool { return l <= g.verbosity }
+
+// exit 刷盘后退出进程
+func (g *GrpcLogger) exit() {
+	if stdlog.RotateWriter != nil {
+		stdlog.RotateWriter.Flush()
+	}
+	os.Exit(1)
+}
+
+func (g *GrpcLogger) output(level stdlog.Level, msg string) {
+	if level > stdlog.GetLevel() {
+		return
+	}
+	w := g.w
+	if w == nil {
+		if w = stdlog.RotateWriter; w == nil {
+			w = os.Stderr
+		}
+	}
+	stdlog.Output(w, 5, level, []byte(msg))
+}
+
+// GrpcUnaryServerInterceptor 返回记录 gRPC 调用日志的一元拦截器，每次调用结束后记录方法名、状态码及耗时，
+// 出错时记为 WARN 级别，w 为空时使用 Init 创建的 RotateWriter，例如：
+//
+//	grpc.NewServer(grpc.ChainUnaryInterceptor(stdgrpclog.GrpcUnaryServerInterceptor(accessFile)))
+func GrpcUnaryServerInterceptor(w io.Writer) grpc.UnaryServerInterceptor {
+	logRPC := grpcAccessLog(w)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logRPC(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// GrpcStreamServerInterceptor 返回记录 gRPC 流式调用日志的拦截器，流结束后记录，同 GrpcUnaryServerInterceptor
+func GrpcStreamServerInterceptor(w io.Writer) grpc.StreamServerInterceptor {
+	logRPC := grpcAccessLog(w)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logRPC(info.FullMethod, start, err)
+		return err
+	}
+}
+
+// grpcAccessLog 返回记录一次 gRPC 调用的函数
+func grpcAccessLog(w io.Writer) func(fullMethod string, start time.Time, err error) {
+	logger := NewGrpcLogger(w, 0)
+	return func(fullMethod string, start time.Time, err error) {
+		if err != nil {
+			logger.output(stdlog.WarnLevel, fmt.Sprintf("rpc %s %s %s error: %v", fullMethod, status.Code(err), time.Since(start), err))
+			return
+		}
+		logger.output(stdlog.InfoLevel, fmt.Sprintf("rpc %s OK %s", fullMethod, time.Since(start)))
+	}
+}
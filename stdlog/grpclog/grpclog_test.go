@@ -0,0 +1,132 @@
+package grpclog
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/rotatefile/stdlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// syncBuffer 并发安全的 bytes.Buffer
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor 等待 cond 成立，超时则失败
+func waitFor(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// lines 返回写入 buf 的日志消息（去掉时间、级别等前缀）
+func lines(buf *bytes.Buffer) []string {
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if _, msg, ok := strings.Cut(line, " : "); ok {
+			line = msg
+		}
+		msgs = append(msgs, line)
+	}
+	return msgs
+}
+
+func TestGrpcInterceptors(t *testing.T) {
+	var access syncBuffer
+	ln := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(GrpcUnaryServerInterceptor(&access)),
+		grpc.ChainStreamInterceptor(GrpcStreamServerInterceptor(&access)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("app", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx := context.Background()
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "app"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	// 流式调用在流结束后记录
+	sctx, cancel := context.WithCancel(ctx)
+	stream, err := client.Watch(sctx, &healthpb.HealthCheckRequest{Service: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	waitFor(t, func() bool { return strings.Count(access.String(), "\n") == 3 }, "stream access log not written")
+
+	got := strings.Split(strings.TrimSuffix(access.String(), "\n"), "\n")
+	if !strings.Contains(got[0], "[INFO ]") || !strings.Contains(got[0], "rpc /grpc.health.v1.Health/Check OK") {
+		t.Fatalf("unexpected access log %q", got[0])
+	}
+	if !strings.Contains(got[1], "[WARN ]") || !strings.Contains(got[1], "rpc /grpc.health.v1.Health/Check NotFound") {
+		t.Fatalf("unexpected access log %q", got[1])
+	}
+	if !strings.Contains(got[2], "rpc /grpc.health.v1.Health/Watch Canceled") {
+		t.Fatalf("unexpected access log %q", got[2])
+	}
+}
+
+func TestGrpcLogger(t *testing.T) {
+	defer stdlog.SetLevel(stdlog.GetLevel())
+	stdlog.SetLevel(stdlog.WarnLevel)
+
+	var buf bytes.Buffer
+	g := NewGrpcLogger(&buf, 2)
+	g.Infof("dropped %d", 1)
+	g.Warningln("warn", 2)
+	g.Error("error")
+	if got := lines(&buf); strings.Join(got, "|") != "warn 2|error" {
+		t.Fatalf("unexpected lines %q", got)
+	}
+	if !g.V(2) || g.V(3) {
+		t.Fatal("unexpected verbosity")
+	}
+}
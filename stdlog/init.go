@@ -1,8 +1,10 @@
 package stdlog
 
 import (
+	"errors"
 	"io"
 	"log"
+	"os"
 
 	"github.com/bingoohuang/rotatefile"
 )
@@ -16,7 +18,21 @@ var (
 func Init(fns ...rotatefile.ConfigFn) {
 	log.SetFlags(0)
 	log.SetPrefix("")
+	if env := os.Getenv("LOG_TERM_LEVEL"); env != "" {
+		if level, err := ParseLevel(env[0]); err == nil {
+			fns = append([]rotatefile.ConfigFn{WithTermLevel(level)}, fns...)
+		}
+	}
 	RotateWriter = rotatefile.New(fns...)
 	LevelLog = NewLevelLog(RotateWriter)
 	log.SetOutput(LevelLog)
 }
+
+// CaptureStderr 将进程标准错误重定向到滚动日志文件，使未捕获的 panic 调用栈等也能记录到日志中
+// 需要在 Init 之后调用
+func CaptureStderr() error {
+	if RotateWriter == nil {
+		return errors.New("stdlog is not initialized, call Init first")
+	}
+	return RotateWriter.CaptureStderr()
+}
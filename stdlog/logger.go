@@ -0,0 +1,44 @@
+package stdlog
+
+import (
+	"log"
+)
+
+// NewLogger 返回写入 stdlog 的 *log.Logger，每条日志自动加上级别标记（例如 "E!"），
+// 用于 http.Server.ErrorLog、数据库驱动等只接受标准库 *log.Logger 的地方
+// 例如：srv := &http.Server{ErrorLog: stdlog.NewLogger(stdlog.ErrorLevel)}
+func NewLogger(level Level) *log.Logger {
+	return log.New(&levelWriter{tag: level.String()[:1] + "! "}, "", 0)
+}
+
+// HTTPServerErrorLog 返回用于 http.Server.ErrorLog 的 ERROR 级别 *log.Logger
+func HTTPServerErrorLog() *log.Logger {
+	return NewLogger(ErrorLevel)
+}
+
+// SQLLogger 返回用于数据库驱动（例如 mysql.SetLogger）的 WARN 级别 *log.Logger
+func SQLLogger() *log.Logger {
+	return NewLogger(WarnLevel)
+}
+
+// levelWriter 在每条日志前加上级别标记后写入 LevelLog，未 Init 时写入标准库 log 的输出
+type levelWriter struct {
+	tag string
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	out := LevelLog
+	if out == nil {
+		out = log.Writer()
+	}
+
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	*buf = append(*buf, w.tag...)
+	*buf = append(*buf, p...)
+	if _, err := out.Write(*buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,91 @@
+package stdlog
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	moduleLevelMu sync.RWMutex
+	moduleLevels  = map[string]Level{}
+	moduleLevelOn atomic.Bool
+)
+
+// SetModuleLevel 设置指定包（模块）路径的日志级别，优先于 SetLevel 设置的全局日志级别
+// 例如 SetModuleLevel("github.com/foo/bar", WarnLevel) 只输出该包及其子包 WARN 以上级别日志
+func SetModuleLevel(module string, level Level) {
+	moduleLevelMu.Lock()
+	defer moduleLevelMu.Unlock()
+
+	moduleLevels[strings.TrimSuffix(module, "/")] = level
+	moduleLevelOn.Store(true)
+}
+
+// ResetModuleLevel 取消指定包（模块）路径的日志级别设置
+func ResetModuleLevel(module string) {
+	moduleLevelMu.Lock()
+	defer moduleLevelMu.Unlock()
+
+	delete(moduleLevels, strings.TrimSuffix(module, "/"))
+	moduleLevelOn.Store(len(moduleLevels) > 0)
+}
+
+// effectiveLevel 返回调用方所在包的日志级别，调用方包没有单独设置时，返回全局日志级别
+func effectiveLevel(callDepth int) Level {
+	if !moduleLevelOn.Load() {
+		return GetLevel()
+	}
+
+	frame, ok := callerFrame(callDepth + 1)
+	if !ok {
+		return GetLevel()
+	}
+
+	return moduleLevel(funcPackage(frame.Function))
+}
+
+// moduleLevel 按最长前缀匹配查找包 pkg 的日志级别
+func moduleLevel(pkg string) Level {
+	moduleLevelMu.RLock()
+	defer moduleLevelMu.RUnlock()
+
+	for p := pkg; p != ""; {
+		if level, ok := moduleLevels[p]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(p, '/')
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+
+	return GetLevel()
+}
+
+// callerFrame 返回调用栈中跳过 callDepth 层（以及 DefaultCallerSkip 层）之后，第一个不在标准库 log 包中的调用帧
+func callerFrame(callDepth int) (runtime.Frame, bool) {
+	rpc := make([]uintptr, 2)
+	if callers := runtime.Callers(callDepth+DefaultCallerSkip, rpc); callers >= 1 {
+		frames := runtime.CallersFrames(rpc[:callers])
+		frame, _ := frames.Next()
+		if strings.HasPrefix(frame.Function, "log.") {
+			frame, _ = frames.Next()
+		}
+		return frame, frame.PC != 0
+	}
+
+	return runtime.Frame{}, false
+}
+
+// funcPackage 从完整函数名中取得包路径
+// 例如 github.com/foo/bar.(*T).Method 的包路径为 github.com/foo/bar
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
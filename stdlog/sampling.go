@@ -0,0 +1,79 @@
+package stdlog
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sampling 某个日志级别的采样配置及计数
+type sampling struct {
+	first      int
+	thereafter int
+	second     int64
+	count      int
+}
+
+var (
+	samplingMu sync.Mutex
+	samplings  = map[Level]*sampling{}
+)
+
+// SetSampling 设置指定级别日志的采样策略，类似 zap 的 sampler
+// 每秒内该级别的前 first 条日志全部输出，之后每 thereafter 条只输出 1 条
+// thereafter 为 0 表示每秒超过 first 条之后全部丢弃，first 和 thereafter 都为 0 表示取消采样
+func SetSampling(level Level, first, thereafter int) {
+	samplingMu.Lock()
+	defer samplingMu.Unlock()
+
+	if first <= 0 && thereafter <= 0 {
+		delete(samplings, level)
+		return
+	}
+	samplings[level] = &sampling{first: first, thereafter: thereafter}
+}
+
+// parseSamplings 解析采样配置，格式为 level:first:thereafter，多个以英文逗号分隔，例如 D:100:10,T:10:100
+func parseSamplings(s string) {
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 3 || parts[0] == "" {
+			continue
+		}
+		level, err := ParseLevel(parts[0][0])
+		if err != nil {
+			continue
+		}
+		first, err1 := strconv.Atoi(parts[1])
+		thereafter, err2 := strconv.Atoi(parts[2])
+		if err1 == nil && err2 == nil {
+			SetSampling(level, first, thereafter)
+		}
+	}
+}
+
+// sampledOut 判断指定级别的日志是否因采样而需要被丢弃
+func sampledOut(level Level) bool {
+	samplingMu.Lock()
+	defer samplingMu.Unlock()
+
+	s, ok := samplings[level]
+	if !ok {
+		return false
+	}
+
+	if now := time.Now().Unix(); now != s.second {
+		s.second = now
+		s.count = 0
+	}
+
+	s.count++
+	if s.count <= s.first {
+		return false
+	}
+	if s.thereafter > 0 && (s.count-s.first)%s.thereafter == 0 {
+		return false
+	}
+	return true
+}
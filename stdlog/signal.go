@@ -0,0 +1,48 @@
+package stdlog
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var (
+	levelSignalMu sync.Mutex
+	levelSignalCh chan os.Signal
+)
+
+// SetLevelSignals 设置动态调整日志级别的信号
+// 收到第一个信号时，日志级别提升一级详细程度（例如 INFO -> DEBUG -> TRACE）
+// 收到第二个信号（如果有）时，恢复到设置信号时的日志级别
+// 只设置一个信号时，提升到 TRACE 后，再次收到信号则恢复
+func SetLevelSignals(signals ...os.Signal) {
+	levelSignalMu.Lock()
+	defer levelSignalMu.Unlock()
+
+	if levelSignalCh != nil {
+		signal.Stop(levelSignalCh)
+		close(levelSignalCh)
+		levelSignalCh = nil
+	}
+
+	if len(signals) == 0 {
+		return
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	levelSignalCh = c
+
+	origin := GetLevel()
+	go func() {
+		for s := range c {
+			if len(signals) > 1 && s != signals[0] {
+				SetLevel(origin)
+			} else if level := GetLevel(); level < TraceLevel {
+				SetLevel(level + 1)
+			} else {
+				SetLevel(origin)
+			}
+		}
+	}()
+}
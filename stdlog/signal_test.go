@@ -0,0 +1,29 @@
+//go:build !windows
+
+package stdlog
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestLevelSignals(t *testing.T) {
+	defer SetLevel(GetLevel())
+	SetLevel(InfoLevel)
+	SetLevelSignals(syscall.SIGUSR1, syscall.SIGUSR2)
+	defer SetLevelSignals()
+
+	kill := func(sig syscall.Signal) {
+		if err := syscall.Kill(os.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kill(syscall.SIGUSR1)
+	waitFor(t, func() bool { return GetLevel() == DebugLevel }, "SIGUSR1 did not raise level to DEBUG")
+	kill(syscall.SIGUSR1)
+	waitFor(t, func() bool { return GetLevel() == TraceLevel }, "SIGUSR1 did not raise level to TRACE")
+	kill(syscall.SIGUSR2)
+	waitFor(t, func() bool { return GetLevel() == InfoLevel }, "SIGUSR2 did not restore level")
+}
@@ -0,0 +1,31 @@
+package stdlog
+
+import "runtime/debug"
+
+var (
+	// DefaultStack 是否在日志行后追加 goroutine 调用栈
+	DefaultStack = false
+	// DefaultStackLevel 追加调用栈的日志级别，该级别及更严重级别的日志会追加调用栈
+	DefaultStackLevel = ErrorLevel
+)
+
+// SetStackLevel 设置追加 goroutine 调用栈的日志级别，例如 ErrorLevel 表示 ERROR/FATAL/PANIC 日志追加调用栈
+func SetStackLevel(l Level) {
+	DefaultStackLevel = l
+	DefaultStack = true
+}
+
+// DisableStack 关闭日志调用栈追加
+func DisableStack() {
+	DefaultStack = false
+}
+
+// appendStack 在日志消息 msg 后追加当前 goroutine 调用栈
+func appendStack(msg []byte, buf *[]byte) []byte {
+	*buf = append(*buf, msg...)
+	if n := len(*buf); n > 0 && (*buf)[n-1] != '\n' {
+		*buf = append(*buf, '\n')
+	}
+	*buf = append(*buf, debug.Stack()...)
+	return *buf
+}
@@ -5,10 +5,10 @@ import (
 	"io"
 	"os"
 	"regexp"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bingoohuang/rotatefile"
@@ -17,43 +17,111 @@ import (
 
 type wrapper struct {
 	Writer io.Writer
+	dedup  dedup
 }
 
 func SetCaller(l bool) {
 	DefaultCaller = l
 }
 
+// SetLevel 设置日志级别，可以在写日志的同时调用（例如信号、控制通道）
 func SetLevel(l Level) {
-	DefaultLevel = l
+	currentLevel.Store(uint32(l))
+}
+
+// GetLevel 返回当前日志级别
+func GetLevel() Level {
+	return Level(currentLevel.Load())
+}
+
+// SetCallerSkip 设置定位调用方时额外跳过的调用栈层数
+// 当使用方把 log.Printf 包装在自己的辅助函数中时，设置为包装的层数，以便打印真实调用位置
+func SetCallerSkip(n int) {
+	DefaultCallerSkip = n
+}
+
+// SetCallerFullPath 设置调用方是否打印完整文件路径，默认只打印文件名
+func SetCallerFullPath(v bool) {
+	DefaultCallerFullPath = v
 }
 
 func init() {
+	SetLevel(DefaultLevel)
 	if env := os.Getenv("LOG_LEVEL"); env != "" {
 		if level, err := ParseLevel(env[0]); err == nil {
 			SetLevel(level)
 		}
 	}
 
+	if env := os.Getenv("LOG_STACK_LEVEL"); env != "" {
+		if level, err := ParseLevel(env[0]); err == nil {
+			SetStackLevel(level)
+		}
+	}
+
+	addFilterPatterns(os.Getenv("LOG_EXCLUDE_PATTERNS"))
+	parseSamplings(os.Getenv("LOG_SAMPLING"))
+	if env := os.Getenv("LOG_DEDUP_WINDOW"); env != "" {
+		if d, err := time.ParseDuration(env); err == nil {
+			SetDedupWindow(d)
+		}
+	}
+
 	debugging := strings.Contains(os.Args[0], "/Caches/JetBrains")
 	SetCaller(rotatefile.EnvBool("LOG_CALLER", debugging))
+	SetCallerSkip(rotatefile.EnvInt("LOG_CALLER_SKIP", 0))
+	SetCallerFullPath(rotatefile.EnvBool("LOG_CALLER_FULL_PATH", false))
+	SetLevelSignals(rotatefile.EnvSignals("LOG_LEVEL_SIGNALS", nil)...)
 }
 
+// currentLevel 当前日志级别，信号、控制通道修改时与写日志并发，使用原子操作
+var currentLevel atomic.Uint32
+
 var (
-	DefaultLevel  = InfoLevel
-	DefaultCaller = false
+	// DefaultLevel 初始日志级别
+	//
+	// Deprecated: 直接赋值不是并发安全的，且初始化之后不再生效，使用 SetLevel、GetLevel
+	DefaultLevel          = InfoLevel
+	DefaultCaller         = false
+	DefaultCallerSkip     = 0
+	DefaultCallerFullPath = false
 )
 
-func (w wrapper) Write(p []byte) (n int, err error) {
+func (w *wrapper) Write(p []byte) (n int, err error) {
 	level, p, _ := parseLevelFromMsg(p)
-	if level > DefaultLevel {
+	if level > effectiveLevel(5) || filtered(p) || sampledOut(level) || w.dedup.suppress(w, level, p) {
 		return len(p), nil
 	}
 
+	if DefaultStack && level <= DefaultStackLevel {
+		stack := GetBuffer()
+		defer PutBuffer(stack)
+		p = appendStack(p, stack)
+	}
+
 	buf := GetBuffer()
 	defer PutBuffer(buf)
 
 	levelBytes, _ := level.MarshalText()
-	return WriteLogLine(w.Writer, 6, levelBytes, p, buf)
+	n, err = WriteLogLine(w.Writer, 6, levelBytes, p, buf)
+	if level <= FatalLevel {
+		runFatalHooks(p)
+	}
+	return n, err
+}
+
+// Output 按 stdlog 格式将级别为 level 的消息 msg 写入 w，不再按级别、过滤规则等筛选，
+// callDepth 同 WriteLogLine，FATAL/PANIC 级别写入后执行 OnFatal 注册的回调，用于将其它日志接口（例如 stdlog/grpclog）适配到 stdlog
+func Output(w io.Writer, callDepth int, level Level, msg []byte) (int, error) {
+	buf := GetBuffer()
+	defer PutBuffer(buf)
+
+	levelBytes, _ := level.MarshalText()
+	n, err := WriteLogLine(w, callDepth+1, levelBytes, msg, buf)
+	if level <= FatalLevel {
+		runFatalHooks(msg)
+	}
+	return n, err
 }
 
 func WriteLogLine(w io.Writer, callDepth int, level, msg []byte, buf *[]byte) (int, error) {
@@ -78,31 +146,20 @@ func WriteLogLine(w io.Writer, callDepth int, level, msg []byte, buf *[]byte) (i
 
 func writeCaller(callDepth int, b *[]byte) *[]byte {
 	*b = append(*b, '[')
-	if !DefaultCaller {
+	if !DefaultCaller || callDepth < 0 {
 		*b = append(*b, '-', ']')
 		return b
 	}
 
-	var (
-		file string
-		line int
-		ok   bool
-	)
-	rpc := make([]uintptr, 2)
-	if callers := runtime.Callers(callDepth, rpc); callers >= 1 {
-		frames := runtime.CallersFrames(rpc)
-		frame, _ := frames.Next()
-		if strings.HasPrefix(frame.Function, "log.") {
-			frame, _ = frames.Next()
-		}
-		file, line, ok = frame.File, frame.Line, frame.PC != 0
-	}
-
+	file := "???"
+	frame, ok := callerFrame(callDepth + 1)
 	if ok {
-		file = shortFile(file)
-	} else {
-		file = "???"
+		file = frame.File
+		if !DefaultCallerFullPath {
+			file = shortFile(file)
+		}
 	}
+	line := frame.Line
 
 	*b = append(*b, file...)
 	*b = append(*b, ':')
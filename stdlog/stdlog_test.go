@@ -0,0 +1,276 @@
+package stdlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor 等待 cond 成立，超时则失败
+func waitFor(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCtlLevel(t *testing.T) {
+	defer SetLevel(GetLevel())
+	SetLevel(InfoLevel)
+
+	w := NewLevelLog(io.Discard)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() { // 与写日志并发修改日志级别
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				w.Write([]byte("D! debug"))
+			}
+		}
+	}()
+
+	if got, err := ctlLevel(nil, nil); err != nil || got != "INFO" {
+		t.Fatalf("ctl level = %q, %v", got, err)
+	}
+	if got, err := ctlLevel(nil, []string{"debug"}); err != nil || got != "DEBUG" {
+		t.Fatalf("ctl level debug = %q, %v", got, err)
+	}
+	if GetLevel() != DebugLevel {
+		t.Fatalf("level not changed: %v", GetLevel())
+	}
+	if _, err := ctlLevel(nil, []string{"x"}); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+	close(done)
+	wg.Wait()
+}
+
+// lines 返回写入 buf 的日志消息（去掉时间、级别等前缀）
+func lines(buf *bytes.Buffer) []string {
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if _, msg, ok := strings.Cut(line, " : "); ok {
+			line = msg
+		}
+		msgs = append(msgs, line)
+	}
+	return msgs
+}
+
+func TestDedup(t *testing.T) {
+	defer SetDedupWindow(DefaultDedupWindow)
+	SetDedupWindow(time.Minute)
+
+	var buf bytes.Buffer
+	w := NewLevelLog(&buf)
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("E! retry failed"))
+	}
+	w.Write([]byte("E! gave up"))
+	if got, want := strings.Join(lines(&buf), "|"), "retry failed|last message repeated 2 times|gave up"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// 重复的 FATAL 日志不合并，每次都执行 OnFatal 回调
+	fatals := 0
+	OnFatal(func(msg []byte) {
+		if string(msg) == "dedup fatal" {
+			fatals++
+		}
+	})
+	buf.Reset()
+	w.Write([]byte("F! dedup fatal"))
+	w.Write([]byte("F! dedup fatal"))
+	if fatals != 2 || len(lines(&buf)) != 2 {
+		t.Fatalf("fatal hooks %d, lines %q", fatals, lines(&buf))
+	}
+}
+
+func TestModuleLevel(t *testing.T) {
+	defer SetLevel(GetLevel())
+	SetLevel(InfoLevel)
+
+	var buf bytes.Buffer
+	logger := log.New(NewLevelLog(&buf), "", 0)
+
+	const pkg = "github.com/bingoohuang/rotatefile/stdlog"
+	SetModuleLevel("github.com/bingoohuang/rotatefile", ErrorLevel)
+	SetModuleLevel(pkg+"/", DebugLevel) // 最长前缀优先
+	logger.Print("D! module debug")
+	ResetModuleLevel(pkg)
+	logger.Print("W! parent warn")
+	logger.Print("E! parent error")
+	ResetModuleLevel("github.com/bingoohuang/rotatefile")
+	logger.Print("D! global debug")
+	logger.Print("I! global info")
+
+	if got := strings.Join(lines(&buf), "|"); got != "module debug|parent error|global info" {
+		t.Fatalf("unexpected lines %q", got)
+	}
+	if got := funcPackage("github.com/foo/bar.(*T).Method"); got != "github.com/foo/bar" {
+		t.Fatalf("funcPackage = %q", got)
+	}
+}
+
+// logVia 模拟使用方包装 log.Print 的辅助函数
+func logVia(logger *log.Logger, msg string) { logger.Print(msg) }
+
+func TestCaller(t *testing.T) {
+	defer SetCaller(DefaultCaller)
+	defer SetCallerSkip(DefaultCallerSkip)
+	defer SetCallerFullPath(DefaultCallerFullPath)
+	SetCaller(true)
+
+	var buf bytes.Buffer
+	logger := log.New(NewLevelLog(&buf), "", 0)
+	_, file, line, _ := runtime.Caller(0)
+	logger.Print("I! direct")
+	SetCallerSkip(1)
+	logVia(logger, "I! wrapped")
+	SetCallerFullPath(true)
+	logVia(logger, "I! full path")
+
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, want := range []string{
+		fmt.Sprintf("[stdlog_test.go:%d] : direct", line+1),
+		fmt.Sprintf("[stdlog_test.go:%d] : wrapped", line+3),
+		fmt.Sprintf("[%s:%d] : full path", file, line+5),
+	} {
+		if !strings.HasSuffix(got[i], want) {
+			t.Fatalf("line %d: %q does not end with %q", i, got[i], want)
+		}
+	}
+}
+
+func TestStackLevel(t *testing.T) {
+	defer func(stack bool, level Level) { DefaultStack, DefaultStackLevel = stack, level }(DefaultStack, DefaultStackLevel)
+	SetStackLevel(ErrorLevel)
+
+	var buf bytes.Buffer
+	w := NewLevelLog(&buf)
+	w.Write([]byte("E! boom"))
+	if s := buf.String(); !strings.Contains(s, "boom\ngoroutine ") || !strings.Contains(s, "TestStackLevel") {
+		t.Fatalf("stack not appended: %q", s)
+	}
+
+	buf.Reset()
+	w.Write([]byte("W! warn"))
+	DisableStack()
+	w.Write([]byte("E! no stack"))
+	if got := strings.Join(lines(&buf), "|"); got != "warn|no stack" {
+		t.Fatalf("unexpected lines %q", got)
+	}
+}
+
+func TestOnFatal(t *testing.T) {
+	var buf bytes.Buffer
+	var hooked []string
+	OnFatal(func(msg []byte) {
+		if bytes.Contains(msg, []byte("on fatal")) {
+			// 回调时日志已经写入
+			hooked = append(hooked, fmt.Sprintf("%s/%d", msg, len(lines(&buf))))
+		}
+	})
+
+	w := NewLevelLog(&buf)
+	w.Write([]byte("E! on fatal error"))
+	w.Write([]byte("F! on fatal exit"))
+	w.Write([]byte("P! on fatal panic"))
+	if got := strings.Join(hooked, "|"); got != "on fatal exit/2|on fatal panic/3" {
+		t.Fatalf("unexpected hooks %q", got)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	defer ClearFilters()
+	AddFilter(regexp.MustCompile(`GET /health`))
+	addFilterPatterns(" keepalive , [invalid,")
+
+	var buf bytes.Buffer
+	w := NewLevelLog(&buf)
+	for _, msg := range []string{"I! GET /health 200", "I! keepalive sent", "W! GET /api 500"} {
+		w.Write([]byte(msg))
+	}
+	if got := strings.Join(lines(&buf), "|"); got != "GET /api 500" {
+		t.Fatalf("unexpected lines %q", got)
+	}
+
+	ClearFilters()
+	buf.Reset()
+	w.Write([]byte("I! GET /health 200"))
+	if got := strings.Join(lines(&buf), "|"); got != "GET /health 200" {
+		t.Fatalf("unexpected lines %q", got)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	defer SetLevel(GetLevel())
+	defer SetSampling(DebugLevel, 0, 0)
+	SetLevel(DebugLevel)
+	SetSampling(DebugLevel, 2, 3)
+
+	// 采样按秒计数，避免跨秒
+	if now := time.Now(); now.Nanosecond() > 5e8 {
+		time.Sleep(time.Second - time.Duration(now.Nanosecond()))
+	}
+
+	var buf bytes.Buffer
+	w := NewLevelLog(&buf)
+	for i := 1; i <= 8; i++ {
+		w.Write([]byte(fmt.Sprintf("D! debug %d", i)))
+		w.Write([]byte(fmt.Sprintf("I! info %d", i)))
+	}
+	var debugs []string
+	for _, line := range lines(&buf) {
+		if strings.HasPrefix(line, "debug") {
+			debugs = append(debugs, line)
+		}
+	}
+	if got := strings.Join(debugs, "|"); got != "debug 1|debug 2|debug 5|debug 8" {
+		t.Fatalf("unexpected sampled lines %q", got)
+	}
+	if n := len(lines(&buf)) - len(debugs); n != 8 {
+		t.Fatalf("info lines sampled: %d", n)
+	}
+
+	parseSamplings("T:1:0, bad, X:1:1, I:a:1")
+	defer SetSampling(TraceLevel, 0, 0)
+	samplingMu.Lock()
+	_, trace := samplings[TraceLevel]
+	_, info := samplings[InfoLevel]
+	samplingMu.Unlock()
+	if !trace || info {
+		t.Fatalf("unexpected samplings trace %v info %v", trace, info)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	defer func(w io.Writer) { LevelLog = w }(LevelLog)
+	var buf bytes.Buffer
+	LevelLog = NewLevelLog(&buf)
+
+	HTTPServerErrorLog().Printf("http: TLS handshake error from %s", "1.2.3.4")
+	SQLLogger().Print("driver: bad connection")
+	NewLogger(DebugLevel).Print("dropped by level")
+
+	got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(got) != 2 || !strings.Contains(got[0], "[ERROR] ") || !strings.HasSuffix(got[0], ": http: TLS handshake error from 1.2.3.4") ||
+		!strings.Contains(got[1], "[WARN ] ") || !strings.HasSuffix(got[1], ": driver: bad connection") {
+		t.Fatalf("unexpected lines %q", got)
+	}
+}
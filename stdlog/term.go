@@ -0,0 +1,33 @@
+package stdlog
+
+import (
+	"bytes"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+// WithTermLevel 指定终端输出的日志级别，只有该级别及更严重级别的日志才在终端输出
+// 例如 WithTermLevel(WarnLevel) 终端只显示 WARN/ERROR/FATAL/PANIC 日志，文件中仍然记录全部日志
+func WithTermLevel(level Level) rotatefile.ConfigFn {
+	return rotatefile.WithTermFilter(func(p []byte) bool {
+		return lineLevel(p) <= level
+	})
+}
+
+// WithSyncLevel 指定按日志级别刷盘，写入该级别及更严重级别的日志后立即刷盘
+func WithSyncLevel(level Level) rotatefile.ConfigFn {
+	return rotatefile.WithSyncFilter(func(p []byte) bool {
+		return lineLevel(p) <= level
+	})
+}
+
+// lineLevel 从 WriteLogLine 格式化后的日志行中解析日志级别，解析不到时返回 InfoLevel
+// 日志行格式：2006-01-02 15:04:05.000 [INFO ] ...
+func lineLevel(line []byte) Level {
+	if i := bytes.IndexByte(line, '['); i >= 0 && i+1 < len(line) {
+		if level, err := ParseLevel(line[i+1]); err == nil {
+			return level
+		}
+	}
+	return InfoLevel
+}
@@ -0,0 +1,77 @@
+package rotatefile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// subdirDepth 返回按日期归档的子目录层数，例如 2006/01/02 为 3 层
+func (l *file) subdirDepth() int {
+	if l.BackupSubdirLayout == "" {
+		return 0
+	}
+	return strings.Count(filepath.ToSlash(l.BackupSubdirLayout), "/") + 1
+}
+
+// archiveToSubdirs 将日志目录下的历史文件，按其滚动时间移动到 BackupSubdirLayout 格式的子目录中
+func (l *file) archiveToSubdirs(files []logInfo) []logInfo {
+	for i, f := range files {
+		if filepath.Dir(f.Name) != "." { // 已归档
+			continue
+		}
+
+		subdir := filepath.FromSlash(f.timestamp.Format(l.BackupSubdirLayout))
+		if err := os.MkdirAll(filepath.Join(l.dir(), subdir), 0o755); err != nil {
+			debugf("archive %s: %v", f.Name, err)
+			continue
+		}
+
+		name := filepath.Join(subdir, f.Name)
+		if err := l.unprotectBackup(filepath.Join(l.dir(), f.Name)); err != nil {
+			debugf("archive %s: %v", f.Name, err)
+			continue
+		}
+		if err := os.Rename(filepath.Join(l.dir(), f.Name), filepath.Join(l.dir(), name)); err != nil {
+			debugf("archive %s: %v", f.Name, err)
+			continue
+		}
+		l.protectBackup(filepath.Join(l.dir(), name))
+		files[i].Name = name
+	}
+
+	return files
+}
+
+// removeBackup 删除历史文件，name 为相对于日志目录的路径，reason 为删除原因，删除后清理空的归档子目录
+func (l *file) removeBackup(name, reason string) error {
+	if l.clean.isDryRun() {
+		l.clean.record(l.dir(), name, ActionDelete, reason)
+		return nil
+	}
+	if err := l.unprotectBackup(filepath.Join(l.dir(), name)); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(l.dir(), name)); err != nil {
+		return err
+	}
+	debugf("removed backup %s", filepath.Join(l.dir(), name))
+	invalidateDiskInfo(l.dir())
+	l.emit(Event{Type: Deleted, Path: filepath.Join(l.dir(), name)})
+	l.backupGone(name, ActionDelete, reason)
+	return nil
+}
+
+// backupGone 历史文件 name 删除或者移走后，更新归档状态，并清理空的归档子目录
+func (l *file) backupGone(name, op, reason string) {
+	l.setShipped(name, false)
+	if l.clean != nil {
+		l.clean.record(l.dir(), name, op, reason)
+	}
+
+	for sub := filepath.Dir(name); sub != "." && sub != string(filepath.Separator); sub = filepath.Dir(sub) {
+		if os.Remove(filepath.Join(l.dir(), sub)) != nil { // 非空
+			break
+		}
+	}
+}
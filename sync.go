@@ -0,0 +1,70 @@
+package rotatefile
+
+import (
+	"strings"
+	"time"
+)
+
+// 刷盘策略
+const (
+	// SyncNone 不主动刷盘，由调用方 Flush（默认）
+	SyncNone = "none"
+	// SyncInterval 每隔 SyncInterval 刷盘一次（期间有写入时）
+	SyncInterval = "interval"
+	// SyncEveryWrite 每次写入后刷盘
+	SyncEveryWrite = "write"
+	// SyncDsync 以 O_DSYNC 模式打开日志文件，每次写入返回前数据已落盘，适用于审计日志
+	SyncDsync = "dsync"
+	// SyncLevel SyncFilter 返回 true 的写入（例如 ERROR 级别以上的日志）后刷盘
+	SyncLevel = "level"
+)
+
+// defaultSyncInterval 定时刷盘的默认间隔
+const defaultSyncInterval = time.Second
+
+// syncFlag 返回打开日志文件时额外的标志
+func (l *file) syncFlag() int {
+	if strings.EqualFold(l.SyncPolicy, SyncDsync) {
+		return dsyncFlag
+	}
+	return 0
+}
+
+// syncAfterWrite 判断写入 p 后是否需要刷盘
+func (l *file) syncAfterWrite(p []byte) bool {
+	switch strings.ToLower(l.SyncPolicy) {
+	case SyncEveryWrite:
+		return true
+	case SyncLevel:
+		return l.SyncFilter != nil && l.SyncFilter(p)
+	}
+	return false
+}
+
+// startSyncInterval 启动定时刷盘协程
+func (l *file) startSyncInterval(bg *background) {
+	if !strings.EqualFold(l.SyncPolicy, SyncInterval) {
+		return
+	}
+
+	interval := l.SyncInterval
+	if interval <= 0 {
+		interval = defaultSyncInterval
+	}
+
+	bg.Go(func(done <-chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if l.dirty.Swap(false) {
+				_ = l.Flush()
+			}
+		}
+	})
+}
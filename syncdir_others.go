@@ -0,0 +1,24 @@
+//go:build !windows
+
+package rotatefile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// syncDir 同步目录 dir，使其中的重命名、删除在掉电后依然有效
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// 部分文件系统不支持同步目录
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
+		return err
+	}
+	return nil
+}
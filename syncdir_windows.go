@@ -0,0 +1,4 @@
+package rotatefile
+
+// syncDir Windows 上无法同步目录，NTFS 的元数据日志保证了重命名的持久性
+func syncDir(string) error { return nil }
@@ -0,0 +1,135 @@
+package rotatefile
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tailChunkSize 从文件末尾向前读取时，每次读取的字节数
+const tailChunkSize = 32 * 1024
+
+// TailLines 返回最近写入的 n 行日志，当前日志文件不足 n 行时，继续从历史文件（包括压缩文件）中读取
+func (l *file) TailLines(n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	l.mu.Lock()
+	if l.filename() == "" {
+		l.mill()
+	}
+	filename := l.filename()
+	l.mu.Unlock()
+
+	var lines []string
+	current, err := l.tailFileLines(filename, n)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	lines = current
+
+	if len(lines) < n {
+		files, err := l.oldLogFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range l.uniqueBackups(files) {
+			backup, err := l.tailFileLines(filepath.Join(l.dir(), f.Name), n-len(lines))
+			if err != nil {
+				if os.IsNotExist(err) { // 可能已被清理
+					continue
+				}
+				return nil, err
+			}
+			lines = append(backup, lines...)
+			if len(lines) >= n {
+				break
+			}
+		}
+	}
+
+	return lines, nil
+}
+
+// tailFileLines 返回文件最后 n 行，压缩文件解压后读取
+func (l *file) tailFileLines(name string, n int) ([]string, error) {
+	if l.compressorOf(name) != nil {
+		r, err := l.openBackup(name)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return tailReaderLines(r, n)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	for offset := info.Size(); offset > 0; {
+		size := int64(tailChunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = append(chunk, buf...)
+
+		// 多读一个换行符，以确保最前面的一行是完整的
+		if bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) >= n {
+			break
+		}
+	}
+
+	return lastLines(buf, n), nil
+}
+
+// tailReaderLines 顺序读取 r，保留最后 n 行
+func tailReaderLines(r io.Reader, n int) ([]string, error) {
+	var lines []string
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line = strings.TrimSuffix(line, "\n"); line != "" || err == nil {
+			if lines = append(lines, line); len(lines) > n {
+				lines = lines[1:]
+			}
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// lastLines 返回 buf 中的最后 n 行（不包括行尾的换行符）
+func lastLines(buf []byte, n int) []string {
+	buf = bytes.TrimSuffix(buf, []byte("\n"))
+	if len(buf) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(string(buf), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
@@ -0,0 +1,13 @@
+package rotatefile
+
+// writeTee 将写入内容同步复制到 Tee 指定的其它输出目标，输出目标的错误不影响日志文件写入
+func (l *file) writeTee(p []byte) {
+	l.teeMu.Lock()
+	defer l.teeMu.Unlock()
+
+	for _, w := range l.Tee {
+		if _, err := w.Write(p); err != nil {
+			debugf("tee: %v", err)
+		}
+	}
+}
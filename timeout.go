@@ -0,0 +1,178 @@
+package rotatefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bingoohuang/rotatefile/flock"
+)
+
+// ErrWriteTimeout 写入超时，可能是文件系统（例如 NFS、fuse）挂起
+var ErrWriteTimeout = errors.New("rotatefile: write timeout")
+
+// writeReq 交给写入协程的一次写入
+type writeReq struct {
+	f *os.File
+	p []byte
+}
+
+// writeRes 写入协程返回的写入结果
+type writeRes struct {
+	n   int
+	err error
+}
+
+// writeWatchdog 设置了 WriteTimeout 时执行文件写入的常驻协程，调用方复用同一个计时器等待写入结果，
+// 不再为每次写入创建协程及复制数据。写入挂起时调用方放弃该协程，挂起的写入返回后由它执行 abandoned
+type writeWatchdog struct {
+	reqCh chan writeReq
+	resCh chan writeRes
+	timer *time.Timer
+
+	// abandoned 放弃时设置，关闭 reqCh 之前写入，协程退出时读取
+	abandoned func()
+}
+
+func newWriteWatchdog() *writeWatchdog {
+	w := &writeWatchdog{
+		reqCh: make(chan writeReq),
+		resCh: make(chan writeRes, 1),
+		timer: time.NewTimer(time.Hour),
+	}
+	w.timer.Stop()
+	go w.run()
+	return w
+}
+
+func (w *writeWatchdog) run() {
+	for req := range w.reqCh {
+		n, err := fileWrite(req.f, req.p)
+		w.resCh <- writeRes{n: n, err: err}
+	}
+	if w.abandoned != nil {
+		w.abandoned()
+	}
+}
+
+// write 交给写入协程写入 p，超过 timeout 未完成时返回 ErrWriteTimeout，此时写入协程仍在使用 p
+func (w *writeWatchdog) write(f *os.File, p []byte, timeout time.Duration) (int, error) {
+	w.reqCh <- writeReq{f: f, p: p}
+	w.timer.Reset(timeout)
+
+	select {
+	case r := <-w.resCh:
+		if !w.timer.Stop() {
+			select {
+			case <-w.timer.C:
+			default:
+			}
+		}
+		return r.n, r.err
+	case <-w.timer.C:
+		return 0, ErrWriteTimeout
+	}
+}
+
+// stop 通知写入协程退出，abandoned 在当前写入返回后执行
+func (w *writeWatchdog) stop(abandoned func()) {
+	w.abandoned = abandoned
+	close(w.reqCh)
+}
+
+// writeWithTimeout 写入当前日志文件，设置了 WriteTimeout 时，超时后返回 ErrWriteTimeout，
+// 并切换到备用目录，以免挂起的文件系统阻塞所有日志协程，调用方需持有 l.mu
+func (l *file) writeWithTimeout(p []byte) (int, error) {
+	if l.WriteTimeout <= 0 {
+		return fileWrite(l.file, p)
+	}
+
+	if l.watchdog == nil {
+		l.watchdog = newWriteWatchdog()
+	}
+	n, err := l.watchdog.write(l.file, p, l.WriteTimeout)
+	if err == ErrWriteTimeout {
+		l.switchToFallback()
+	}
+	return n, err
+}
+
+// fileWrite is a var so we can mock it out during tests.
+var fileWrite = (*os.File).Write
+
+// switchToFallback 放弃挂起的日志文件，切换到备用目录 FallbackDir（默认系统临时目录）下的同名文件，
+// 下次写入时重新打开，并通知清理协程。挂起的文件仍由写入协程持有，不在这里关闭，以免关闭操作同样挂起，
+// 挂起的写入返回后，由写入协程关闭该文件、释放其文件名锁，主目录恢复时，下次写入切换回主目录
+func (l *file) switchToFallback() {
+	hung, hungLock := l.file, l.fileLock()
+	primary := l.primaryDir == ""
+	l.file = nil
+
+	dir := l.FallbackDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	from := l.dir()
+	if err := l.switchDir(dir); err != nil {
+		debugf("switch to fallback dir %s: %v", dir, err)
+		hungLock = nil // 仍然使用原来的文件名，保留其锁
+	} else if primary {
+		l.primaryDir = from
+	}
+
+	l.watchdog.stop(func() {
+		_ = hung.Close()
+		releaseLock(hungLock)
+		if primary {
+			l.primaryBack.Store(true)
+		}
+	})
+	l.watchdog = nil
+}
+
+// leaveFallback 切换到备用目录后，挂起的写入已经返回（主目录恢复）时，关闭备用目录的日志文件，
+// 切换回主目录，下次写入时重新打开，调用方需持有 l.mu
+func (l *file) leaveFallback() error {
+	if l.primaryDir == "" || !l.primaryBack.Load() {
+		return nil
+	}
+	if err := l.close(); err != nil {
+		return err
+	}
+
+	fallbackLock := l.fileLock()
+	if err := l.switchDir(l.primaryDir); err != nil {
+		return err
+	}
+	releaseLock(fallbackLock)
+	l.primaryDir = ""
+	l.primaryBack.Store(false)
+	return nil
+}
+
+// switchDir 切换到目录 dir 下的同名日志文件，并锁定其文件名
+func (l *file) switchDir(dir string) error {
+	name, lock, err := GenerateFilename(FilenameOptions{
+		AppName:       l.AppName,
+		Prefix:        l.Prefix,
+		Filename:      filepath.Join(dir, strings.TrimPrefix(filepath.Base(l.filename()), l.Prefix)),
+		DirCandidates: []string{dir},
+		TryLock:       !l.DisableLock,
+		LockDir:       l.LockDir,
+	})
+	if err != nil {
+		return err
+	}
+	l.setPaths(name, lock)
+	return nil
+}
+
+// releaseLock 不再使用的文件名锁，持有时删除其锁文件并解锁
+func releaseLock(lock *flock.Flock) {
+	if lock != nil && lock.Locked() {
+		_ = os.Remove(lock.Path())
+		_ = lock.Unlock()
+	}
+}
@@ -0,0 +1,160 @@
+package rotatefile
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+)
+
+// trashRename 移入隔离目录时使用的改名函数，便于测试模拟跨文件系统
+var trashRename = os.Rename
+
+// trashDir 返回隔离目录，相对路径相对于日志目录，未配置时返回空
+func (l *file) trashDir() string {
+	if l.TrashDir == "" || filepath.IsAbs(l.TrashDir) {
+		return l.TrashDir
+	}
+	return filepath.Join(l.dir(), l.TrashDir)
+}
+
+// retireBackup 按保留策略（过期、个数）清理历史文件 name，配置了 TrashDir 时移入隔离目录，否则直接删除
+func (l *file) retireBackup(name, reason string) error {
+	if l.TrashDir == "" {
+		return l.removeBackup(name, reason)
+	}
+	return l.moveBackup(name, l.trashDir(), reason)
+}
+
+// moveBackup 将历史文件 name 移入隔离目录 dir
+func (l *file) moveBackup(name, dir, reason string) error {
+	if l.clean.isDryRun() {
+		l.clean.record(l.dir(), name, ActionTrash, reason)
+		return nil
+	}
+
+	// 保留相对于日志目录的路径，避免不同归档子目录中的同名历史文件互相覆盖
+	src, dst := filepath.Join(l.dir(), name), filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := l.unprotectBackup(src); err != nil {
+		return err
+	}
+	if err := moveFile(src, dst); err != nil {
+		return err
+	}
+	// 修改时间记为移入时间，按 TrashMaxAge 清理
+	now := l.now()
+	_ = os.Chtimes(dst, now, now)
+
+	debugf("trashed backup %s to %s", src, dst)
+	l.emit(Event{Type: Trashed, Path: dst})
+	l.backupGone(name, ActionTrash, reason)
+	return nil
+}
+
+// purgeTrash 删除隔离目录中本日志文件的、移入超过 TrashMaxAge 的历史文件
+func (l *file) purgeTrash() error {
+	dir := l.trashDir()
+	if dir == "" || l.TrashMaxAge <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	prefix, ext := l.prefixAndExt()
+	now := l.now()
+	var err error
+	errWalk := filepath.WalkDir(dir, func(path string, e fs.DirEntry, errEntry error) error {
+		if errEntry != nil {
+			return errEntry
+		}
+		if _, ok := l.matchBackup(e.Name(), prefix, ext); !ok || !e.Type().IsRegular() {
+			return nil
+		}
+		info, errInfo := e.Info()
+		if errInfo != nil || now.Sub(info.ModTime()) < l.TrashMaxAge {
+			return nil
+		}
+		if l.clean.isDryRun() {
+			l.clean.plan(ActionDelete, path, ReasonTrashMaxAge)
+			return nil
+		}
+		if errRemove := os.Remove(path); errRemove != nil {
+			if err == nil {
+				err = errRemove
+			}
+			return nil
+		}
+		l.emit(Event{Type: Deleted, Path: path})
+		// 清理空的子目录
+		for sub := filepath.Dir(path); sub != dir; sub = filepath.Dir(sub) {
+			if os.Remove(sub) != nil { // 非空
+				break
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = errWalk
+	}
+	return err
+}
+
+// moveFile 移动文件 src 到 dst，跨文件系统无法直接改名时，复制后删除源文件
+func moveFile(src, dst string) error {
+	err := trashRename(src, dst)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// isCrossDevice 判断改名失败是否因为源和目标不在同一文件系统
+func isCrossDevice(err error) bool {
+	if errors.Is(err, syscall.EXDEV) {
+		return true
+	}
+	// Windows ERROR_NOT_SAME_DEVICE
+	return runtime.GOOS == "windows" && errors.Is(err, syscall.Errno(17))
+}
+
+// copyFile 复制文件 src 到 dst，保留权限及修改时间
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
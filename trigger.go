@@ -0,0 +1,55 @@
+package rotatefile
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// rotateTriggerSuffix Windows 上默认的滚动触发文件后缀，例如 foobar.log.rotate
+const rotateTriggerSuffix = ".rotate"
+
+// rotateTriggerInterval 检查滚动触发文件的间隔
+var rotateTriggerInterval = time.Second
+
+// rotateTrigger 返回滚动触发文件路径，Windows 不支持滚动信号，默认使用 {日志文件名}.rotate
+func (l *file) rotateTrigger() string {
+	trigger := l.RotateTrigger
+	if trigger == "" && runtime.GOOS == "windows" {
+		trigger = l.filename() + rotateTriggerSuffix
+	}
+	if trigger != "" && !filepath.IsAbs(trigger) {
+		trigger = filepath.Join(l.dir(), trigger)
+	}
+	return trigger
+}
+
+// watchRotateTrigger 定期检查滚动触发文件，文件存在时，删除该文件并强制滚动
+// 外部工具创建该文件即可触发滚动，例如 touch foobar.log.rotate 或者 type nul > foobar.log.rotate
+func (l *file) watchRotateTrigger(bg *background) {
+	trigger := l.rotateTrigger()
+	if trigger == "" {
+		return
+	}
+
+	ticker := time.NewTicker(rotateTriggerInterval)
+	bg.Go(func(done <-chan struct{}) {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if _, err := os.Stat(trigger); err != nil {
+				continue
+			}
+			if err := os.Remove(trigger); err != nil {
+				continue
+			}
+			l.Rotate()
+		}
+	})
+}
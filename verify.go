@@ -0,0 +1,178 @@
+package rotatefile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checksumSuffix 校验和文件扩展名，内容为 sha256sum 的输出格式
+const checksumSuffix = ".sha256"
+
+// Problem 校验发现的问题
+type Problem struct {
+	// Path 有问题的文件
+	Path string `json:"path"`
+	// Err 问题描述
+	Err string `json:"err"`
+}
+
+func (p Problem) String() string { return p.Path + ": " + p.Err }
+
+// Verify 不写入日志，校验目录 dir 中 rotatefile 格式的历史文件：
+// 压缩文件能否完整解压、校验和文件（{历史文件名}.sha256）是否匹配、文件名中的时间戳能否解析，
+// 以及清单文件与历史文件是否一致，返回发现的问题
+func Verify(dir string, fns ...ConfigFn) ([]Problem, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &file{Config: createConfig(fns...)}
+	l.setDir(dir)
+	var problems []Problem
+	report := func(name string, format string, args ...interface{}) {
+		problems = append(problems, Problem{Path: filepath.Join(dir, name), Err: fmt.Sprintf(format, args...)})
+	}
+
+	// 根据可以解析的历史文件及清单文件确定日志文件名，用于发现时间戳无法解析的历史文件
+	actives := map[string]bool{}
+	backups := map[string]bool{}
+	var manifests, others []string
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case !e.Type().IsRegular():
+		case strings.HasSuffix(name, manifestSuffix):
+			manifests = append(manifests, name)
+		case strings.HasSuffix(name, checksumSuffix), strings.HasSuffix(name, compressTmpSuffix):
+		default:
+			if active, _, ok := l.splitAnyBackupName(name); ok {
+				actives[active] = true
+				backups[name] = true
+			} else {
+				others = append(others, name)
+			}
+		}
+	}
+
+	loaded := map[string]*Manifest{}
+	for _, name := range manifests {
+		m, err := LoadManifest(filepath.Join(dir, name))
+		if err != nil {
+			report(name, "invalid manifest: %v", err)
+			continue
+		}
+		loaded[name] = m
+		if m.Filename != "" {
+			actives[m.Filename] = true
+		}
+	}
+
+	for _, name := range others {
+		if actives[name] {
+			continue
+		}
+		for active := range actives {
+			ext := filepath.Ext(active)
+			if prefix := strings.TrimSuffix(active, ext) + "."; strings.HasPrefix(name, prefix) &&
+				strings.HasSuffix(l.trimCompressSuffix(name), ext) {
+				report(name, "invalid backup timestamp, expected format %s", backupTimeFormat)
+				break
+			}
+		}
+	}
+
+	names := make([]string, 0, len(backups))
+	for name := range backups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if c := l.compressorOf(name); c != nil {
+			if err := verifyCompressed(path, c); err != nil {
+				report(name, "corrupt compressed file: %v", err)
+			}
+		}
+		if err := verifyChecksumFile(path); err != nil {
+			report(name+checksumSuffix, "%v", err)
+		}
+	}
+
+	for _, name := range manifests {
+		if m := loaded[name]; m != nil {
+			for _, err := range l.verifyManifest(m, backups) {
+				report(name, "%v", err)
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+// verifyChecksumFile 校验文件 path 与其校验和文件是否匹配，校验和文件不存在时忽略
+func verifyChecksumFile(path string) error {
+	data, err := os.ReadFile(path + checksumSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file")
+	}
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(fields[0], sum) {
+		return fmt.Errorf("checksum mismatch, expected %s, got %s", fields[0], sum)
+	}
+	return nil
+}
+
+// verifyManifest 校验清单 m 中的历史文件是否存在、大小及校验和是否一致，
+// 以及目录中属于该日志文件的历史文件是否都在清单中
+func (l *file) verifyManifest(m *Manifest, backups map[string]bool) []error {
+	var errs []error
+	listed := map[string]bool{}
+	for _, e := range m.Backups {
+		listed[e.Name] = true
+		path := filepath.Join(l.dir(), e.Name)
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", e.Name, err))
+			continue
+		}
+		if info.Size() != e.Size {
+			errs = append(errs, fmt.Errorf("%s: size mismatch, expected %d, got %d", e.Name, e.Size, info.Size()))
+			continue
+		}
+		if e.SHA256 == "" {
+			continue
+		}
+		if sum, err := fileSHA256(path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", e.Name, err))
+		} else if sum != e.SHA256 {
+			errs = append(errs, fmt.Errorf("%s: checksum mismatch, expected %s, got %s", e.Name, e.SHA256, sum))
+		}
+	}
+
+	var missing []string
+	for name := range backups {
+		if active, _, _ := l.splitAnyBackupName(name); active == m.Filename && !listed[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		errs = append(errs, fmt.Errorf("%s: not listed in manifest", name))
+	}
+	return errs
+}
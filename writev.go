@@ -0,0 +1,70 @@
+package rotatefile
+
+import (
+	"io"
+)
+
+// readFromChunkSize ReadFrom 每次读取并写入的最大字节数
+const readFromChunkSize = 32 * 1024
+
+// ReadFrom 实现 io.ReaderFrom，从 r 中分块读取并写入，超过 MaxSize 时按块滚动，而不是报错
+// 例如转储大的请求内容，注意：块的边界可能位于一行的中间
+func (l *file) ReadFrom(r io.Reader) (n int64, err error) {
+	size := int64(readFromChunkSize)
+	if max := l.max(); max < size {
+		size = max
+	}
+
+	buf := make([]byte, size)
+	for {
+		nr, er := r.Read(buf)
+		if nr > 0 {
+			nw, ew := l.Write(buf[:nr])
+			n += int64(nw)
+			if ew != nil {
+				return n, ew
+			}
+		}
+		if er == io.EOF {
+			return n, nil
+		}
+		if er != nil {
+			return n, er
+		}
+	}
+}
+
+// WriteV 依次写入多个缓冲，不合并复制，写入期间持有锁，不会穿插其它写入，
+// 单个缓冲不会被拆分到两个文件中，必要时在缓冲之间滚动
+func (l *file) WriteV(bufs [][]byte) (n int, err error) {
+	if l.AsyncWrite {
+		for _, p := range bufs {
+			nw, _ := l.writeAsync(p)
+			n += nw
+		}
+		return n, nil
+	}
+
+	writes := make([][]byte, 0, len(bufs))
+	origLens := make([]int, 0, len(bufs))
+	for _, p := range bufs {
+		if b, ok := l.beforeWrite(p); ok {
+			writes = append(writes, b)
+			origLens = append(origLens, len(p))
+		} else {
+			n += len(p)
+		}
+	}
+
+	writeTime := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, p := range writes {
+		if _, err = l.writeFileLocked(p, writeTime); err != nil {
+			return n, err
+		}
+		n += origLens[i]
+	}
+	return n, nil
+}
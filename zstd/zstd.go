@@ -0,0 +1,58 @@
+// Package zstd 提供 zstd 压缩格式，作为 rotatefile 的 Compressor 使用，
+// 压缩率及速度通常优于 gzip。导入时注册名为 zstd 的压缩格式，
+// 此后可以通过 LOG_COMPRESS_FORMAT=zstd 选用，所有日志文件都能识别 .zst 历史文件
+//
+//	import _ "github.com/bingoohuang/rotatefile/zstd"
+//
+//	rotatefile.New(rotatefile.WithCompressFormat("zstd"))
+package zstd
+
+import (
+	"io"
+	"os"
+
+	"github.com/bingoohuang/rotatefile"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Suffix zstd 压缩文件扩展名
+const Suffix = ".zst"
+
+// Compressor zstd 压缩格式，扩展名 .zst
+var Compressor rotatefile.Compressor = compressor{}
+
+func init() {
+	rotatefile.RegisterCompressFormat("zstd", New)
+}
+
+// New 创建指定压缩级别的 zstd 压缩格式，level 为 zstd 的压缩级别 1（最快）~ 22（最小），
+// 0 表示默认级别
+func New(level int) rotatefile.Compressor { return compressor{level: level} }
+
+type compressor struct{ level int }
+
+func (compressor) Suffix() string { return Suffix }
+
+func (c compressor) Compress(dst io.Writer, src *os.File) error {
+	var opts []zstd.EOption
+	if c.level > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.level)))
+	}
+	w, err := zstd.NewWriter(dst, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (compressor) Decompress(src *os.File) (io.ReadCloser, error) {
+	r, err := zstd.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	return r.IOReadCloser(), nil
+}
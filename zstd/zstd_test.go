@@ -0,0 +1,141 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bingoohuang/rotatefile"
+)
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.log")
+	data := []byte(strings.Repeat("hello zstd\n", 1000))
+	if err := os.WriteFile(src, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []int{0, 1, 9, 22} {
+		f, err := os.Open(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		err = New(level).Compress(&buf, f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if buf.Len() >= len(data) {
+			t.Fatalf("level %d: not compressed, %d >= %d", level, buf.Len(), len(data))
+		}
+
+		dst := src + Suffix
+		if err := os.WriteFile(dst, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f, err = os.Open(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := Compressor.Decompress(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		f.Close()
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("level %d: decompressed mismatch, err %v", level, err)
+		}
+	}
+}
+
+func TestRecompressGzip(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.20240102T030405.000.log")
+	if err := os.WriteFile(name, []byte("boo!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotatefile.Recompress(dir, rotatefile.WithFilename(filepath.Join(dir, "app.log"))); err != nil {
+		t.Fatal(err)
+	}
+
+	// gzip 迁移为 zstd
+	got, err := rotatefile.Recompress(dir,
+		rotatefile.WithFilename(filepath.Join(dir, "app.log")), rotatefile.WithCompressor(New(6)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != name+Suffix {
+		t.Fatalf("unexpected recompressed files %v", got)
+	}
+	if _, err := os.Stat(name + ".gz"); !os.IsNotExist(err) {
+		t.Fatalf("gzip file not removed: %v", err)
+	}
+
+	f, err := os.Open(name + Suffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := Compressor.Decompress(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if data, err := io.ReadAll(r); err != nil || string(data) != "boo!" {
+		t.Fatalf("unexpected content %q, err %v", data, err)
+	}
+}
+
+func TestCompressFormat(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	// migrate 命令生成的 .zst 历史文件，超出 MaxBackups 后与其它历史文件一起清理
+	old := []string{
+		filepath.Join(dir, "app.20240101T000000.000.log"+Suffix),
+		filepath.Join(dir, "app.20240102T000000.000.log"+Suffix),
+	}
+	for _, name := range old {
+		if err := os.WriteFile(name, []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := rotatefile.Open(rotatefile.WithFilename(filename), rotatefile.WithCompressFormat("zstd"),
+		rotatefile.WithCompress(true), rotatefile.WithMaxBackups(1), rotatefile.WithSyncMill(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := l.Write([]byte("boo!\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "app.*.log*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], Suffix) || backups[0] == old[1] {
+		t.Fatalf("expected one new zstd backup, got %v", backups)
+	}
+
+	// 读取历史文件时同样识别 .zst
+	r, err := rotatefile.Cat(filename, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if data, err := io.ReadAll(r); err != nil || string(data) != "boo!\n" {
+		t.Fatalf("unexpected content %q, err %v", data, err)
+	}
+}